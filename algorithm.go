@@ -0,0 +1,179 @@
+package ethash
+
+/*
+#include "src/libethash/internal.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+// Algorithm is a proof of work in the ethash family: a seed schedule,
+// a verification cache derived from the seed, a dataset derived from
+// the cache and a hash function reading the dataset. Forks using a
+// variant of ethash can register it with RegisterAlgorithm, verify
+// seals with VerifySeal and mine with the datasets of NewDataset.
+//
+// Light and Full manage the caches and DAG files of the C library
+// themselves, sharing them between instances and processes, and always
+// use the ethash implementation registered as "ethash".
+type Algorithm interface {
+	Name() string
+	EpochLength() uint64
+	SeedHash(epoch uint64) common.Hash
+	DatasetSize(epoch uint64) uint64
+
+	// NewCache builds the verification cache of the given epoch.
+	NewCache(epoch uint64) (AlgorithmCache, error)
+	// NewDataset builds the full dataset of the given epoch, which may
+	// be stored in dir. The empty string selects the algorithm's
+	// default directory.
+	NewDataset(epoch uint64, dir string) (AlgorithmDataset, error)
+}
+
+// AlgorithmCache is the verification cache of one epoch of an
+// Algorithm.
+type AlgorithmCache interface {
+	Epoch() uint64
+	// DatasetItem computes the dataset item at the given index.
+	DatasetItem(index uint32) []byte
+	// Hash computes the mix digest and result of the seal given by
+	// nonce for the header hash (without nonce), computing the dataset
+	// items it reads from the cache.
+	Hash(headerHash common.Hash, nonce uint64) (mixDigest, result common.Hash)
+}
+
+// AlgorithmDataset is the full dataset of one epoch of an Algorithm.
+type AlgorithmDataset interface {
+	Epoch() uint64
+	// Hash computes the mix digest and result of the seal given by
+	// nonce for the header hash (without nonce).
+	Hash(headerHash common.Hash, nonce uint64) (mixDigest, result common.Hash)
+	// Release frees the dataset, which must not be used afterwards.
+	Release()
+}
+
+var algorithms = struct {
+	mu     sync.Mutex
+	byName map[string]Algorithm
+}{byName: map[string]Algorithm{"ethash": ethashAlgorithm{}}}
+
+// RegisterAlgorithm makes an algorithm available under its name. It
+// fails if an algorithm of that name is registered already.
+func RegisterAlgorithm(alg Algorithm) error {
+	algorithms.mu.Lock()
+	defer algorithms.mu.Unlock()
+	if _, ok := algorithms.byName[alg.Name()]; ok {
+		return fmt.Errorf("algorithm %q is already registered", alg.Name())
+	}
+	algorithms.byName[alg.Name()] = alg
+	return nil
+}
+
+// GetAlgorithm returns the algorithm registered under name.
+func GetAlgorithm(name string) (Algorithm, error) {
+	algorithms.mu.Lock()
+	defer algorithms.mu.Unlock()
+	alg, ok := algorithms.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm %q", name)
+	}
+	return alg, nil
+}
+
+// Algorithms returns the names of all registered algorithms, sorted.
+func Algorithms() []string {
+	algorithms.mu.Lock()
+	defer algorithms.mu.Unlock()
+	names := make([]string, 0, len(algorithms.byName))
+	for name := range algorithms.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// VerifySeal checks the block's nonce and mix digest using the given
+// cache of alg, which must belong to the block's epoch.
+func VerifySeal(alg Algorithm, cache AlgorithmCache, block pow.Block) bool {
+	if cache.Epoch() != block.NumberU64()/alg.EpochLength() {
+		return false
+	}
+	difficulty := block.Difficulty()
+	if CheckDifficulty(difficulty) != nil {
+		return false
+	}
+	mix, result := cache.Hash(block.HashNoNonce(), block.Nonce())
+	if mix != block.MixDigest() {
+		return false
+	}
+	target := new(big.Int).Div(minDifficulty, difficulty)
+	return result.Big().Cmp(target) <= 0
+}
+
+// ethashAlgorithm is ethash as implemented by the C library.
+type ethashAlgorithm struct {
+	test bool // use the sizes of NewForTesting
+}
+
+func (ethashAlgorithm) Name() string                        { return "ethash" }
+func (ethashAlgorithm) EpochLength() uint64                 { return epochLength }
+func (ethashAlgorithm) SeedHash(epoch uint64) common.Hash   { return makeSeedHash(epoch) }
+func (alg ethashAlgorithm) DatasetSize(epoch uint64) uint64 { return datasetSize(epoch, alg.test) }
+
+func (alg ethashAlgorithm) NewCache(epoch uint64) (AlgorithmCache, error) {
+	if epoch >= maxEpoch {
+		return nil, fmt.Errorf("epoch number too high, limit is %d", maxEpoch)
+	}
+	c := newCache(epoch, alg.test, cacheConfig{})
+	if c.generate(); c.err != nil {
+		c.release()
+		return nil, c.err
+	}
+	return wrapCache(c), nil
+}
+
+// NewDataset returns the DAG of the given epoch, which is shared with
+// the Full instances using dir, see Full.Dataset.
+func (alg ethashAlgorithm) NewDataset(epoch uint64, dir string) (AlgorithmDataset, error) {
+	if epoch >= maxEpoch {
+		return nil, fmt.Errorf("epoch number too high, limit is %d", maxEpoch)
+	}
+	d := newDAG(epoch, alg.test, dir, dagConfig{})
+	if d.generate(); d.err != nil {
+		d.release()
+		return nil, d.err
+	}
+	return &Dataset{d}, nil
+}
+
+// DatasetItem computes the dataset item at the given index, see
+// CalcDatasetItem.
+func (c *Cache) DatasetItem(index uint32) []byte {
+	return CalcDatasetItem(c, index)
+}
+
+// Hash computes the mix digest and result of a seal for a block of the
+// cache's epoch.
+func (c *Cache) Hash(headerHash common.Hash, nonce uint64) (mixDigest, result common.Hash) {
+	size := datasetSize(c.cache.epoch, c.cache.test)
+	t := cgoCalls.lightCompute.begin()
+	ret := C.ethash_light_compute_internal(c.cache.ptr, C.uint64_t(size), hashToH256(headerHash), C.uint64_t(nonce))
+	cgoCalls.lightCompute.end(t)
+	runtime.KeepAlive(c)
+	return h256ToHash(ret.mix_hash), h256ToHash(ret.result)
+}
+
+// HashBatch is like Hash for many nonces of the same header, see
+// Dataset.HashBatch.
+func (c *Cache) HashBatch(headerHash common.Hash, nonces []uint64) (mixDigests, results []common.Hash) {
+	return hashBatch(nil, c.cache.ptr, datasetSize(c.cache.epoch, c.cache.test), headerHash, nonces, c)
+}
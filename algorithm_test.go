@@ -0,0 +1,103 @@
+package ethash
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type renamedAlgorithm struct {
+	ethashAlgorithm
+	name string
+}
+
+func (alg renamedAlgorithm) Name() string { return alg.name }
+
+// unregisterAlgorithm removes the algorithm registered under name, so
+// tests may register algorithms again when run repeatedly.
+func unregisterAlgorithm(name string) {
+	algorithms.mu.Lock()
+	defer algorithms.mu.Unlock()
+	delete(algorithms.byName, name)
+}
+
+func TestAlgorithmRegistry(t *testing.T) {
+	if _, err := GetAlgorithm("ethash"); err != nil {
+		t.Fatal("ethash not registered:", err)
+	}
+	if _, err := GetAlgorithm("no-such-hash"); err == nil {
+		t.Error("no error for unknown algorithm")
+	}
+	if err := RegisterAlgorithm(ethashAlgorithm{}); err == nil {
+		t.Error("ethash registered twice")
+	}
+
+	variant := renamedAlgorithm{name: "ethash-variant"}
+	if err := RegisterAlgorithm(variant); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { unregisterAlgorithm(variant.name) })
+	if alg, err := GetAlgorithm("ethash-variant"); err != nil || alg != variant {
+		t.Errorf("got %v, %v for registered variant", alg, err)
+	}
+	found := false
+	for _, name := range Algorithms() {
+		found = found || name == "ethash-variant"
+	}
+	if !found {
+		t.Errorf("variant missing from %v", Algorithms())
+	}
+}
+
+func TestVerifySeal(t *testing.T) {
+	alg, _ := GetAlgorithm("ethash")
+	cache, err := alg.NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := *validBlocks[0]
+	if !VerifySeal(alg, cache, &block) {
+		t.Error("valid block did not verify")
+	}
+	if VerifySeal(alg, cache, validBlocks[1]) {
+		t.Error("block verified with cache of another epoch")
+	}
+	block.mixDigest[31] ^= 1
+	if VerifySeal(alg, cache, &block) {
+		t.Error("block with wrong mix digest verified")
+	}
+}
+
+func TestAlgorithmDataset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	alg := ethashAlgorithm{test: true}
+	ds, err := alg.NewDataset(0, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Release()
+	cache, err := alg.NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Epoch() != 0 || cache.Epoch() != 0 {
+		t.Errorf("got dataset of epoch %d and cache of epoch %d, want 0", ds.Epoch(), cache.Epoch())
+	}
+	header := common.HexToHash("0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d")
+	for _, nonce := range []uint64{0, 1, 0x495732e0ed7a801c} {
+		mix, result := ds.Hash(header, nonce)
+		if wantMix, wantResult := cache.Hash(header, nonce); mix != wantMix || result != wantResult {
+			t.Errorf("nonce %x: dataset computed %x, %x, cache %x, %x", nonce, mix, result, wantMix, wantResult)
+		}
+	}
+	if _, err := alg.NewDataset(maxEpoch, dir); err == nil {
+		t.Error("no error for an epoch beyond the limit")
+	}
+}
@@ -0,0 +1,154 @@
+package ethash
+
+/*
+#include "src/libethash/internal.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// CacheBootstrap configures downloading verification caches from
+// other nodes or a mirror serving cache files, see SetCacheBootstrap.
+// Only caches of epochs with a pinned checksum are downloaded, so a
+// server can't make Light use a wrong cache. The cache is generated in
+// the background nonetheless. If it doesn't match the downloaded one,
+// i.e. the pin is wrong, the downloaded cache is dropped and the epoch
+// is not downloaded again.
+type CacheBootstrap struct {
+	URLs   []string               // tried in order, the cache file name is appended
+	Pins   map[uint64]common.Hash // SHA-256 of the cache contents by epoch, without the magic number
+	Client *http.Client           // nil selects http.DefaultClient
+
+	mu    sync.Mutex
+	wrong map[uint64]bool // epochs whose pin turned out wrong
+}
+
+func (b *CacheBootstrap) setWrong(epoch uint64) {
+	b.mu.Lock()
+	if b.wrong == nil {
+		b.wrong = make(map[uint64]bool)
+	}
+	b.wrong[epoch] = true
+	b.mu.Unlock()
+}
+
+// fetch downloads the cache of the given epoch from the first URL
+// serving it with the pinned checksum. The cache is returned in C
+// memory, which is freed with ethash_light_delete.
+func (b *CacheBootstrap) fetch(epoch, size uint64) (*C.struct_ethash_light, error) {
+	pin, ok := b.Pins[epoch]
+	if !ok {
+		return nil, fmt.Errorf("no checksum pinned for epoch %d", epoch)
+	}
+	b.mu.Lock()
+	wrong := b.wrong[epoch]
+	b.mu.Unlock()
+	if wrong {
+		return nil, fmt.Errorf("pinned checksum of epoch %d is wrong", epoch)
+	}
+	if len(b.URLs) == 0 {
+		return nil, errors.New("no URLs configured")
+	}
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	light, data, err := allocLight(size)
+	if err != nil {
+		return nil, err
+	}
+	name := cacheName(makeSeedHash(epoch))
+	for _, base := range b.URLs {
+		url := strings.TrimSuffix(base, "/") + "/" + name
+		if err = download(client, url, data, pin); err == nil {
+			glog.V(logger.Info).Infof("Downloaded cache for epoch %d from %s", epoch, url)
+			return light, nil
+		}
+		glog.V(logger.Debug).Infof("Can't download cache for epoch %d from %s: %v", epoch, url, err)
+	}
+	C.ethash_light_delete(light)
+	return nil, err
+}
+
+// download reads the cache file at url into data and checks it against
+// the pinned checksum.
+func download(client *http.Client, url string, data []byte, pin common.Hash) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	var magic uint64
+	if _, err := io.ReadFull(resp.Body, (*[dagMagicSize]byte)(unsafe.Pointer(&magic))[:]); err != nil {
+		return err
+	}
+	if magic != C.ETHASH_DAG_MAGIC_NUM {
+		return errors.New("not a cache file")
+	}
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return err
+	}
+	if n, _ := resp.Body.Read(make([]byte, 1)); n != 0 {
+		return errors.New("cache file too long")
+	}
+	if sha256.Sum256(data) != pin {
+		return errors.New("cache does not match the pinned checksum")
+	}
+	return nil
+}
+
+// check generates the cache and compares it with the downloaded
+// contents. On a mismatch the cache is evicted from the registry and
+// its file removed, so that the next user generates it. check owns a
+// reference to the cache.
+func (cache *cache) check() {
+	defer cache.release()
+	seed := hashToH256(makeSeedHash(cache.epoch))
+	t := cgoCalls.lightNew.begin()
+	ptr := C.ethash_light_new_internal(C.uint64_t(cache.size), &seed)
+	cgoCalls.lightNew.end(t)
+	if ptr == nil {
+		glog.V(logger.Error).Infof("Can't generate cache for epoch %d to check the download", cache.epoch)
+		return
+	}
+	trackScratch(int64(cache.size))
+	defer func() {
+		C.ethash_light_delete(ptr)
+		trackScratch(-int64(cache.size))
+	}()
+	have, _ := cBytes(unsafe.Pointer(cache.ptr.cache), cache.size)
+	want, _ := cBytes(unsafe.Pointer(ptr.cache), cache.size)
+	if bytes.Equal(have, want) {
+		glog.V(logger.Debug).Infof("Downloaded cache for epoch %d matches the generated one", cache.epoch)
+		return
+	}
+	glog.V(logger.Error).Infof("Downloaded cache for epoch %d is wrong, check its pinned checksum", cache.epoch)
+	cache.bootstrap.setWrong(cache.epoch)
+	atomic.StoreInt32(&cache.bad, 1)
+	shared.evictCache(cache)
+	if cache.dir != "" {
+		path := filepath.Join(cache.dir, cacheName(makeSeedHash(cache.epoch)))
+		os.Remove(path)
+		os.Remove(path + checksumSuffix)
+	}
+}
@@ -0,0 +1,108 @@
+package ethash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCacheBootstrap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := &cache{epoch: 2, test: true}
+	c.generate()
+	defer freeCache(c)
+	want := append([]byte(nil), (*[1 << 30]byte)(unsafe.Pointer(c.ptr.cache))[:c.size]...)
+	if err := writeCacheFile(filepath.Join(dir, cacheName(makeSeedHash(2))), c); err != nil {
+		t.Fatal(err)
+	}
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.FileServer(http.Dir(dir)).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	b := &CacheBootstrap{
+		URLs: []string{srv.URL + "/missing", srv.URL},
+		Pins: map[uint64]common.Hash{2: sha256.Sum256(want)},
+	}
+	fetched := &cache{epoch: 2, test: true, bootstrap: b}
+	fetched.refs.acquire()
+	fetched.generate()
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("made %d requests, want 2", n)
+	}
+	if have := (*[1 << 30]byte)(unsafe.Pointer(fetched.ptr.cache))[:fetched.size]; !bytes.Equal(have, want) {
+		t.Error("downloaded cache does not match")
+	}
+	fetched.release()
+
+	// a cache not matching its pin is not used.
+	b.Pins[2] = common.Hash{1}
+	atomic.StoreInt32(&requests, 0)
+	generated := &cache{epoch: 2, test: true, bootstrap: b}
+	generated.generate()
+	defer freeCache(generated)
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("made %d requests, want 2", n)
+	}
+	if have := (*[1 << 30]byte)(unsafe.Pointer(generated.ptr.cache))[:generated.size]; !bytes.Equal(have, want) {
+		t.Error("generated cache does not match")
+	}
+}
+
+func TestCacheBootstrapWrongPin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// serve the cache of another epoch, pinned as if it was right.
+	c := &cache{epoch: 3, test: true}
+	c.generate()
+	defer freeCache(c)
+	data := (*[1 << 30]byte)(unsafe.Pointer(c.ptr.cache))[:c.size]
+	if err := writeCacheFile(filepath.Join(dir, cacheName(makeSeedHash(4))), c); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	l := &Light{test: true}
+	l.SetCacheBootstrap(&CacheBootstrap{
+		URLs: []string{srv.URL},
+		Pins: map[uint64]common.Hash{4: sha256.Sum256(data)},
+	})
+	bad, _, _ := l.getCache(4 * epochLength)
+	defer bad.release()
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadInt32(&bad.bad) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("wrong download not detected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	good, _, _ := l.getCache(4 * epochLength)
+	defer good.release()
+	if good == bad {
+		t.Fatal("wrong cache still in use")
+	}
+	if bytes.Equal((*[1 << 30]byte)(unsafe.Pointer(good.ptr.cache))[:good.size], data) {
+		t.Error("cache downloaded again")
+	}
+	l.FreeCache()
+}
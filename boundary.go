@@ -0,0 +1,64 @@
+package ethash
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// boundaryDifficulty keeps mining the blocks of CrossEpochBoundary fast.
+var boundaryDifficulty = big.NewInt(10)
+
+// CrossEpochBoundary drives engine across the start of the given epoch,
+// which must be at least 1, and returns the first problem it finds. It
+// moves the head of chain to two blocks before the boundary, then mines
+// the blocks up to two past it with engine at a low difficulty, moving
+// the head along. Each block must verify, must not verify as a block of
+// the other epoch, and its mix digest must match ComputeMixDigest.
+// Going back, the blocks before the boundary must still verify, as
+// after a reorg.
+//
+// The blocks are mined with engine's DAGs, so unless it is a testing
+// instance this generates two full-size DAGs. Tests of code using the
+// engine can run it to check the cache and DAG swap.
+func CrossEpochBoundary(engine *Ethash, chain *FakeChain, epoch uint64) error {
+	if epoch == 0 || epoch >= maxEpoch {
+		return fmt.Errorf("epoch %d has no boundary to cross, want 1 to %d", epoch, maxEpoch-1)
+	}
+	first := epoch * epochLength
+	var blocks []*FakeBlock
+	for n := first - 2; n < first+2; n++ {
+		chain.SetHead(n - 1)
+		block := chain.Block(n)
+		block.difficulty = new(big.Int).Set(boundaryDifficulty)
+		if !block.Mine(engine, nil) {
+			return fmt.Errorf("block %d: search stopped", n)
+		}
+		chain.SetHead(n)
+		if !engine.Verify(block) {
+			return fmt.Errorf("block %d: mined seal did not verify", n)
+		}
+		other := first
+		if n >= first {
+			other = first - 1
+		}
+		moved := NewFakeBlock(other, block.HashNoNonce(), block.Difficulty())
+		moved.SetSeal(block.Nonce(), block.MixDigest())
+		if engine.VerifySeal(moved) {
+			return fmt.Errorf("block %d: seal verified as a block of epoch %d", n, other/epochLength)
+		}
+		mixDigest, _, err := engine.ComputeMixDigest(n/epochLength, block.HashNoNonce(), block.Nonce())
+		if err != nil {
+			return fmt.Errorf("block %d: %v", n, err)
+		}
+		if mixDigest != block.MixDigest() {
+			return fmt.Errorf("block %d: ComputeMixDigest returned %x, mined %x", n, mixDigest, block.MixDigest())
+		}
+		blocks = append(blocks, block)
+	}
+	for _, block := range blocks[:2] {
+		if !engine.Verify(block) {
+			return fmt.Errorf("block %d: did not verify after crossing into epoch %d", block.NumberU64(), epoch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package ethash
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCrossEpochBoundary(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+
+	chain := NewFakeChain(0, boundaryDifficulty)
+	eth.SetBlockProvider(chain)
+	if err := CrossEpochBoundary(eth, chain, 1); err != nil {
+		t.Error(err)
+	}
+	if head := chain.CurrentBlockNumber(); head != epochLength+1 {
+		t.Errorf("head at %d after crossing, want %d", head, epochLength+1)
+	}
+	if err := CrossEpochBoundary(eth, chain, 0); err == nil {
+		t.Error("no error for epoch 0")
+	}
+}
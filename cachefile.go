@@ -0,0 +1,150 @@
+package ethash
+
+/*
+#include <stdlib.h>
+#include "src/libethash/internal.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// cacheName returns the name of the cache file for the given seed hash.
+// Cache files follow the DAG file naming and layout: a magic number
+// followed by the raw cache contents, with the chunk checksums in a
+// separate file.
+func cacheName(seedHash common.Hash) string {
+	return fmt.Sprintf("cache-R%d-%x", C.ETHASH_REVISION, seedHash[:8])
+}
+
+// MakeCache generates the verification cache for the given epoch and
+// stores it in dir. If dir is the empty string, the default directory
+// is used.
+func MakeCache(epoch uint64, dir string) error {
+	if epoch >= maxEpoch {
+		return fmt.Errorf("epoch number too high, limit is %d", maxEpoch)
+	}
+	if dir == "" {
+		dir = DefaultDir
+	}
+	if err := checkDir(dir); err != nil {
+		return err
+	}
+	c := newCache(epoch, false, cacheConfig{})
+	defer c.release()
+	if c.generate(); c.err != nil {
+		return c.err
+	}
+	return writeCacheFile(filepath.Join(dir, cacheName(makeSeedHash(epoch))), c)
+}
+
+// writeCacheFile stores the contents of c and their checksums at path.
+// The file is written under a temporary name first and renamed into
+// place when complete, so readers never observe a partial cache. The
+// checksum file is written before the rename, a complete cache file
+// always has one.
+func writeCacheFile(path string, c *cache) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	magic := uint64(C.ETHASH_DAG_MAGIC_NUM)
+	data, err := cBytes(unsafe.Pointer(c.ptr.cache), c.size)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	_, err = f.Write((*[dagMagicSize]byte)(unsafe.Pointer(&magic))[:])
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	runtime.KeepAlive(c)
+	if err == nil {
+		var sums *dagChecksums
+		if sums, err = computeDAGChecksums(f, c.size, dagChunkSize); err == nil {
+			err = writeChecksumFile(path, sums)
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// loadCacheFile reads the cache of the given epoch from its file in
+// dir into C memory, which is freed with ethash_light_delete. It fails
+// if the file doesn't exist, has the wrong size or doesn't match its
+// checksums.
+func loadCacheFile(dir string, epoch, size uint64) (*C.struct_ethash_light, error) {
+	path := filepath.Join(dir, cacheName(makeSeedHash(epoch)))
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := checkDAGHeader(f, size); err != nil {
+		return nil, err
+	}
+	sums, err := readDAGChecksums(path)
+	if err != nil {
+		return nil, err
+	}
+	if want := (size + sums.chunkSize - 1) / sums.chunkSize; uint64(len(sums.sums)) != want {
+		return nil, fmt.Errorf("cache checksum file has %d chunks, want %d", len(sums.sums), want)
+	}
+
+	light, data, err := allocLight(size)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.ReadAt(data, dagMagicSize)
+	for i := 0; err == nil && i < len(sums.sums); i++ {
+		start := uint64(i) * sums.chunkSize
+		if crc32.ChecksumIEEE(data[start:start+min64(sums.chunkSize, size-start)]) != sums.sums[i] {
+			err = fmt.Errorf("cache chunk %d does not match its checksum", i)
+		}
+	}
+	if err != nil {
+		C.ethash_light_delete(light)
+		return nil, err
+	}
+	return light, nil
+}
+
+// allocLight allocates an uninitialized cache of the given size in C
+// memory, which is freed with ethash_light_delete. data refers to the
+// cache contents.
+func allocLight(size uint64) (light *C.struct_ethash_light, data []byte, err error) {
+	if size > maxCBytes {
+		return nil, nil, fmt.Errorf("cache size %d too large", size)
+	}
+	light = (*C.struct_ethash_light)(C.calloc(1, C.size_t(unsafe.Sizeof(C.struct_ethash_light{}))))
+	if light == nil {
+		return nil, nil, errors.New("out of memory")
+	}
+	if light.cache = C.malloc(C.size_t(size)); light.cache == nil {
+		C.free(unsafe.Pointer(light))
+		return nil, nil, errors.New("out of memory")
+	}
+	light.cache_size = C.uint64_t(size)
+	data, _ = cBytes(light.cache, size)
+	return light, data, nil
+}
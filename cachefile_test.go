@@ -0,0 +1,165 @@
+package ethash
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func TestWriteCacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &cache{epoch: 1, test: true}
+	c.generate()
+	defer freeCache(c)
+	path := filepath.Join(dir, cacheName(makeSeedHash(1)))
+	if err := writeCacheFile(path, c); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(content)) != dagMagicSize+c.size {
+		t.Fatalf("cache file has %d bytes, want %d", len(content), dagMagicSize+c.size)
+	}
+	want := (*[1 << 30]byte)(unsafe.Pointer(c.ptr.cache))[:c.size]
+	if !bytes.Equal(content[dagMagicSize:], want) {
+		t.Error("cache file content does not match the cache")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 2 {
+		t.Errorf("found %d files in cache directory, want the cache and its checksums", len(files))
+	}
+}
+
+func TestLoadCacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// generating a cache with a directory stores it.
+	c := &cache{epoch: 1, test: true, dir: dir}
+	c.generate()
+	defer freeCache(c)
+	want := (*[1 << 30]byte)(unsafe.Pointer(c.ptr.cache))[:c.size]
+	light, err := loadCacheFile(dir, 1, c.size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &cache{epoch: 1, test: true}
+	loaded.setPtr(light, c.size)
+	defer freeCache(loaded)
+	if have := (*[1 << 30]byte)(unsafe.Pointer(loaded.ptr.cache))[:loaded.size]; !bytes.Equal(have, want) {
+		t.Error("loaded cache does not match the generated cache")
+	}
+
+	// a corrupt file is detected and replaced.
+	path := filepath.Join(dir, cacheName(makeSeedHash(1)))
+	content, _ := ioutil.ReadFile(path)
+	content[len(content)-1] ^= 0xff
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCacheFile(dir, 1, c.size); err == nil {
+		t.Fatal("corrupt cache file loaded without error")
+	}
+	c2 := &cache{epoch: 1, test: true, dir: dir}
+	c2.generate()
+	defer freeCache(c2)
+	if have := (*[1 << 30]byte)(unsafe.Pointer(c2.ptr.cache))[:c2.size]; !bytes.Equal(have, want) {
+		t.Error("regenerated cache does not match")
+	}
+	if _, err := loadCacheFile(dir, 1, c.size); err != nil {
+		t.Errorf("cache file not replaced: %v", err)
+	}
+}
+
+func TestLightVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := MakeCache(0, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, cacheName(makeSeedHash(0))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := validBlocks[0]
+	for _, cacheData := range [][]byte{data, data[dagMagicSize:]} {
+		if ok, err := LightVerify(cacheData, block); !ok || err != nil {
+			t.Errorf("valid block with %d cache bytes: got %v, %v", len(cacheData), ok, err)
+		}
+	}
+	wrong := *block
+	wrong.nonce++
+	if ok, _ := LightVerify(data, &wrong); ok {
+		t.Error("block with a changed nonce verified")
+	}
+	if _, err := LightVerify(data[:len(data)-1], block); err == nil {
+		t.Error("no error for a truncated cache")
+	}
+	bad := append([]byte(nil), data...)
+	bad[0]++
+	if _, err := LightVerify(bad, block); err == nil {
+		t.Error("no error for a cache file without magic number")
+	}
+	if _, err := LightVerify(data, validBlocks[1]); err == nil {
+		t.Error("no error for the cache of another epoch")
+	}
+}
+
+func TestCacheMarshalBinary(t *testing.T) {
+	f, err := NewFixture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	data, err := f.Cache.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := new(Cache)
+	if err := c.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	c.cache.refs.mu.Lock()
+	if c.cache.refs.n != 1 {
+		t.Errorf("unmarshaled cache has %d references, want 1", c.cache.refs.n)
+	}
+	c.cache.refs.mu.Unlock()
+	if c.Epoch() != f.Cache.Epoch() {
+		t.Errorf("epoch %d after unmarshaling, want %d", c.Epoch(), f.Cache.Epoch())
+	}
+	for _, seal := range f.Seals {
+		if mix, result := c.Hash(FixtureHeaderHash, seal.Nonce); mix != seal.MixDigest || result != seal.Result {
+			t.Errorf("nonce %x: unmarshaled cache computed %x, %x, want %x, %x", seal.Nonce, mix, result, seal.MixDigest, seal.Result)
+		}
+	}
+	if again, _ := c.MarshalBinary(); !bytes.Equal(again, data) {
+		t.Error("marshaling the unmarshaled cache gave different bytes")
+	}
+
+	if err := c.UnmarshalBinary(data); err == nil {
+		t.Error("no error unmarshaling into a cache in use")
+	}
+	for i, bad := range [][]byte{nil, data[:len(data)-1], append([]byte{2}, data[1:]...)} {
+		if err := new(Cache).UnmarshalBinary(bad); err == nil {
+			t.Errorf("bad data %d: no error", i)
+		}
+	}
+}
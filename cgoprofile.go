@@ -0,0 +1,100 @@
+package ethash
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// CgoStats describes the calls of one libethash function.
+type CgoStats struct {
+	Calls uint64
+	Time  time.Duration // total time spent in the calls
+}
+
+// Mean returns the average duration of a call.
+func (s CgoStats) Mean() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Time / time.Duration(s.Calls)
+}
+
+// CgoProfile holds the calls into libethash made while profiling was
+// enabled, see EnableCgoProfiling.
+type CgoProfile struct {
+	LightNew     CgoStats // cache generation
+	LightCompute CgoStats // hashimoto with the cache, used by Verify
+	FullNew      CgoStats // DAG generation or loading
+	FullCompute  CgoStats // hashimoto with the DAG, used by Search
+	DatasetItem  CgoStats // single dataset item computations
+}
+
+// cgoCounter accumulates the calls of one function. It is updated
+// atomically.
+type cgoCounter struct {
+	calls uint64
+	nanos uint64
+}
+
+var (
+	cgoProfiling int32 // set atomically by EnableCgoProfiling
+	cgoCalls     struct {
+		lightNew, lightCompute, fullNew, fullCompute, datasetItem cgoCounter
+	}
+)
+
+// EnableCgoProfiling turns counting and timing of the calls into
+// libethash on or off. It is off by default, as taking the time costs
+// noticeably compared to a single hash.
+func EnableCgoProfiling(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&cgoProfiling, v)
+}
+
+// GetCgoProfile returns the calls into libethash counted so far by all
+// instances in the process.
+func GetCgoProfile() CgoProfile {
+	return CgoProfile{
+		LightNew:     cgoCalls.lightNew.stats(),
+		LightCompute: cgoCalls.lightCompute.stats(),
+		FullNew:      cgoCalls.fullNew.stats(),
+		FullCompute:  cgoCalls.fullCompute.stats(),
+		DatasetItem:  cgoCalls.datasetItem.stats(),
+	}
+}
+
+// ResetCgoProfile sets all counters to zero.
+func ResetCgoProfile() {
+	for _, c := range []*cgoCounter{&cgoCalls.lightNew, &cgoCalls.lightCompute, &cgoCalls.fullNew, &cgoCalls.fullCompute, &cgoCalls.datasetItem} {
+		atomic.StoreUint64(&c.calls, 0)
+		atomic.StoreUint64(&c.nanos, 0)
+	}
+}
+
+// begin is called before a C call. It returns the zero time if
+// profiling is disabled.
+func (c *cgoCounter) begin() time.Time {
+	if atomic.LoadInt32(&cgoProfiling) == 0 {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// end records a call that started at the time returned by begin.
+func (c *cgoCounter) end(start time.Time) {
+	if start.IsZero() {
+		return
+	}
+	atomic.AddUint64(&c.calls, 1)
+	atomic.AddUint64(&c.nanos, uint64(time.Since(start)))
+}
+
+func (c *cgoCounter) stats() CgoStats {
+	return CgoStats{
+		Calls: atomic.LoadUint64(&c.calls),
+		Time:  time.Duration(atomic.LoadUint64(&c.nanos)),
+	}
+}
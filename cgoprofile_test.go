@@ -0,0 +1,68 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"testing"
+)
+
+func TestCgoProfile(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	eth.SetLookahead(NoLookaheadLimit)
+
+	// calls are not counted while profiling is disabled.
+	ResetCgoProfile()
+	block := &testBlock{number: 13 * epochLength, difficulty: big.NewInt(10)}
+	block.seal(eth.Search(block, nil))
+	if p := GetCgoProfile(); p.FullCompute.Calls != 0 || p.FullNew.Calls != 0 {
+		t.Fatalf("calls counted with profiling disabled: %+v", p)
+	}
+
+	EnableCgoProfiling(true)
+	defer EnableCgoProfiling(false)
+	if !eth.Verify(block) {
+		t.Fatal("mined block did not verify")
+	}
+	eth.Search(block, nil)
+	p := GetCgoProfile()
+	if p.LightCompute.Calls != 1 {
+		t.Errorf("got %d light compute calls, want 1", p.LightCompute.Calls)
+	}
+	if p.FullCompute.Calls == 0 || p.FullCompute.Time <= 0 {
+		t.Errorf("full compute calls not recorded: %+v", p.FullCompute)
+	}
+	if p.FullCompute.Mean() > p.FullCompute.Time {
+		t.Errorf("mean %v exceeds total %v", p.FullCompute.Mean(), p.FullCompute.Time)
+	}
+	ResetCgoProfile()
+	if p := GetCgoProfile(); p != (CgoProfile{}) {
+		t.Errorf("profile not reset: %+v", p)
+	}
+
+	// calls through Dataset and Cache are counted too, batches once.
+	ds, err := eth.Full.Dataset(block.number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Release()
+	cache, err := ethashAlgorithm{test: true}.NewCache(13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces := []uint64{1, 2, 3}
+	ds.Hash(block.hashNoNonce, block.nonce)
+	ds.HashBatch(block.hashNoNonce, nonces)
+	cache.Hash(block.hashNoNonce, block.nonce)
+	cache.(*Cache).HashBatch(block.hashNoNonce, nonces)
+	p = GetCgoProfile()
+	if p.FullCompute.Calls != 2 {
+		t.Errorf("got %d full compute calls through Dataset, want 2", p.FullCompute.Calls)
+	}
+	if p.LightCompute.Calls != 2 {
+		t.Errorf("got %d light compute calls through Cache, want 2", p.LightCompute.Calls)
+	}
+}
@@ -0,0 +1,144 @@
+package ethash
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// Checksums of DAG files are kept in a separate file next to the DAG,
+// so that the DAG file format stays compatible with the C and Python
+// implementations, which map the dataset right after the magic number
+// of the header. The checksum file holds the chunk size as a little
+// endian uint64, followed by the CRC-32 (IEEE) of every chunk of the
+// dataset, not including the magic number. Cache files use the same
+// scheme.
+const checksumSuffix = ".crc"
+
+// dagChunkSize is the number of dataset bytes covered by one checksum.
+// It is a multiple of dagItemSize.
+var dagChunkSize uint64 = 1 << 20
+
+// dagChecksums are the chunk checksums of a DAG file.
+type dagChecksums struct {
+	chunkSize uint64
+	sums      []uint32
+}
+
+// writeChecksums writes the checksum file of the DAG file of d,
+// replacing any previous one. It must only be called for DAG files that
+// were just generated: checksums of a file that was only spot-checked
+// would make corrupt chunks look intact to repairDAGFile.
+func (d *dag) writeChecksums() error {
+	path := filepath.Join(d.dir, dagName(makeSeedHash(d.epoch)))
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sums, err := computeDAGChecksums(f, datasetSize(d.epoch, d.test), dagChunkSize)
+	if err != nil {
+		return err
+	}
+	return writeChecksumFile(path, sums)
+}
+
+// writeChecksumFile stores sums as the checksum file of the DAG or
+// cache file at path.
+func writeChecksumFile(path string, sums *dagChecksums) error {
+	buf := make([]byte, 8+4*len(sums.sums))
+	binary.LittleEndian.PutUint64(buf, sums.chunkSize)
+	for i, sum := range sums.sums {
+		binary.LittleEndian.PutUint32(buf[8+4*i:], sum)
+	}
+	tmp := path + checksumSuffix + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path+checksumSuffix)
+}
+
+// computeDAGChecksums computes the chunk checksums of the DAG file f.
+func computeDAGChecksums(f *os.File, dagSize, chunkSize uint64) (*dagChecksums, error) {
+	sums := &dagChecksums{chunkSize: chunkSize}
+	r := io.NewSectionReader(f, dagMagicSize, int64(dagSize))
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			sums.sums = append(sums.sums, crc32.ChecksumIEEE(buf[:n]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sums, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readDAGChecksums reads the checksum file of the DAG file at path.
+func readDAGChecksums(path string) (*dagChecksums, error) {
+	buf, err := ioutil.ReadFile(path + checksumSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 8 || (len(buf)-8)%4 != 0 {
+		return nil, errors.New("malformed DAG checksum file")
+	}
+	sums := &dagChecksums{chunkSize: binary.LittleEndian.Uint64(buf)}
+	if sums.chunkSize == 0 || sums.chunkSize%dagItemSize != 0 {
+		return nil, fmt.Errorf("invalid DAG checksum chunk size %d", sums.chunkSize)
+	}
+	for i := 8; i < len(buf); i += 4 {
+		sums.sums = append(sums.sums, binary.LittleEndian.Uint32(buf[i:]))
+	}
+	return sums, nil
+}
+
+// repairDAGFile checks every chunk of the DAG file f and recomputes the
+// chunks that don't match their checksum from cache. An error is
+// returned if a repaired chunk still doesn't match, which means the
+// checksums themselves are wrong.
+func repairDAGFile(f *os.File, cache *cache, dagSize uint64, sums *dagChecksums) error {
+	if want := (dagSize + sums.chunkSize - 1) / sums.chunkSize; uint64(len(sums.sums)) != want {
+		return fmt.Errorf("DAG checksum file has %d chunks, want %d", len(sums.sums), want)
+	}
+	buf := make([]byte, sums.chunkSize)
+	for i, sum := range sums.sums {
+		start := uint64(i) * sums.chunkSize
+		chunk := buf[:min64(sums.chunkSize, dagSize-start)]
+		if _, err := f.ReadAt(chunk, int64(dagMagicSize+start)); err != nil {
+			return err
+		}
+		if crc32.ChecksumIEEE(chunk) == sum {
+			continue
+		}
+		glog.V(logger.Warn).Infof("Repairing DAG chunk %d of epoch %d", i, cache.epoch)
+		for off := uint64(0); off < uint64(len(chunk)); off += dagItemSize {
+			calcDatasetItem(cache, itemIndex((start+off)/dagItemSize), chunk[off:])
+		}
+		if crc32.ChecksumIEEE(chunk) != sum {
+			return fmt.Errorf("recomputed DAG chunk %d does not match its checksum", i)
+		}
+		if _, err := f.WriteAt(chunk, int64(dagMagicSize+start)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,44 @@
+package ethash
+
+import "time"
+
+// Clock is the source of time of a Full: for its hash rate, pacing,
+// search hook, nonce seeding and the throttling of the DAGs it
+// generates. Tests can control time by setting their own with
+// SetClock.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// systemClock is the Clock used unless another one is set.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// orSystem returns c, or the system clock if c is nil.
+func orSystem(c Clock) Clock {
+	if c == nil {
+		return systemClock{}
+	}
+	return c
+}
+
+// SetClock sets the clock used by pow. Nil selects the system clock,
+// which is the default. DAGs already generated or shared with other
+// instances keep the clock they were created with.
+func (pow *Full) SetClock(c Clock) {
+	pow.mu.Lock()
+	pow.clock = c
+	pow.mu.Unlock()
+	pow.hashrate.setClock(c)
+	pow.pacer.setClock(c)
+}
+
+// getClock returns the clock of pow.
+func (pow *Full) getClock() Clock {
+	pow.mu.Lock()
+	defer pow.mu.Unlock()
+	return orSystem(pow.clock)
+}
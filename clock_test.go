@@ -0,0 +1,114 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only advances when told to or slept on.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1500000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.slept += d
+	c.mu.Unlock()
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestHashrateFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	var m hashrateMeter
+	m.setClock(clock)
+	m.start()
+	defer m.stop()
+
+	clock.advance(2 * time.Second)
+	m.mark(1000)
+	if r := m.rate(); r != 500 {
+		t.Errorf("rate after 1000 hashes in 2s: got %v, want 500", r)
+	}
+	// samples leave the window as the clock moves on.
+	for i := 0; i < 3*int(DefaultHashrateWindow/time.Second); i++ {
+		clock.advance(time.Second)
+		m.rate()
+	}
+	m.mark(100)
+	clock.advance(time.Second)
+	if r, want := m.rate(), 100/(DefaultHashrateWindow+time.Second).Seconds(); r < want*0.9 || r > want*1.1 {
+		t.Errorf("rate after the window moved: got %v, want about %v", r, want)
+	}
+}
+
+func TestPacerFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	var p pacer
+	p.setClock(clock)
+	p.setInterval(time.Second)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		p.wait()
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("100 tokens at 1s took %v of real time with a fake clock", elapsed)
+	}
+	if clock.slept < 98*time.Second || clock.slept > 100*time.Second {
+		t.Errorf("slept %v for 100 tokens at 1s, want about 99s", clock.slept)
+	}
+}
+
+func TestThrottleFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	d := &dag{test: true, limits: GenerationLimits{CPUShare: 0.25}, clock: clock}
+	d.throttle()
+	clock.advance(time.Second)
+	d.throttle()
+	if clock.slept != 3*time.Second {
+		t.Errorf("slept %v after a 1s step at a quarter core, want 3s", clock.slept)
+	}
+}
+
+func TestSearchHookFakeClock(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	// without pacing, nothing advances the clock.
+	eth.Turbo(true)
+	eth.SetClock(newFakeClock())
+	eth.SetSearchHook(1, func(worker int, n uint64, elapsed time.Duration) {
+		if elapsed != 0 {
+			t.Errorf("hook saw %v elapsed on a stopped clock", elapsed)
+		}
+	})
+	first, _ := eth.Search(&testBlock{difficulty: big.NewInt(100)}, nil)
+	// nor are the nonces seeded from it.
+	if second, _ := eth.Search(&testBlock{difficulty: big.NewInt(100)}, nil); second == first {
+		t.Errorf("searches on a stopped clock both found nonce %d", first)
+	}
+}
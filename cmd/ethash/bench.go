@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// hasher computes the seal of a header hash for one nonce.
+type hasher func(headerHash common.Hash, nonce uint64) (mixDigest, result common.Hash)
+
+func bench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	light := fs.Bool("light", false, "hash with the verification cache")
+	full := fs.Bool("full", false, "hash with the full DAG (default)")
+	report := fs.Bool("report", false, "run all benchmarks and print a JSON report, each hashing benchmark runs for the duration")
+	threads := fs.Int("threads", runtime.NumCPU(), "number of hashing threads")
+	duration := fs.Duration("duration", 60*time.Second, "how long to hash for")
+	number := fs.Uint64("number", 0, "block number selecting the epoch")
+	dir := fs.String("dir", ethash.DefaultDir, "directory holding the DAG files")
+	fs.Parse(args)
+	if fs.NArg() != 0 || (*light && *full) || (*report && (*light || *full)) || *threads < 1 || *duration <= 0 {
+		return errUsage
+	}
+	if *report {
+		return benchReport(*threads, *duration, *number, *dir)
+	}
+
+	var hash hasher
+	mode := "full"
+	if *light {
+		mode = "light"
+		alg, err := ethash.GetAlgorithm("ethash")
+		if err != nil {
+			return err
+		}
+		cache, err := alg.NewCache(*number / alg.EpochLength())
+		if err != nil {
+			return err
+		}
+		hash = cache.Hash
+	} else {
+		ds, err := (&ethash.Full{Dir: *dir}).Dataset(*number)
+		if err != nil {
+			return err
+		}
+		defer ds.Release()
+		hash = ds.Hash
+	}
+
+	fmt.Printf("hashing in %s mode with %d threads for %v\n", mode, *threads, *duration)
+	counts, elapsed := hashFor(hash, *threads, *duration)
+	var total uint64
+	for i, n := range counts {
+		fmt.Printf("thread %d: %.0f H/s\n", i, float64(n)/elapsed)
+		total += n
+	}
+	mem := ethash.MemoryStats()
+	fmt.Printf("total: %.0f H/s\n", float64(total)/elapsed)
+	fmt.Printf("memory: %d bytes (caches %d, DAGs %d)\n", mem.Total(), sum(mem.Caches), sum(mem.DAGs))
+	return nil
+}
+
+// hashFor runs hash on the given number of threads for duration. It
+// returns the number of hashes per thread and the seconds taken.
+func hashFor(hash hasher, threads int, duration time.Duration) ([]uint64, float64) {
+	counts := make([]uint64, threads)
+	deadline := time.Now().Add(duration)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			header := common.Hash{byte(i), byte(i >> 8)}
+			var n uint64
+			for ; time.Now().Before(deadline); n++ {
+				hash(header, n)
+			}
+			counts[i] = n
+		}(i)
+	}
+	wg.Wait()
+	return counts, time.Since(start).Seconds()
+}
+
+// platformReport describes the machine and the results of bench -report,
+// for comparing hardware.
+type platformReport struct {
+	Host     string `json:"host"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Go       string `json:"go"`
+	CPU      string `json:"cpu,omitempty"` // model name, if known
+	CPUs     int    `json:"cpus"`
+	Sockets  int    `json:"sockets"`
+	Threads  int    `json:"threads"`
+	Epoch    uint64 `json:"epoch"`
+	Duration string `json:"duration"` // of each hashing benchmark
+
+	CacheGeneration float64    `json:"cacheGenerationSeconds"`
+	Light           hashReport `json:"light"`
+	Full            hashReport `json:"full"`
+	Memory          uint64     `json:"memoryBytes"`
+}
+
+// hashReport holds the hash rates of a mode in hashes per second.
+type hashReport struct {
+	PerCore   float64 `json:"perCore"`   // of a single thread
+	PerSocket float64 `json:"perSocket"` // of all threads, divided by the sockets
+	Total     float64 `json:"total"`     // of all threads
+}
+
+// benchReport generates the cache, hashes with it and with the DAG,
+// first on one thread and then on all of them, and prints the JSON
+// report.
+func benchReport(threads int, duration time.Duration, number uint64, dir string) error {
+	host, _ := os.Hostname()
+	model, sockets := cpuInfo()
+	r := platformReport{
+		Host:     host,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Go:       runtime.Version(),
+		CPU:      model,
+		CPUs:     runtime.NumCPU(),
+		Sockets:  sockets,
+		Threads:  threads,
+		Duration: duration.String(),
+	}
+	alg, err := ethash.GetAlgorithm("ethash")
+	if err != nil {
+		return err
+	}
+	r.Epoch = number / alg.EpochLength()
+
+	fmt.Fprintf(os.Stderr, "generating the cache for epoch %d\n", r.Epoch)
+	start := time.Now()
+	cache, err := alg.NewCache(r.Epoch)
+	if err != nil {
+		return err
+	}
+	r.CacheGeneration = time.Since(start).Seconds()
+	fmt.Fprintln(os.Stderr, "hashing in light mode")
+	r.Light = hashRates(cache.Hash, threads, sockets, duration)
+
+	ds, err := (&ethash.Full{Dir: dir}).Dataset(number)
+	if err != nil {
+		return err
+	}
+	defer ds.Release()
+	fmt.Fprintln(os.Stderr, "hashing in full mode")
+	r.Full = hashRates(ds.Hash, threads, sockets, duration)
+	r.Memory = ethash.MemoryStats().Total()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// hashRates measures hash on one thread, then on the given number.
+func hashRates(hash hasher, threads, sockets int, duration time.Duration) hashReport {
+	counts, elapsed := hashFor(hash, 1, duration)
+	r := hashReport{PerCore: float64(counts[0]) / elapsed}
+	counts, elapsed = hashFor(hash, threads, duration)
+	var total uint64
+	for _, n := range counts {
+		total += n
+	}
+	r.Total = float64(total) / elapsed
+	r.PerSocket = r.Total / float64(sockets)
+	return r
+}
+
+// cpuInfo returns the CPU model and the number of sockets, read from
+// /proc/cpuinfo where available. It assumes a single socket otherwise.
+func cpuInfo() (model string, sockets int) {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return "", 1
+	}
+	defer f.Close()
+	ids := make(map[string]bool)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		kv := strings.SplitN(s.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "model name":
+			if model == "" {
+				model = value
+			}
+		case "physical id":
+			ids[value] = true
+		}
+	}
+	if len(ids) == 0 {
+		return model, 1
+	}
+	return model, len(ids)
+}
+
+func sum(m map[uint64]uint64) uint64 {
+	var total uint64
+	for _, n := range m {
+		total += n
+	}
+	return total
+}
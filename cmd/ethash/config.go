@@ -0,0 +1,258 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/ethash/remote"
+)
+
+// config holds the settings of the serve command. It is read from a
+// JSON file, e.g.
+//
+//	{
+//		"threads": 4,
+//		"dagDir": "/var/lib/ethash",
+//		"pools": [
+//			{"url": "http://node1:8545", "priority": 0},
+//			{"url": "http://node2:8545", "priority": 1}
+//		],
+//		"throttle": {"cpuShare": 0.5, "writeRate": 10485760},
+//		"hashrateWindow": "30s",
+//		"tls": {"certFile": "/etc/ethash/cert.pem", "keyFile": "/etc/ethash/key.pem"},
+//		"tokens": {"3f9c2a...": "rig1", "b71e04...": "rig2"},
+//		"limits": {"allow": ["10.0.0.0/8"], "connsPerIP": 8, "submitRate": 2, "submitBurst": 20},
+//		"metricsAddr": "127.0.0.1:9100"
+//	}
+type config struct {
+	Threads         int               `json:"threads"`         // local mining threads, 0 disables mining
+	DAGDir          string            `json:"dagDir"`          // directory of the DAG files
+	DatasetsInMem   int               `json:"datasetsInMem"`   // DAGs kept in memory, more than a gigabyte each
+	GPUDAG          bool              `json:"gpuDAG"`          // generate DAGs on the GPU, needs the opencl build tag
+	Pools           []poolConfig      `json:"pools"`           // upstream nodes providing work
+	Throttle        throttleConfig    `json:"throttle"`        // limits for background DAG generation
+	HashrateWindow  duration          `json:"hashrateWindow"`  // period the reported hash rate is averaged over
+	HashrateSample  duration          `json:"hashrateSample"`  // minimum time between two hash rate samples
+	MetricsAddr     string            `json:"metricsAddr"`     // address serving metrics, empty to disable
+	StatusFile      string            `json:"statusFile"`      // file the status is written to periodically, empty to disable
+	HTTPAddr        string            `json:"httpAddr"`        // address serving getWork, empty to disable
+	StratumAddr     string            `json:"stratumAddr"`     // address serving stratum, empty to disable
+	TLS             tlsConfig         `json:"tls"`             // certificate for getWork and stratum, plain text if empty
+	Tokens          map[string]string `json:"tokens"`          // worker names by the token they authenticate with, open to anyone if empty
+	Limits          limitsConfig      `json:"limits"`          // limits for miners connecting to getWork and stratum
+	Poll            duration          `json:"poll"`            // how often to ask the upstream for work
+	SubmitStale     bool              `json:"submitStale"`     // forward solutions for replaced work
+	ShutdownTimeout duration          `json:"shutdownTimeout"` // how long to wait for threads and DAG writes on exit
+}
+
+// poolConfig is an upstream node. Pools with a lower priority value
+// are preferred, the others take over while it fails.
+type poolConfig struct {
+	URL      string `json:"url"`
+	Priority int    `json:"priority"`
+}
+
+// tlsConfig is the certificate the getWork and stratum endpoints are
+// served with. If ClientCAFile is set, miners must present a client
+// certificate signed by one of the CAs in it.
+type tlsConfig struct {
+	CertFile     string `json:"certFile"`     // PEM encoded certificate chain
+	KeyFile      string `json:"keyFile"`      // PEM encoded private key
+	ClientCAFile string `json:"clientCAFile"` // PEM encoded CA certificates, optional
+}
+
+// load returns the TLS configuration, nil if TLS is disabled.
+func (c tlsConfig) load() (*tls.Config, error) {
+	if c.CertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", c.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// limitsConfig holds the settings of remote.Limits. Allow lists
+// networks in CIDR notation or single addresses.
+type limitsConfig struct {
+	Allow       []string `json:"allow"`
+	ConnsPerIP  int      `json:"connsPerIP"`
+	SubmitRate  float64  `json:"submitRate"`
+	SubmitBurst int      `json:"submitBurst"`
+}
+
+// limits returns the limits, failing for invalid networks.
+func (c limitsConfig) limits() (remote.Limits, error) {
+	l := remote.Limits{ConnsPerIP: c.ConnsPerIP, SubmitRate: c.SubmitRate, SubmitBurst: c.SubmitBurst}
+	for _, a := range c.Allow {
+		if !strings.Contains(a, "/") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return l, fmt.Errorf("invalid address %q", a)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			l.Allow = append(l.Allow, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return l, err
+		}
+		l.Allow = append(l.Allow, n)
+	}
+	return l, nil
+}
+
+type throttleConfig struct {
+	CPUShare  float64 `json:"cpuShare"`  // fraction of one core
+	WriteRate uint64  `json:"writeRate"` // bytes per second
+}
+
+// duration is a time.Duration encoded as a string like "500ms".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func defaultConfig() config {
+	return config{
+		DAGDir:          ethash.DefaultDir,
+		DatasetsInMem:   1,
+		HTTPAddr:        "127.0.0.1:8545",
+		StratumAddr:     "127.0.0.1:8008",
+		Poll:            duration(remote.DefaultPollInterval),
+		HashrateWindow:  duration(ethash.DefaultHashrateWindow),
+		HashrateSample:  duration(ethash.DefaultHashrateSampleInterval),
+		ShutdownTimeout: duration(30 * time.Second),
+	}
+}
+
+// loadConfig reads the configuration file at path on top of the
+// defaults.
+func loadConfig(path string) (config, error) {
+	cfg := defaultConfig()
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := cfg.check(); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *config) check() error {
+	if cfg.Threads < 0 {
+		return fmt.Errorf("invalid thread count %d", cfg.Threads)
+	}
+	if cfg.DatasetsInMem < 1 {
+		return fmt.Errorf("invalid number of DAGs in memory %d", cfg.DatasetsInMem)
+	}
+	if s := cfg.Throttle.CPUShare; s < 0 || s > 1 {
+		return fmt.Errorf("CPU share %v not between 0 and 1", s)
+	}
+	if cfg.Poll <= 0 {
+		return fmt.Errorf("invalid poll interval %v", time.Duration(cfg.Poll))
+	}
+	if cfg.HashrateWindow <= 0 || cfg.HashrateSample <= 0 || cfg.HashrateSample > cfg.HashrateWindow {
+		return fmt.Errorf("invalid hash rate window %v sampled every %v", time.Duration(cfg.HashrateWindow), time.Duration(cfg.HashrateSample))
+	}
+	if cfg.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout %v", time.Duration(cfg.ShutdownTimeout))
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS needs both a certificate and a key file")
+	}
+	if cfg.TLS.ClientCAFile != "" && cfg.TLS.CertFile == "" {
+		return fmt.Errorf("client certificates need TLS")
+	}
+	if _, ok := cfg.Tokens[""]; ok {
+		return fmt.Errorf("empty token")
+	}
+	if _, err := cfg.Limits.limits(); err != nil {
+		return err
+	}
+	if l := cfg.Limits; l.ConnsPerIP < 0 || l.SubmitRate < 0 || l.SubmitBurst < 0 {
+		return fmt.Errorf("negative limit")
+	}
+	for _, p := range cfg.Pools {
+		if p.URL == "" {
+			return fmt.Errorf("pool without url")
+		}
+	}
+	return nil
+}
+
+// upstreams returns the URLs of the pools, the preferred one first.
+func (cfg *config) upstreams() []string {
+	pools := append([]poolConfig(nil), cfg.Pools...)
+	sort.SliceStable(pools, func(i, j int) bool { return pools[i].Priority < pools[j].Priority })
+	urls := make([]string, len(pools))
+	for i, p := range pools {
+		urls[i] = p.URL
+	}
+	return urls
+}
+
+// upstream returns the URL of the preferred pool.
+func (cfg *config) upstream() string {
+	if len(cfg.Pools) == 0 {
+		return ""
+	}
+	return cfg.upstreams()[0]
+}
+
+// newUpstream returns the upstream for the pools, failing over between
+// them in order of priority if there are several.
+func (cfg *config) newUpstream() remote.Upstream {
+	urls := cfg.upstreams()
+	if len(urls) == 1 {
+		return remote.NewRPCUpstream(urls[0])
+	}
+	upstreams := make([]remote.Upstream, len(urls))
+	for i, url := range urls {
+		upstreams[i] = remote.NewRPCUpstream(url)
+	}
+	return remote.NewFailoverUpstream(upstreams...)
+}
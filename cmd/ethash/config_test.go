@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a configuration file with the given contents and
+// returns its path.
+func writeConfig(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "ethash-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `{
+		"threads": 4,
+		"dagDir": "/var/lib/ethash",
+		"pools": [{"url": "http://node2:8545", "priority": 1}, {"url": "http://node1:8545"}],
+		"hashrateWindow": "30s",
+		"tokens": {"secret": "rig1"},
+		"limits": {"allow": ["10.0.0.0/8"], "connsPerIP": 8, "submitRate": 2, "submitBurst": 20}
+	}`)
+	defer os.Remove(path)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Threads != 4 || cfg.DAGDir != "/var/lib/ethash" || time.Duration(cfg.HashrateWindow) != 30*time.Second {
+		t.Errorf("settings of the file not loaded: %+v", cfg)
+	}
+	if def := defaultConfig(); cfg.HTTPAddr != def.HTTPAddr || cfg.Poll != def.Poll || cfg.DatasetsInMem != def.DatasetsInMem {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+	if want := []string{"http://node1:8545", "http://node2:8545"}; !reflect.DeepEqual(cfg.upstreams(), want) {
+		t.Errorf("got upstreams %v, want %v", cfg.upstreams(), want)
+	}
+	if cfg.Tokens["secret"] != "rig1" {
+		t.Errorf("got tokens %v", cfg.Tokens)
+	}
+	if l := cfg.Limits; l.ConnsPerIP != 8 || l.SubmitRate != 2 || l.SubmitBurst != 20 {
+		t.Errorf("got limits %+v", l)
+	}
+
+	if _, err := loadConfig(path + ".missing"); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	for _, test := range []struct {
+		name, contents string
+	}{
+		{"unknown key", `{"thread": 4}`},
+		{"negative threads", `{"threads": -1}`},
+		{"no DAGs in memory", `{"datasetsInMem": 0}`},
+		{"CPU share above 1", `{"throttle": {"cpuShare": 1.5}}`},
+		{"zero poll interval", `{"poll": "0s"}`},
+		{"invalid duration", `{"poll": "often"}`},
+		{"sample above window", `{"hashrateWindow": "10s", "hashrateSample": "20s"}`},
+		{"negative shutdown timeout", `{"shutdownTimeout": "-1s"}`},
+		{"certificate without key", `{"tls": {"certFile": "cert.pem"}}`},
+		{"client CA without TLS", `{"tls": {"clientCAFile": "ca.pem"}}`},
+		{"empty token", `{"tokens": {"": "rig1"}}`},
+		{"invalid network", `{"limits": {"allow": ["10.0.0.0/33"]}}`},
+		{"invalid address", `{"limits": {"allow": ["node1"]}}`},
+		{"negative limit", `{"limits": {"submitRate": -1}}`},
+		{"pool without url", `{"pools": [{"priority": 1}]}`},
+	} {
+		path := writeConfig(t, test.contents)
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+		os.Remove(path)
+	}
+}
+
+func TestLimitsConfig(t *testing.T) {
+	c := limitsConfig{Allow: []string{"10.0.0.0/8", "192.168.1.5", "::1", "fd00::/8"}, ConnsPerIP: 8, SubmitRate: 2, SubmitBurst: 20}
+	l, err := c.limits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var allow []string
+	for _, n := range l.Allow {
+		allow = append(allow, n.String())
+	}
+	if want := []string{"10.0.0.0/8", "192.168.1.5/32", "::1/128", "fd00::/8"}; !reflect.DeepEqual(allow, want) {
+		t.Errorf("got networks %v, want %v", allow, want)
+	}
+	if l.ConnsPerIP != 8 || l.SubmitRate != 2 || l.SubmitBurst != 20 {
+		t.Errorf("got limits %+v", l)
+	}
+	if l, err := (limitsConfig{}).limits(); err != nil || l.Allow != nil {
+		t.Errorf("empty allowlist: got %v, %v", l.Allow, err)
+	}
+}
+
+func TestServeFlags(t *testing.T) {
+	path := writeConfig(t, `{
+		"threads": 4,
+		"dagDir": "/var/lib/ethash",
+		"pools": [{"url": "http://node1:8545"}],
+		"poll": "5s",
+		"tokens": {"secret": "rig1"},
+		"limits": {"allow": ["10.0.0.0/8"]}
+	}`)
+	defer os.Remove(path)
+
+	parse := func(args ...string) (config, error) {
+		fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+		load := serveFlags(fs, defaultConfig())
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return load()
+	}
+
+	// the flags given take precedence, the others don't override the file.
+	cfg, err := parse("-config", path, "-threads", "2", "-token", "other", "-upstream", "http://a,http://b", "-allow", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Threads != 2 {
+		t.Errorf("got %d threads, want those of the flag", cfg.Threads)
+	}
+	if cfg.DAGDir != "/var/lib/ethash" || time.Duration(cfg.Poll) != 5*time.Second {
+		t.Errorf("flag defaults overrode the file: dir %q, poll %v", cfg.DAGDir, time.Duration(cfg.Poll))
+	}
+	if want := map[string]string{"other": ""}; !reflect.DeepEqual(cfg.Tokens, want) {
+		t.Errorf("got tokens %v, want %v", cfg.Tokens, want)
+	}
+	if want := []string{"http://a", "http://b"}; !reflect.DeepEqual(cfg.upstreams(), want) {
+		t.Errorf("got upstreams %v, want %v", cfg.upstreams(), want)
+	}
+	if cfg.Limits.Allow != nil {
+		t.Errorf("empty -allow kept %v", cfg.Limits.Allow)
+	}
+
+	// without a file, flags apply on top of the defaults.
+	cfg, err = parse("-allow", "10.0.0.1", "-hashrate-window", "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def := defaultConfig(); cfg.DAGDir != def.DAGDir || cfg.Threads != def.Threads || time.Duration(cfg.HashrateWindow) != time.Minute {
+		t.Errorf("got %+v", cfg)
+	}
+	if want := []string{"10.0.0.1"}; !reflect.DeepEqual(cfg.Limits.Allow, want) {
+		t.Errorf("got allowlist %v, want %v", cfg.Limits.Allow, want)
+	}
+
+	// flags are checked like the file.
+	for _, args := range [][]string{
+		{"-threads", "-1"},
+		{"-allow", "10.0.0.0/33"},
+		{"-hashrate-sample", "1h"},
+		{"-tls-cert", "cert.pem"},
+		{"-config", path, "-datasets", "0"},
+	} {
+		if _, err := parse(args...); err == nil {
+			t.Errorf("%v: no error", args)
+		}
+	}
+}
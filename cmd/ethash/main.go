@@ -0,0 +1,130 @@
+// Command ethash provides tools for working with ethash caches and DAG files.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ethereum/ethash"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// errUsage is returned by commands invoked with invalid arguments.
+var errUsage = errors.New("invalid arguments")
+
+var commands = []command{
+	{"verifydag", "verifydag [-samples N] <dag file>...", verifyDAG},
+	{"makecache", "makecache [-dir D] <epoch>...", makeCache},
+	{"makedag", "makedag [-dir D] <epoch>...", makeDAG},
+	{"verify", "verify -hash H -nonce N -mix M -difficulty D [-number N]", verifySeal},
+	{"serve", "serve [-config FILE] [-upstream URL[,URL...]] [-http ADDR] [-stratum ADDR] [-tls-cert FILE -tls-key FILE [-tls-client-ca FILE]] [-token T] [-allow NETS] [-poll D] [-threads N] [-dir D] [-datasets N] [-gpudag] [-hashrate-window D] [-hashrate-sample D] [-metrics ADDR] [-status FILE] [-shutdown-timeout D]", serve},
+	{"bench", "bench [-light|-full|-report] [-threads N] [-duration D] [-number N] [-dir D]", bench},
+	{"verifyserver", "verifyserver [-http ADDR] [-caches N] [-dir D]", verifyServer},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			err := cmd.run(os.Args[2:])
+			if err == errUsage {
+				usage()
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "ethash:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	for _, cmd := range commands {
+		fmt.Fprintln(os.Stderr, "  ethash", cmd.usage)
+	}
+	os.Exit(2)
+}
+
+func verifyDAG(args []string) error {
+	fs := flag.NewFlagSet("verifydag", flag.ExitOnError)
+	samples := fs.Int("samples", 1000, "number of random DAG items to check, 0 checks all")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	failed := false
+	for _, path := range fs.Args() {
+		if err := ethash.VerifyDAGFile(path, *samples); err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			failed = true
+		} else {
+			fmt.Printf("%s: OK\n", path)
+		}
+	}
+	if failed {
+		return fmt.Errorf("some DAG files are invalid")
+	}
+	return nil
+}
+
+func makeCache(args []string) error {
+	fs := flag.NewFlagSet("makecache", flag.ExitOnError)
+	dir := fs.String("dir", ethash.DefaultDir, "directory to store the cache files in")
+	fs.Parse(args)
+	epochs, err := parseEpochs(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, epoch := range epochs {
+		if err := ethash.MakeCache(epoch, *dir); err != nil {
+			return err
+		}
+		fmt.Printf("generated cache for epoch %d in %s\n", epoch, *dir)
+	}
+	return nil
+}
+
+func makeDAG(args []string) error {
+	fs := flag.NewFlagSet("makedag", flag.ExitOnError)
+	dir := fs.String("dir", ethash.DefaultDir, "directory to store the DAG files in")
+	fs.Parse(args)
+	epochs, err := parseEpochs(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, epoch := range epochs {
+		if err := ethash.MakeDataset(epoch, *dir); err != nil {
+			return err
+		}
+		fmt.Printf("generated DAG for epoch %d in %s\n", epoch, *dir)
+	}
+	return nil
+}
+
+func parseEpochs(args []string) ([]uint64, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	epochs := make([]uint64, len(args))
+	for i, arg := range args {
+		epoch, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid epoch %q", arg)
+		}
+		epochs[i] = epoch
+	}
+	return epochs, nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/ethash/remote"
+)
+
+// statusInterval is how often serve writes the status file.
+const statusInterval = 10 * time.Second
+
+// serve runs the mining proxy until it is interrupted by SIGINT or
+// SIGTERM. It returns nil if it then shut down cleanly, i.e. the mining
+// threads stopped and no DAG file was left half written, so the
+// command exits with status 0, and an error otherwise.
+func serve(args []string) error {
+	def := defaultConfig()
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	load := serveFlags(fs, def)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	if cfg.upstream() == "" || (cfg.HTTPAddr == "" && cfg.StratumAddr == "" && cfg.Threads == 0) {
+		return errUsage
+	}
+	tlsCfg, err := cfg.TLS.load()
+	if err != nil {
+		return err
+	}
+
+	srv := remote.NewServer(cfg.newUpstream())
+	full := &ethash.Full{Dir: cfg.DAGDir}
+	full.Turbo(true)
+	full.EnableAutoDAG(1)
+	miner := remote.NewMiner(srv, full)
+	apply := func(cfg config) {
+		srv.SetPollInterval(time.Duration(cfg.Poll))
+		srv.SetSubmitStale(cfg.SubmitStale)
+		srv.SetTokens(cfg.Tokens)
+		limits, _ := cfg.Limits.limits() // checked by load
+		srv.SetLimits(limits)
+		full.SetGenerationLimits(ethash.GenerationLimits{CPUShare: cfg.Throttle.CPUShare, WriteRate: cfg.Throttle.WriteRate})
+		full.SetDatasetsInMem(cfg.DatasetsInMem)
+		full.SetGPUDAG(cfg.GPUDAG)
+		full.SetHashrateWindow(time.Duration(cfg.HashrateWindow), time.Duration(cfg.HashrateSample))
+		miner.SetThreads(cfg.Threads)
+	}
+	apply(cfg)
+	stop := make(chan struct{})
+	go srv.Run(stop)
+
+	errc := make(chan error, 3)
+	var listeners []net.Listener
+	listen := func(name, addr string, tlsCfg *tls.Config, serve func(net.Listener) error) error {
+		if addr == "" {
+			return nil
+		}
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		if tlsCfg != nil {
+			l = tls.NewListener(l, tlsCfg)
+			name += " over TLS"
+		}
+		fmt.Printf("serving %s on %v\n", name, l.Addr())
+		listeners = append(listeners, l)
+		go func() { errc <- serve(l) }()
+		return nil
+	}
+	if err := listen("getWork", cfg.HTTPAddr, tlsCfg, func(l net.Listener) error { return http.Serve(l, srv) }); err != nil {
+		return err
+	}
+	if err := listen("stratum", cfg.StratumAddr, tlsCfg, srv.ServeStratum); err != nil {
+		return err
+	}
+	expvar.Publish("ethash", expvar.Func(func() interface{} {
+		mem := ethash.MemoryStats()
+		return map[string]interface{}{
+			"hashrate": full.GetHashrate(),
+			"threads":  miner.Threads(),
+			"memory":   mem.Total(),
+			"shares":   srv.Stats(),
+			"found":    miner.Stats(),
+		}
+	}))
+	metrics := http.NewServeMux()
+	metrics.Handle("/", expvar.Handler())
+	metrics.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(miner.Status())
+	})
+	if err := listen("metrics", cfg.MetricsAddr, nil, func(l net.Listener) error { return http.Serve(l, metrics) }); err != nil {
+		return err
+	}
+	statusTicker := time.NewTicker(statusInterval)
+	defer statusTicker.Stop()
+	writeStatus := func() {
+		if cfg.StatusFile == "" {
+			return
+		}
+		if err := remote.WriteStatusFile(cfg.StatusFile, miner.Status()); err != nil {
+			fmt.Fprintln(os.Stderr, "ethash: can't write status:", err)
+		}
+	}
+
+	// shutdown stops mining and serving, then waits for the mining
+	// threads and DAG file writes to finish. It gives up after the
+	// shutdown timeout or on a second signal.
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
+	shutdown := func(timeout time.Duration) error {
+		close(stop)
+		for _, l := range listeners {
+			l.Close()
+		}
+		srv.CloseStratum("server shutting down")
+		deadline := time.Now().Add(timeout)
+		flushed := make(chan bool, 1)
+		go func() {
+			miner.Stop()
+			flushed <- ethash.FlushDAGFiles(time.Until(deadline))
+		}()
+		select {
+		case ok := <-flushed:
+			if !ok {
+				return fmt.Errorf("DAG files still being written after %v, they are regenerated on the next start", timeout)
+			}
+			return nil
+		case <-time.After(timeout):
+			return fmt.Errorf("mining threads did not stop within %v", timeout)
+		case sig := <-term:
+			return fmt.Errorf("shutdown interrupted by %v", sig)
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for {
+		select {
+		case err := <-errc:
+			shutdown(time.Duration(cfg.ShutdownTimeout))
+			return err
+		case sig := <-term:
+			fmt.Printf("received %v, shutting down\n", sig)
+			return shutdown(time.Duration(cfg.ShutdownTimeout))
+		case <-hup:
+			cfg = reload(cfg, load, srv, apply)
+		case <-statusTicker.C:
+			writeStatus()
+		}
+	}
+}
+
+// serveFlags defines the flags of the serve command in fs and returns
+// a function loading the configuration: it reads the configuration
+// file, if any, and applies the flags given on the command line on top
+// of it. The defaults are those of def.
+func serveFlags(fs *flag.FlagSet, def config) (load func() (config, error)) {
+	configPath := fs.String("config", "", "JSON configuration file, flags given as well take precedence")
+	upstream := fs.String("upstream", "", "JSON-RPC endpoints of the nodes providing work, comma separated in order of preference")
+	httpAddr := fs.String("http", def.HTTPAddr, "address serving getWork and work pushed over websocket, empty to disable")
+	stratumAddr := fs.String("stratum", def.StratumAddr, "address serving stratum, empty to disable")
+	tlsCert := fs.String("tls-cert", "", "PEM certificate file to serve getWork and stratum over TLS with")
+	tlsKey := fs.String("tls-key", "", "PEM private key file of the TLS certificate")
+	tlsClientCA := fs.String("tls-client-ca", "", "PEM file of the CAs miners' client certificates must be signed by, optional")
+	token := fs.String("token", "", "secret miners must authenticate with, replaces the tokens of the configuration file")
+	allow := fs.String("allow", "", "comma separated networks miners may connect from, e.g. 10.0.0.0/8, any if empty")
+	poll := fs.Duration("poll", time.Duration(def.Poll), "how often to ask the upstream for work")
+	threads := fs.Int("threads", def.Threads, "number of local mining threads")
+	dir := fs.String("dir", def.DAGDir, "directory to store the DAG files in")
+	datasets := fs.Int("datasets", def.DatasetsInMem, "number of DAGs kept in memory, more than a gigabyte each")
+	hashrateWindow := fs.Duration("hashrate-window", time.Duration(def.HashrateWindow), "period the reported hash rate is averaged over")
+	hashrateSample := fs.Duration("hashrate-sample", time.Duration(def.HashrateSample), "minimum time between two hash rate samples")
+	gpuDAG := fs.Bool("gpudag", def.GPUDAG, "generate DAGs on the GPU if built with the opencl tag")
+	metricsAddr := fs.String("metrics", def.MetricsAddr, "address serving metrics and the status at /status, empty to disable")
+	statusFile := fs.String("status", def.StatusFile, "file to write the status to every "+statusInterval.String()+", empty to disable")
+	shutdownTimeout := fs.Duration("shutdown-timeout", time.Duration(def.ShutdownTimeout), "how long to wait for mining threads and DAG writes on exit")
+	return func() (config, error) {
+		cfg := def
+		if *configPath != "" {
+			var err error
+			if cfg, err = loadConfig(*configPath); err != nil {
+				return cfg, err
+			}
+		}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "upstream":
+				cfg.Pools = nil
+				for i, url := range strings.Split(*upstream, ",") {
+					cfg.Pools = append(cfg.Pools, poolConfig{URL: url, Priority: i})
+				}
+			case "http":
+				cfg.HTTPAddr = *httpAddr
+			case "stratum":
+				cfg.StratumAddr = *stratumAddr
+			case "tls-cert":
+				cfg.TLS.CertFile = *tlsCert
+			case "tls-key":
+				cfg.TLS.KeyFile = *tlsKey
+			case "tls-client-ca":
+				cfg.TLS.ClientCAFile = *tlsClientCA
+			case "token":
+				cfg.Tokens = map[string]string{*token: ""}
+			case "allow":
+				cfg.Limits.Allow = nil
+				if *allow != "" {
+					cfg.Limits.Allow = strings.Split(*allow, ",")
+				}
+			case "poll":
+				cfg.Poll = duration(*poll)
+			case "threads":
+				cfg.Threads = *threads
+			case "dir":
+				cfg.DAGDir = *dir
+			case "datasets":
+				cfg.DatasetsInMem = *datasets
+			case "hashrate-window":
+				cfg.HashrateWindow = duration(*hashrateWindow)
+			case "hashrate-sample":
+				cfg.HashrateSample = duration(*hashrateSample)
+			case "gpudag":
+				cfg.GPUDAG = *gpuDAG
+			case "metrics":
+				cfg.MetricsAddr = *metricsAddr
+			case "status":
+				cfg.StatusFile = *statusFile
+			case "shutdown-timeout":
+				cfg.ShutdownTimeout = duration(*shutdownTimeout)
+			}
+		})
+		return cfg, cfg.check()
+	}
+}
+
+// reload re-reads the configuration on SIGHUP and applies the settings
+// that can change at runtime: threads, DAGs in memory, GPU DAG
+// generation, throttle, hash rate window, poll interval, stale solution
+// policy, tokens, limits, status file and the upstream pools. Listen
+// addresses, TLS settings and the DAG directory need a restart. The
+// current configuration is kept if the new one is invalid.
+func reload(old config, load func() (config, error), srv *remote.Server, apply func(config)) config {
+	cfg, err := load()
+	if err == nil && cfg.upstream() == "" {
+		err = fmt.Errorf("no upstream configured")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ethash: not reloading configuration:", err)
+		return old
+	}
+	if cfg.HTTPAddr != old.HTTPAddr || cfg.StratumAddr != old.StratumAddr || cfg.MetricsAddr != old.MetricsAddr || cfg.TLS != old.TLS || cfg.DAGDir != old.DAGDir {
+		fmt.Fprintln(os.Stderr, "ethash: listen address, TLS and DAG directory changes take effect after a restart")
+		cfg.HTTPAddr, cfg.StratumAddr, cfg.MetricsAddr, cfg.TLS, cfg.DAGDir = old.HTTPAddr, old.StratumAddr, old.MetricsAddr, old.TLS, old.DAGDir
+	}
+	upstreams := strings.Join(cfg.upstreams(), ", ")
+	if upstreams != strings.Join(old.upstreams(), ", ") {
+		srv.SetUpstream(cfg.newUpstream())
+	}
+	apply(cfg)
+	fmt.Printf("reloaded configuration: %d threads, upstream %s\n", cfg.Threads, upstreams)
+	return cfg
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/ethash/remote"
+)
+
+func TestReload(t *testing.T) {
+	old := defaultConfig()
+	old.Threads = 4
+	old.Pools = []poolConfig{{URL: "http://node1:8545"}}
+	srv := remote.NewServer(old.newUpstream())
+
+	var applied []config
+	apply := func(cfg config) { applied = append(applied, cfg) }
+	reloadWith := func(cfg config, err error) config {
+		return reload(old, func() (config, error) { return cfg, err }, srv, apply)
+	}
+	upstream := func() string { return srv.UpstreamStats()[0].Name }
+
+	// runtime settings and the pools change.
+	next := old
+	next.Threads = 2
+	next.Throttle.CPUShare = 0.5
+	next.Tokens = map[string]string{"secret": "rig1"}
+	next.Limits.ConnsPerIP = 8
+	next.Pools = []poolConfig{{URL: "http://node2:8545"}}
+	cfg := reloadWith(next, nil)
+	if !reflect.DeepEqual(cfg, next) {
+		t.Errorf("got %+v, want %+v", cfg, next)
+	}
+	if len(applied) != 1 || !reflect.DeepEqual(applied[0], next) {
+		t.Errorf("applied %+v, want %+v", applied, next)
+	}
+	if upstream() != "http://node2:8545" {
+		t.Errorf("upstream %s after reload", upstream())
+	}
+
+	// restart-only settings are refused, the others still apply.
+	applied = nil
+	srv.SetUpstream(old.newUpstream())
+	restart := old
+	restart.Threads = 1
+	restart.HTTPAddr = "0.0.0.0:8545"
+	restart.StratumAddr = ""
+	restart.MetricsAddr = "127.0.0.1:9100"
+	restart.TLS = tlsConfig{CertFile: "cert.pem", KeyFile: "key.pem"}
+	restart.DAGDir = "/tmp/ethash"
+	cfg = reloadWith(restart, nil)
+	if cfg.HTTPAddr != old.HTTPAddr || cfg.StratumAddr != old.StratumAddr || cfg.MetricsAddr != old.MetricsAddr || cfg.TLS != old.TLS || cfg.DAGDir != old.DAGDir {
+		t.Errorf("restart-only settings changed: %+v", cfg)
+	}
+	if cfg.Threads != 1 || len(applied) != 1 || applied[0].Threads != 1 {
+		t.Errorf("threads not reloaded along with restart-only settings: %+v", cfg)
+	}
+	if upstream() != "http://node1:8545" {
+		t.Errorf("upstream replaced although the pools are unchanged: %s", upstream())
+	}
+
+	// invalid configurations are not applied.
+	applied = nil
+	noPools := old
+	noPools.Threads = 8
+	noPools.Pools = nil
+	for name, cfg := range map[string]config{
+		"load error":  reloadWith(next, errors.New("invalid")),
+		"no upstream": reloadWith(noPools, nil),
+	} {
+		if !reflect.DeepEqual(cfg, old) {
+			t.Errorf("%s: got %+v, want the old configuration", name, cfg)
+		}
+	}
+	if len(applied) != 0 {
+		t.Errorf("invalid configurations applied: %+v", applied)
+	}
+	if upstream() != "http://node1:8545" {
+		t.Errorf("upstream replaced by an invalid configuration: %s", upstream())
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	two256     = new(big.Int).Lsh(big.NewInt(1), 256)
+	maxUint256 = new(big.Int).Sub(two256, big.NewInt(1))
+)
+
+// sealTarget returns the largest result meeting difficulty. It is
+// capped to 2^256-1 so that it fits into a hash.
+func sealTarget(difficulty *big.Int) *big.Int {
+	target := new(big.Int).Div(two256, difficulty)
+	if target.Cmp(maxUint256) > 0 {
+		target.Set(maxUint256)
+	}
+	return target
+}
+
+// parseHash parses a 32 byte hash given in hex, with or without 0x
+// prefix.
+func parseHash(s string) (common.Hash, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid hash %q, want %d hex bytes", s, common.HashLength)
+	}
+	return common.BytesToHash(b), nil
+}
+
+func verifySeal(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	hash := fs.String("hash", "", "header hash without nonce, hex")
+	nonceFlag := fs.String("nonce", "", "seal nonce, hex with 0x prefix or decimal")
+	mix := fs.String("mix", "", "mix digest claimed by the seal, hex")
+	difficultyFlag := fs.String("difficulty", "", "block difficulty")
+	number := fs.Uint64("number", 0, "block number")
+	fs.Parse(args)
+	if fs.NArg() != 0 || *hash == "" || *nonceFlag == "" || *mix == "" || *difficultyFlag == "" {
+		return errUsage
+	}
+	headerHash, err := parseHash(*hash)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ethash: -hash:", err)
+		return errUsage
+	}
+	mixDigest, err := parseHash(*mix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ethash: -mix:", err)
+		return errUsage
+	}
+	nonce, err := strconv.ParseUint(*nonceFlag, 0, 64)
+	if err != nil {
+		return fmt.Errorf("invalid nonce %q", *nonceFlag)
+	}
+	difficulty, ok := new(big.Int).SetString(*difficultyFlag, 0)
+	if !ok || difficulty.Sign() <= 0 {
+		return fmt.Errorf("invalid difficulty %q", *difficultyFlag)
+	}
+
+	alg, err := ethash.GetAlgorithm("ethash")
+	if err != nil {
+		return err
+	}
+	cache, err := alg.NewCache(*number / alg.EpochLength())
+	if err != nil {
+		return err
+	}
+	gotMix, result := cache.Hash(headerHash, nonce)
+	target := sealTarget(difficulty)
+	mixOK := gotMix == mixDigest
+	resultOK := new(big.Int).SetBytes(result[:]).Cmp(target) <= 0
+
+	fmt.Printf("result:     %x\n", result)
+	fmt.Printf("target:     %064x\n", target)
+	fmt.Printf("mix digest: %x\n", gotMix)
+	switch {
+	case !mixOK:
+		fmt.Println("FAIL: mix digest does not match")
+	case !resultOK:
+		fmt.Println("FAIL: result is above the target")
+	default:
+		fmt.Println("PASS")
+		return nil
+	}
+	return fmt.Errorf("invalid seal")
+}
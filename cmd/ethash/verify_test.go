@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestSealTarget(t *testing.T) {
+	for _, test := range []struct {
+		difficulty int64
+		target     *big.Int
+	}{
+		{1, maxUint256},
+		{2, new(big.Int).Lsh(big.NewInt(1), 255)},
+		{1 << 20, new(big.Int).Lsh(big.NewInt(1), 236)},
+	} {
+		target := sealTarget(big.NewInt(test.difficulty))
+		if target.Cmp(test.target) != 0 {
+			t.Errorf("difficulty %d: got target %x, want %x", test.difficulty, target, test.target)
+		}
+		if s := fmt.Sprintf("%064x", target); len(s) != 64 {
+			t.Errorf("difficulty %d: target printed as %d digits", test.difficulty, len(s))
+		}
+	}
+}
+
+func TestVerifySealUsage(t *testing.T) {
+	const hash = "0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d"
+	for _, args := range [][]string{
+		{"-nonce", "0", "-mix", hash, "-difficulty", "1"},
+		{"-hash", "0x1234", "-nonce", "0", "-mix", hash, "-difficulty", "1"},
+		{"-hash", hash[:len(hash)-1] + "g", "-nonce", "0", "-mix", hash, "-difficulty", "1"},
+		{"-hash", hash, "-nonce", "0", "-mix", hash + "00", "-difficulty", "1"},
+		{"-hash", hash, "-nonce", "0", "-mix", "mix", "-difficulty", "1"},
+	} {
+		if err := verifySeal(args); err != errUsage {
+			t.Errorf("%v: got %v, want errUsage", args, err)
+		}
+	}
+	if h, err := parseHash(hash[2:]); err != nil || fmt.Sprintf("0x%x", h) != hash {
+		t.Errorf("hash without prefix: got %x, %v", h, err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"expvar"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/ethash/remote"
+)
+
+// verifyServer serves seal verification over HTTP until it fails. The
+// memory use and verification latencies are served at /debug/vars.
+func verifyServer(args []string) error {
+	fs := flag.NewFlagSet("verifyserver", flag.ExitOnError)
+	addr := fs.String("http", "127.0.0.1:8547", "address to serve verification on")
+	caches := fs.Int("caches", 3, "number of epoch caches kept in memory")
+	dir := fs.String("dir", "", "directory to store caches in, empty to keep them in memory only")
+	fs.Parse(args)
+	if fs.NArg() != 0 || *caches < 1 {
+		return errUsage
+	}
+	light := new(ethash.Light)
+	light.SetCachesInMem(*caches)
+	light.SetCacheDir(*dir)
+	light.SetLookahead(ethash.NoLookaheadLimit)
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	expvar.Publish("ethash", expvar.Func(func() interface{} {
+		return map[string]interface{}{
+			"memory":  ethash.MemoryStats().Total(),
+			"latency": ethash.GetVerifyLatency(),
+		}
+	}))
+	mux := http.NewServeMux()
+	mux.Handle("/", remote.NewVerifyService(light))
+	mux.Handle("/debug/vars", expvar.Handler())
+	fmt.Printf("serving verification on %v, metrics at /debug/vars\n", l.Addr())
+	return http.Serve(l, mux)
+}
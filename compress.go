@@ -0,0 +1,134 @@
+package ethash
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/golang/snappy"
+)
+
+// compressedSuffix is appended to the name of compressed DAG files,
+// which use the snappy framing format.
+const compressedSuffix = ".sz"
+
+// dagFiles serializes compression and decompression of DAG files.
+var dagFiles sync.Mutex
+
+// SetDAGCompression enables or disables compression of DAG files.
+// When enabled, the file of a DAG is compressed once the DAG is no
+// longer held in memory, and decompressed again before it is loaded.
+// This saves disk space when DAGs of several epochs are kept, at the
+// cost of CPU time whenever an epoch is loaded. The file is decompressed
+// as it is read, without holding the compressed DAG in memory.
+func (pow *Full) SetDAGCompression(on bool) {
+	pow.mu.Lock()
+	pow.compress = on
+	pow.mu.Unlock()
+}
+
+// compressDAGFile replaces the complete DAG file of the given epoch in
+// dir by its compressed form. The caller must hold the lock of the DAG
+// file, as other processes may be loading it.
+func compressDAGFile(dir string, epoch uint64, test bool) error {
+	dagFiles.Lock()
+	defer dagFiles.Unlock()
+	if !dagFileComplete(dir, epoch, test) {
+		return nil
+	}
+	path := filepath.Join(dir, dagName(makeSeedHash(epoch)))
+	if err := convertFile(path, path+compressedSuffix, compressTo); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// decompressDAGFile restores the DAG file of the given epoch in dir if
+// only its compressed form exists. The caller must hold the lock of the
+// DAG file, as other processes may be decompressing it too.
+func decompressDAGFile(dir string, epoch uint64) error {
+	dagFiles.Lock()
+	defer dagFiles.Unlock()
+	path := filepath.Join(dir, dagName(makeSeedHash(epoch)))
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if !compressedDAGExists(dir, epoch) {
+		return nil
+	}
+	if err := convertFile(path+compressedSuffix, path, decompressTo); err != nil {
+		return err
+	}
+	return os.Remove(path + compressedSuffix)
+}
+
+// isCorruptInput reports whether err means that a compressed DAG file
+// is malformed, as opposed to failing to write the decompressed file.
+func isCorruptInput(err error) bool {
+	return err == snappy.ErrCorrupt || err == snappy.ErrUnsupported || err == io.ErrUnexpectedEOF
+}
+
+// compressedDAGExists reports whether a compressed DAG file for the
+// given epoch exists in dir.
+func compressedDAGExists(dir string, epoch uint64) bool {
+	_, err := os.Stat(filepath.Join(dir, dagName(makeSeedHash(epoch))+compressedSuffix))
+	return err == nil
+}
+
+func compressTo(w io.Writer, r io.Reader) error {
+	zw := snappy.NewBufferedWriter(w)
+	if _, err := io.Copy(zw, r); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func decompressTo(w io.Writer, r io.Reader) error {
+	_, err := io.Copy(w, snappy.NewReader(r))
+	return err
+}
+
+// convertFile streams src through convert into a temporary file, which
+// is renamed to dst when complete.
+func convertFile(src, dst string, convert func(io.Writer, io.Reader) error) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := convert(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// compressDAG compresses the file of d in the background.
+func compressDAG(d *dag) {
+	beginDAGWrite()
+	go func() {
+		defer endDAGWrite()
+		unlock, err := d.lock()
+		if err != nil {
+			glog.V(logger.Error).Infof("Can't compress DAG for epoch %d: %v", d.epoch, err)
+			return
+		}
+		defer unlock()
+		if err := compressDAGFile(d.dir, d.epoch, d.test); err != nil {
+			glog.V(logger.Error).Infof("Can't compress DAG for epoch %d: %v", d.epoch, err)
+		}
+	}()
+}
@@ -0,0 +1,51 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDAGCompression(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	eth.SetDAGCompression(true)
+	block := &testBlock{difficulty: big.NewInt(10)}
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed")
+	}
+	eth.FreeDAG()
+
+	path := filepath.Join(eth.Full.Dir, dagName(makeSeedHash(0)))
+	deadline := time.Now().Add(5 * time.Second)
+	for !compressedDAGExists(eth.Full.Dir, 0) {
+		if time.Now().After(deadline) {
+			t.Fatal("DAG file was not compressed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	dagFiles.Lock()
+	_, err = os.Stat(path)
+	dagFiles.Unlock()
+	if err == nil {
+		t.Fatal("uncompressed DAG file still exists")
+	}
+
+	// with auto DAG disabled, the DAG can only come from the compressed file.
+	eth.DisableAutoDAG()
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed with compressed DAG file")
+	}
+	if err := verifyDAGFile(path, 0, true, 0); err != nil {
+		t.Errorf("decompressed DAG did not verify: %v", err)
+	}
+	if compressedDAGExists(eth.Full.Dir, 0) {
+		t.Error("compressed DAG file still exists after loading")
+	}
+}
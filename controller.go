@@ -0,0 +1,224 @@
+package ethash
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+// controllerStaleHistory is the number of replaced blocks a Controller
+// reports as stale.
+const controllerStaleHistory = 8
+
+// Result is a nonce found by a Controller. Stale is set if the block
+// was replaced while the nonce was found, it may still be used for an
+// uncle.
+type Result struct {
+	Block     pow.Block
+	Nonce     uint64
+	MixDigest common.Hash
+	Stale     bool
+}
+
+// Controller runs mining threads on the most recent block handed to
+// it. Unlike Search and Mine, which keep searching their block until
+// stopped, a Controller preempts its threads as soon as SetWork
+// replaces the block: they drop the old header at once and continue on
+// the new one, loading the DAG of its epoch if needed.
+type Controller struct {
+	pow     *Full
+	results chan Result
+
+	mu      sync.Mutex
+	work    *controllerWork // nil before the first SetWork
+	changed chan struct{}   // closed and replaced when work changes
+	stale   []common.Hash   // replaced blocks, most recent last
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// controllerWork is a block being mined by the threads of a
+// Controller. done is closed once it is replaced or solved.
+type controllerWork struct {
+	block  pow.Block
+	done   chan struct{}
+	closed bool
+	solved bool
+}
+
+// finish stops the search on w. The caller holds the controller's lock.
+func (w *controllerWork) finish() {
+	if !w.closed {
+		close(w.done)
+		w.closed = true
+	}
+}
+
+// NewController starts threads mining with pow. They are idle until
+// SetWork is called. Found nonces are delivered on Results.
+func NewController(pow *Full, threads int) *Controller {
+	c := &Controller{
+		pow:     pow,
+		results: make(chan Result, threads),
+		changed: make(chan struct{}),
+		quit:    make(chan struct{}),
+	}
+	for i := 0; i < threads; i++ {
+		c.wg.Add(1)
+		go c.mine()
+	}
+	return c
+}
+
+// HeadSubscriber is a chain announcing its new canonical heads.
+// SubscribeHeads returns a channel receiving the block to mine on top
+// of each new head, the pending block, and a function ending the
+// subscription. Blocks may be dropped if they arrive faster than they
+// are received, as long as the most recent one is delivered.
+type HeadSubscriber interface {
+	SubscribeHeads() (<-chan pow.Block, func())
+}
+
+// Follow makes the controller mine the pending block of every new head
+// of chain until it is stopped, so it cycles its work without the
+// caller polling the chain and calling SetWork.
+func (c *Controller) Follow(chain HeadSubscriber) {
+	heads, unsubscribe := chain.SubscribeHeads()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer unsubscribe()
+		for {
+			select {
+			case block, ok := <-heads:
+				if !ok {
+					return
+				}
+				c.SetWork(block)
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Results returns the channel delivering the nonces found. It must be
+// drained, threads wait for their result to be taken.
+func (c *Controller) Results() <-chan Result {
+	return c.results
+}
+
+// SetWork makes block the work of all threads, replacing the current
+// block, which is then reported as stale. Threads searching the old
+// block move over at once. A block with a lower number than the
+// current one is a late delivery of an outdated head and is dropped,
+// SetWork then returns false. Setting the current block again has no
+// effect.
+func (c *Controller) SetWork(block pow.Block) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old := c.work; old != nil {
+		if block.HashNoNonce() == old.block.HashNoNonce() {
+			return true
+		}
+		if block.NumberU64() < old.block.NumberU64() {
+			glog.V(logger.Debug).Infof("Dropping work for block %d, already mining %d", block.NumberU64(), old.block.NumberU64())
+			return false
+		}
+		old.finish()
+		c.stale = append(c.stale, old.block.HashNoNonce())
+		if len(c.stale) > controllerStaleHistory {
+			c.stale = c.stale[1:]
+		}
+		glog.V(logger.Debug).Infof("Replacing work for block %d with %d", old.block.NumberU64(), block.NumberU64())
+	}
+	c.work = &controllerWork{block: block, done: make(chan struct{})}
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return true
+}
+
+// IsStale reports whether the block with the given header hash was
+// recently replaced by SetWork.
+func (c *Controller) IsStale(hashNoNonce common.Hash) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range c.stale {
+		if h == hashNoNonce {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops all threads and waits for them to exit.
+func (c *Controller) Stop() {
+	c.mu.Lock()
+	select {
+	case <-c.quit:
+	default:
+		close(c.quit)
+		if c.work != nil {
+			c.work.finish()
+		}
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+// current returns the work to mine, nil if there is none or it is
+// finished, and the channel closed when it changes.
+func (c *Controller) current() (*controllerWork, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.quit:
+		return nil, c.changed
+	default:
+	}
+	if c.work == nil || c.work.closed {
+		return nil, c.changed
+	}
+	return c.work, c.changed
+}
+
+// mine is a mining thread.
+func (c *Controller) mine() {
+	defer c.wg.Done()
+	for {
+		work, changed := c.current()
+		if work != nil {
+			nonce, mixDigest, found := c.pow.search(work.block, work.done, nil)
+			if found {
+				c.deliver(work, nonce, mixDigest)
+			}
+		}
+		// wait for new work unless it changed during the search.
+		select {
+		case <-changed:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// deliver reports a nonce found for work. Only the first nonce found
+// for a block is delivered, finding it stops the other threads.
+func (c *Controller) deliver(work *controllerWork, nonce uint64, mixDigest []byte) {
+	c.mu.Lock()
+	first := !work.solved
+	work.solved = true
+	stale := c.work != work
+	work.finish()
+	c.mu.Unlock()
+	if !first {
+		return
+	}
+	select {
+	case c.results <- Result{Block: work.block, Nonce: nonce, MixDigest: common.BytesToHash(mixDigest), Stale: stale}:
+	case <-c.quit:
+	}
+}
@@ -0,0 +1,108 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestControllerPreempts(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+	c := NewController(eth.Full, 2)
+	defer c.Stop()
+
+	// the first block can't be mined in reasonable time.
+	hard := &testBlock{number: 10, hashNoNonce: common.HexToHash("0x01"), difficulty: new(big.Int).Lsh(big.NewInt(1), 255)}
+	c.SetWork(hard)
+	time.Sleep(100 * time.Millisecond)
+	if c.SetWork(&testBlock{number: 9, hashNoNonce: common.HexToHash("0x02"), difficulty: big.NewInt(10)}) {
+		t.Error("work for an older block accepted")
+	}
+	next := &testBlock{number: 11, hashNoNonce: common.HexToHash("0x03"), difficulty: big.NewInt(10)}
+	if !c.SetWork(next) {
+		t.Fatal("work for the new head dropped")
+	}
+	if !c.IsStale(hard.hashNoNonce) || c.IsStale(next.hashNoNonce) {
+		t.Error("replaced block not stale")
+	}
+
+	select {
+	case res := <-c.Results():
+		if res.Block != next || res.Stale {
+			t.Fatalf("got result for block %d, stale %v, want %d", res.Block.NumberU64(), res.Stale, next.number)
+		}
+		next.seal(res.Nonce, res.MixDigest[:])
+		if !eth.Verify(next) {
+			t.Error("nonce found for the new head could not be verified")
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("no result for the new head")
+	}
+	// the other thread stopped on the solved block, nothing more comes.
+	select {
+	case res := <-c.Results():
+		t.Errorf("second result for block %d", res.Block.NumberU64())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestControllerStop(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	c := NewController(eth.Full, 2)
+	c.SetWork(&testBlock{difficulty: new(big.Int).Lsh(big.NewInt(1), 255)})
+	time.Sleep(50 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		c.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("threads didn't stop")
+	}
+}
+
+func TestControllerFollow(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+	c := NewController(eth.Full, 1)
+	defer c.Stop()
+	chain := NewFakeChain(0, big.NewInt(10))
+	c.Follow(chain)
+
+	for head := uint64(0); head < 3; head++ {
+		if head > 0 {
+			chain.SetHead(head)
+		}
+		select {
+		case res := <-c.Results():
+			if res.Block.NumberU64() != head+1 {
+				t.Fatalf("mined block %d on head %d", res.Block.NumberU64(), head)
+			}
+			block := res.Block.(*FakeBlock)
+			block.SetSeal(res.Nonce, res.MixDigest)
+			if !eth.Verify(block) {
+				t.Errorf("block %d: nonce could not be verified", head+1)
+			}
+		case <-time.After(20 * time.Second):
+			t.Fatalf("nothing mined on head %d", head)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ethash
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestReferenceCrossCheck compares the seals computed through the Go
+// bindings with those of the reference tool built from test/c, or from
+// another release of libethash, over random inputs. It only runs if
+// ETHASH_REFERENCE names the tool. ETHASH_CROSSCHECK_SEED repeats the
+// inputs of an earlier run.
+func TestReferenceCrossCheck(t *testing.T) {
+	tool := os.Getenv("ETHASH_REFERENCE")
+	if tool == "" {
+		t.Skip("ETHASH_REFERENCE not set")
+	}
+	seed := time.Now().UnixNano()
+	if s := os.Getenv("ETHASH_CROSSCHECK_SEED"); s != "" {
+		var err error
+		if seed, err = strconv.ParseInt(s, 0, 64); err != nil {
+			t.Fatal("invalid ETHASH_CROSSCHECK_SEED:", err)
+		}
+	}
+	t.Logf("inputs from seed %d", seed)
+	rnd := rand.New(rand.NewSource(seed))
+
+	// The tool generates a cache per epoch, the inputs are sorted by it.
+	const epochs, perEpoch = 3, 32
+	type input struct {
+		number     uint64
+		headerHash common.Hash
+		nonce      uint64
+	}
+	var (
+		inputs []input
+		stdin  bytes.Buffer
+	)
+	for epoch := uint64(0); epoch < epochs; epoch++ {
+		for i := 0; i < perEpoch; i++ {
+			in := input{number: epoch*epochLength + uint64(rnd.Int63n(int64(epochLength))), nonce: rnd.Uint64()}
+			rnd.Read(in.headerHash[:])
+			inputs = append(inputs, in)
+			fmt.Fprintf(&stdin, "%x %x %x\n", in.number, in.headerHash, in.nonce)
+		}
+	}
+	cmd := exec.Command(tool)
+	cmd.Stdin = &stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s: %v", tool, err)
+	}
+
+	lines := bufio.NewScanner(bytes.NewReader(out))
+	var cache *Cache
+	for i, in := range inputs {
+		if !lines.Scan() {
+			t.Fatalf("%s answered %d of %d inputs", tool, i, len(inputs))
+		}
+		var mixHex, resultHex string
+		if _, err := fmt.Sscan(lines.Text(), &mixHex, &resultHex); err != nil {
+			t.Fatalf("%s: invalid output %q", tool, lines.Text())
+		}
+		if epoch := in.number / epochLength; cache == nil || cache.Epoch() != epoch {
+			if cache, err = NewCache(epoch); err != nil {
+				t.Fatal(err)
+			}
+		}
+		mix, result := cache.Hash(in.headerHash, in.nonce)
+		if mix != common.HexToHash(mixHex) || result != common.HexToHash(resultHex) {
+			t.Errorf("block %d, header %x, nonce %x: computed %x, %x, reference %s, %s",
+				in.number, in.headerHash, in.nonce, mix, result, mixHex, resultHex)
+		}
+	}
+}
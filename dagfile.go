@@ -0,0 +1,299 @@
+package ethash
+
+/*
+#include "src/libethash/internal.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+const (
+	dagMagicSize = C.ETHASH_DAG_MAGIC_NUM_SIZE
+	dagItemSize  = C.ETHASH_HASH_BYTES
+)
+
+// dagWrites counts DAG generations and compressions in progress. Unlike
+// a sync.WaitGroup, it may be incremented while FlushDAGFiles waits.
+var dagWrites struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // closed when n drops to zero
+}
+
+// beginDAGWrite records the start of a DAG file write.
+func beginDAGWrite() {
+	dagWrites.mu.Lock()
+	if dagWrites.n == 0 {
+		dagWrites.idle = make(chan struct{})
+	}
+	dagWrites.n++
+	dagWrites.mu.Unlock()
+}
+
+// endDAGWrite records the end of a DAG file write.
+func endDAGWrite() {
+	dagWrites.mu.Lock()
+	if dagWrites.n--; dagWrites.n == 0 {
+		close(dagWrites.idle)
+	}
+	dagWrites.mu.Unlock()
+}
+
+// FlushDAGFiles waits until all DAG files being generated or
+// compressed are completely written, so that the process can exit
+// without interrupting them. It reports whether that happened within
+// timeout. Writes started while it waits are waited for as well. A
+// DAG file left behind incomplete lacks its magic number and is
+// regenerated the next time it is needed, so exiting after a timeout is
+// safe, it only wastes the work done so far.
+func FlushDAGFiles(timeout time.Duration) bool {
+	dagWrites.mu.Lock()
+	n, idle := dagWrites.n, dagWrites.idle
+	dagWrites.mu.Unlock()
+	if n == 0 {
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// dagName returns the name of the DAG file for the given seed hash,
+// following https://github.com/ethereum/wiki/wiki/Ethash-DAG.
+func dagName(seedHash common.Hash) string {
+	return fmt.Sprintf("full-R%d-%x%s", C.ETHASH_REVISION, seedHash[:8], dagNameSuffix)
+}
+
+// dagFileEpoch determines the epoch of a DAG file from its name.
+func dagFileEpoch(path string) (uint64, error) {
+	var (
+		rev    uint
+		prefix []byte
+	)
+	if _, err := fmt.Sscanf(filepath.Base(path), "full-R%d-%x", &rev, &prefix); err != nil || len(prefix) != 8 {
+		return 0, fmt.Errorf("%s is not a DAG file name", filepath.Base(path))
+	}
+	if rev != C.ETHASH_REVISION {
+		return 0, fmt.Errorf("DAG revision %d does not match the supported revision %d", rev, C.ETHASH_REVISION)
+	}
+	for epoch := uint64(0); epoch < maxEpoch; epoch++ {
+		if seed := makeSeedHash(epoch); bytes.Equal(seed[:8], prefix) {
+			if filepath.Base(path) != dagName(seed) {
+				return 0, fmt.Errorf("%s is not a DAG file of this build, want %s", filepath.Base(path), dagName(seed))
+			}
+			return epoch, nil
+		}
+	}
+	return 0, fmt.Errorf("no epoch below %d has seed hash prefix %x", maxEpoch, prefix)
+}
+
+// checkDAGHeader checks that f has the expected size and starts with
+// the magic number.
+func checkDAGHeader(f *os.File, dagSize uint64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if uint64(fi.Size()) != dagSize+dagMagicSize {
+		return fmt.Errorf("DAG file has size %d, want %d", fi.Size(), dagSize+dagMagicSize)
+	}
+	// The magic number is written in host byte order after the
+	// DAG has been completely generated.
+	var magic [dagMagicSize]byte
+	if _, err := f.ReadAt(magic[:], 0); err != nil {
+		return err
+	}
+	if *(*uint64)(unsafe.Pointer(&magic[0])) != C.ETHASH_DAG_MAGIC_NUM {
+		return errors.New("DAG file has no magic number, it was not generated completely")
+	}
+	return nil
+}
+
+// dagFileComplete reports whether a completely generated DAG file for
+// the given epoch exists in dir.
+func dagFileComplete(dir string, epoch uint64, test bool) bool {
+	f, err := os.Open(filepath.Join(dir, dagName(makeSeedHash(epoch))))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return checkDAGHeader(f, datasetSize(epoch, test)) == nil
+}
+
+// VerifyDAGFile checks the DAG file at path against the verification
+// cache of its epoch. It recomputes the given number of randomly chosen
+// dataset items, or all of them if samples is zero or negative, and
+// compares them with the contents of the file.
+func VerifyDAGFile(path string, samples int) error {
+	epoch, err := dagFileEpoch(path)
+	if err != nil {
+		return err
+	}
+	return verifyDAGFile(path, epoch, false, samples)
+}
+
+func verifyDAGFile(path string, epoch uint64, test bool, samples int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dagSize := datasetSize(epoch, test)
+	if err := checkDAGHeader(f, dagSize); err != nil {
+		return err
+	}
+
+	cache := newCache(epoch, test, cacheConfig{})
+	defer cache.release()
+	if cache.generate(); cache.err != nil {
+		return cache.err
+	}
+	return checkDAGItems(f, cache, dagSize, samples)
+}
+
+// checkDAGItems compares items of the DAG file f with the values
+// computed from cache, see VerifyDAGFile.
+func checkDAGItems(f *os.File, cache *cache, dagSize uint64, samples int) error {
+	items := dagSize / dagItemSize
+	check := func(index uint64) error {
+		var want, have [dagItemSize]byte
+		if _, err := f.ReadAt(have[:], int64(dagMagicSize+index*dagItemSize)); err != nil {
+			return err
+		}
+		calcDatasetItem(cache, itemIndex(index), want[:])
+		if want != have {
+			return fmt.Errorf("DAG item %d does not match the value computed from the cache", index)
+		}
+		return nil
+	}
+	if samples <= 0 || uint64(samples) >= items {
+		for i := uint64(0); i < items; i++ {
+			if err := check(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// Always include the last item, truncated writes show up there first.
+	if err := check(items - 1); err != nil {
+		return err
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 1; i < samples; i++ {
+		if err := check(uint64(r.Int63n(int64(items)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dagAuditSamples is the number of dataset items checked when an
+// existing DAG file is loaded.
+const dagAuditSamples = 1000
+
+// corruptSuffix is appended to the name of DAG files that were found
+// to be corrupt. They are kept for inspection, replacing an earlier
+// corrupt file of the same epoch.
+const corruptSuffix = ".corrupt"
+
+// audit checks the existing DAG file of d against cache and moves it
+// aside or repairs it if it is corrupt, so that mining doesn't use a
+// wrong dataset for the whole epoch. Files with checksums are checked
+// completely, others by a random sample of items.
+func (d *dag) audit(cache *cache) {
+	path := filepath.Join(d.dir, dagName(makeSeedHash(d.epoch)))
+	fi, err := os.Stat(path)
+	if err != nil {
+		// checksums of a missing file are outdated.
+		os.Remove(path + checksumSuffix)
+		return
+	}
+	dagSize := datasetSize(d.epoch, d.test)
+	if !fi.Mode().IsRegular() || uint64(fi.Size()) != dagSize+dagMagicSize {
+		d.quarantine(path, fmt.Errorf("file has size %d, want %d", fi.Size(), dagSize+dagMagicSize))
+		return
+	}
+	if !dagFileComplete(d.dir, d.epoch, d.test) {
+		// the generation was interrupted, it is continued from scratch.
+		glog.V(logger.Info).Infof("DAG file for epoch %d is incomplete, regenerating it", d.epoch)
+		os.Remove(path + checksumSuffix)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		// the file may be shared read-only, it can still be checked.
+		f, err = os.Open(path)
+	}
+	if err == nil {
+		err = d.check(f, cache, path)
+		f.Close()
+	}
+	if err != nil {
+		d.quarantine(path, err)
+	}
+}
+
+// check checks and repairs the complete DAG file f at path, see audit.
+func (d *dag) check(f *os.File, cache *cache, path string) error {
+	dagSize := datasetSize(d.epoch, d.test)
+	sums, err := readDAGChecksums(path)
+	if err == nil {
+		if err = repairDAGFile(f, cache, dagSize, sums); err != nil {
+			glog.V(logger.Error).Infof("Can't repair DAG for epoch %d: %v", d.epoch, err)
+		}
+		return err
+	}
+	if !os.IsNotExist(err) {
+		glog.V(logger.Warn).Infof("Ignoring DAG checksums for epoch %d: %v", d.epoch, err)
+	}
+	return checkDAGItems(f, cache, dagSize, dagAuditSamples)
+}
+
+// quarantine moves the corrupt DAG file at path aside, so that it is
+// regenerated, and removes its checksums. It also handles compressed
+// DAG files.
+func (d *dag) quarantine(path string, reason error) {
+	glog.V(logger.Error).Infof("DAG file for epoch %d is corrupt, regenerating it: %v", d.epoch, reason)
+	os.Remove(path + checksumSuffix)
+	if err := os.Rename(path, path+corruptSuffix); err != nil {
+		glog.V(logger.Error).Infof("Can't move corrupt DAG file aside: %v", err)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			glog.V(logger.Error).Infof("Can't remove corrupt DAG file: %v", err)
+		}
+		return
+	}
+	glog.V(logger.Info).Infof("Corrupt DAG file for epoch %d kept as %s", d.epoch, path+corruptSuffix)
+}
+
+// lockSuffix is appended to the DAG file name to get the name of the
+// file locked while the DAG is generated or checked.
+const lockSuffix = ".lock"
+
+// lock takes the lock of the DAG file of d, which is shared by all
+// processes using the same DAG directory.
+func (d *dag) lock() (unlock func(), err error) {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return nil, err
+	}
+	return lockFile(filepath.Join(d.dir, dagName(makeSeedHash(d.epoch))+lockSuffix))
+}
@@ -0,0 +1,377 @@
+package ethash
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDAGFileEpoch(t *testing.T) {
+	for _, epoch := range []uint64{0, 1, 30} {
+		name := dagName(makeSeedHash(epoch))
+		got, err := dagFileEpoch(filepath.Join("dir", name))
+		if err != nil {
+			t.Errorf("epoch %d: %v", epoch, err)
+		} else if got != epoch {
+			t.Errorf("epoch %d: name %s resolved to epoch %d", epoch, name, got)
+		}
+	}
+	if _, err := dagFileEpoch("full-R1-0000000000000000"); err == nil {
+		t.Error("no error for wrong revision")
+	}
+}
+
+func TestVerifyDAGFile(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{difficulty: big.NewInt(10)}
+	eth.Search(block, nil)
+	path := filepath.Join(eth.Full.Dir, dagName(makeSeedHash(0)))
+
+	if err := verifyDAGFile(path, 0, true, 0); err != nil {
+		t.Fatalf("generated DAG did not verify: %v", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := wrapCache(newCache(0, true, cacheConfig{}))
+	c.cache.generate()
+	for _, index := range []uint32{0, 1, 511} {
+		off := dagMagicSize + int(index)*dagItemSize
+		if item := CalcDatasetItem(c, index); !bytes.Equal(item, content[off:off+dagItemSize]) {
+			t.Errorf("item %d: computed %x, DAG file has %x", index, item, content[off:off+dagItemSize])
+		}
+	}
+	if err := VerifyDAGFile(path, 10); err == nil {
+		t.Error("test DAG verified with regular size")
+	}
+
+	// flip a bit in the last item.
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b [1]byte
+	off := int64(dagMagicSize) + int64(dagSizeForTesting) - 1
+	f.ReadAt(b[:], off)
+	b[0] ^= 1
+	f.WriteAt(b[:], off)
+	f.Close()
+	if err := verifyDAGFile(path, 0, true, 1); err == nil {
+		t.Error("corrupted DAG verified")
+	}
+}
+
+func TestDAGAuditOnLoad(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{difficulty: big.NewInt(10)}
+	eth.Search(block, nil)
+	eth.FreeDAG()
+
+	// flip a bit in the middle of the file, which the C code doesn't check.
+	path := filepath.Join(eth.Full.Dir, dagName(makeSeedHash(0)))
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b [1]byte
+	off := int64(dagMagicSize) + int64(dagSizeForTesting)/2
+	f.ReadAt(b[:], off)
+	b[0] ^= 1
+	f.WriteAt(b[:], off)
+	f.Close()
+
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed")
+	}
+	if err := verifyDAGFile(path, 0, true, 0); err != nil {
+		t.Errorf("corrupt DAG file was not regenerated: %v", err)
+	}
+}
+
+func TestCorruptDAGQuarantine(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{difficulty: big.NewInt(10)}
+	eth.Search(block, nil)
+	eth.FreeDAG()
+	path := filepath.Join(eth.Full.Dir, dagName(makeSeedHash(0)))
+
+	corruptions := map[string]func(){
+		"wrong item": func() {
+			// without checksums the file can't be repaired.
+			os.Remove(path + checksumSuffix)
+			content, _ := ioutil.ReadFile(path)
+			for i := dagMagicSize; i < len(content); i++ {
+				content[i] ^= 0xff
+			}
+			ioutil.WriteFile(path, content, 0644)
+		},
+		"truncated": func() {
+			os.Truncate(path, int64(dagMagicSize+dagSizeForTesting/2))
+		},
+		"compressed": func() {
+			os.Remove(path)
+			ioutil.WriteFile(path+compressedSuffix, []byte("not snappy data"), 0644)
+		},
+	}
+	for name, corrupt := range corruptions {
+		kept := path + corruptSuffix
+		if name == "compressed" {
+			kept = path + compressedSuffix + corruptSuffix
+		}
+		os.Remove(kept)
+		corrupt()
+		if _, mix := eth.Search(block, nil); mix == nil {
+			t.Fatalf("%s: search failed", name)
+		}
+		eth.FreeDAG()
+		if err := verifyDAGFile(path, 0, true, 0); err != nil {
+			t.Errorf("%s: DAG file was not regenerated: %v", name, err)
+		}
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("%s: corrupt file not kept: %v", name, err)
+		}
+		if compressedDAGExists(eth.Full.Dir, 0) {
+			t.Errorf("%s: corrupt compressed file not moved", name)
+		}
+	}
+}
+
+func TestUnlockedDAGFilesLeftAlone(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{difficulty: big.NewInt(10)}
+	eth.Search(block, nil)
+	eth.FreeDAG()
+	path := filepath.Join(eth.Full.Dir, dagName(makeSeedHash(0)))
+	os.Remove(path + checksumSuffix)
+	content, _ := ioutil.ReadFile(path)
+	for i := dagMagicSize; i < len(content); i++ {
+		content[i] ^= 0xff
+	}
+	ioutil.WriteFile(path, content, 0644)
+
+	// a directory in place of the lock file makes locking fail.
+	os.Remove(path + lockSuffix)
+	if err := os.Mkdir(path+lockSuffix, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed")
+	}
+	eth.FreeDAG()
+	if _, err := os.Stat(path + corruptSuffix); err == nil {
+		t.Error("DAG file quarantined without the lock")
+	}
+	if _, err := os.Stat(path + checksumSuffix); err == nil {
+		t.Error("checksums written without the lock")
+	}
+	if err := verifyDAGFile(path, 0, true, 0); err == nil {
+		t.Error("DAG file audited without the lock")
+	}
+}
+
+func TestUnwritableDAGDir(t *testing.T) {
+	base, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Chmod(filepath.Join(base, "read-only"), 0755)
+		os.RemoveAll(base)
+	}()
+	readOnly := filepath.Join(base, "read-only")
+	os.Mkdir(readOnly, 0555)
+	notDir := filepath.Join(base, "file")
+	ioutil.WriteFile(notDir, nil, 0644)
+
+	for name, dir := range map[string]string{"read-only": readOnly, "not a directory": notDir} {
+		if name == "read-only" && os.Geteuid() == 0 {
+			// root may write anyway.
+			continue
+		}
+		// generating the DAG fails without crashing the process.
+		pow := &Full{Dir: dir, test: true}
+		if err := <-pow.WarmDAG(context.Background(), 0); err == nil {
+			t.Errorf("%s: WarmDAG succeeded", name)
+		}
+		if _, err := pow.Dataset(0); err == nil {
+			t.Errorf("%s: got a dataset", name)
+		}
+		if pow.Ready(0) {
+			t.Errorf("%s: DAG ready", name)
+		}
+		if n := len(pow.dags.items); n != 0 {
+			t.Errorf("%s: %d failed DAGs held in memory", name, n)
+		}
+	}
+}
+
+func TestDAGChecksumRepair(t *testing.T) {
+	defer func(size uint64) { dagChunkSize = size }(dagChunkSize)
+	dagChunkSize = 4096
+
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{difficulty: big.NewInt(10)}
+	eth.Search(block, nil)
+	eth.FreeDAG()
+
+	path := filepath.Join(eth.Full.Dir, dagName(makeSeedHash(0)))
+	sums, err := readDAGChecksums(path)
+	if err != nil {
+		t.Fatal("no checksums written:", err)
+	}
+	if want := int(dagSizeForTesting / 4096); len(sums.sums) != want {
+		t.Fatalf("got %d checksums, want %d", len(sums.sums), want)
+	}
+	fi, _ := os.Stat(path)
+
+	// corrupt the third chunk.
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{1, 2, 3}, dagMagicSize+2*4096+100)
+	f.Close()
+
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed")
+	}
+	if err := verifyDAGFile(path, 0, true, 0); err != nil {
+		t.Errorf("DAG file was not repaired: %v", err)
+	}
+	if fi2, _ := os.Stat(path); !os.SameFile(fi, fi2) {
+		t.Error("DAG file was regenerated instead of repaired")
+	}
+
+	// checksums are not made up for files that were only spot-checked.
+	eth.FreeDAG()
+	os.Remove(path + checksumSuffix)
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed")
+	}
+	if _, err := os.Stat(path + checksumSuffix); err == nil {
+		t.Error("checksums written for an existing DAG file")
+	}
+}
+
+func TestDatasetHash(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{difficulty: big.NewInt(10)}
+	block.seal(eth.Search(block, nil))
+	ds, err := eth.Full.Dataset(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Release()
+	if mix, _ := ds.Hash(block.hashNoNonce, block.nonce); mix != block.mixDigest {
+		t.Errorf("dataset computed mix digest %x, search found %x", mix, block.mixDigest)
+	}
+}
+
+func TestHashBatch(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	ds, err := eth.Full.Dataset(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Release()
+	c := newCache(0, true, cacheConfig{})
+	defer c.release()
+	c.generate()
+	cache := &Cache{c}
+
+	header := common.HexToHash("0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d")
+	nonces := []uint64{0, 1, 0x495732e0ed7a801c, 1 << 63}
+	for _, h := range []interface {
+		Hash(common.Hash, uint64) (common.Hash, common.Hash)
+		HashBatch(common.Hash, []uint64) ([]common.Hash, []common.Hash)
+	}{ds, cache} {
+		mixes, results := h.HashBatch(header, nonces)
+		if len(mixes) != len(nonces) || len(results) != len(nonces) {
+			t.Fatalf("%T: got %d mix digests and %d results for %d nonces", h, len(mixes), len(results), len(nonces))
+		}
+		for i, nonce := range nonces {
+			if mix, result := h.Hash(header, nonce); mixes[i] != mix || results[i] != result {
+				t.Errorf("%T: nonce %x: batch computed %x, %x, want %x, %x", h, nonce, mixes[i], results[i], mix, result)
+			}
+		}
+		if mixes, _ := h.HashBatch(header, nil); len(mixes) != 0 {
+			t.Errorf("%T: got %d mix digests for no nonces", h, len(mixes))
+		}
+	}
+}
+
+func TestFlushDAGFiles(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	eth.EnableAutoDAG(1)
+	eth.Search(&testBlock{difficulty: big.NewInt(10)}, nil)
+	if !FlushDAGFiles(time.Minute) {
+		t.Fatal("DAG files not flushed")
+	}
+	if !dagFileComplete(eth.Full.Dir, 1, true) {
+		t.Error("pre-generated DAG incomplete after flush")
+	}
+}
+
+func TestFlushDAGFilesTimeout(t *testing.T) {
+	beginDAGWrite()
+	if FlushDAGFiles(10 * time.Millisecond) {
+		t.Error("flushed with a DAG write in progress")
+	}
+	// writes may start while FlushDAGFiles waits.
+	go func() {
+		beginDAGWrite()
+		endDAGWrite()
+		endDAGWrite()
+	}()
+	if !FlushDAGFiles(time.Minute) {
+		t.Error("DAG files not flushed")
+	}
+}
@@ -0,0 +1,285 @@
+package ethash
+
+/*
+#include "src/libethash/internal.h"
+
+void ethashGoHashBatch(ethash_full_t, ethash_light_t, uint64_t, ethash_h256_t, uint64_t const*, size_t, ethash_h256_t*, ethash_h256_t*);
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+// Cache is the verification cache of one epoch, from which any item of
+// the epoch's dataset can be computed. Its memory is freed when the
+// Cache becomes unreachable.
+type Cache struct {
+	cache *cache
+}
+
+// NewCache generates the cache for the given epoch.
+func NewCache(epoch uint64) (*Cache, error) {
+	if epoch >= maxEpoch {
+		return nil, fmt.Errorf("epoch number too high, limit is %d", maxEpoch)
+	}
+	c := newCache(epoch, false, cacheConfig{})
+	if c.generate(); c.err != nil {
+		c.release()
+		return nil, c.err
+	}
+	return wrapCache(c), nil
+}
+
+// LoadCache returns the cache of the given epoch from its serialized
+// form: the contents of a cache file as stored by MakeCache or
+// SetCacheDir and served to a CacheBootstrap, with or without the
+// leading magic number. The cache is not checked, which would take as
+// long as generating it. Callers must get it from a source they trust
+// or compare its SHA-256 checksum with a pin, like CacheBootstrap
+// does.
+func LoadCache(epoch uint64, data []byte) (*Cache, error) {
+	if epoch >= maxEpoch {
+		return nil, fmt.Errorf("epoch number too high, limit is %d", maxEpoch)
+	}
+	size := cacheSize(epoch, false)
+	if uint64(len(data)) == dagMagicSize+size {
+		// the magic number is in host byte order, see checkDAGHeader.
+		var magic uint64
+		copy((*[dagMagicSize]byte)(unsafe.Pointer(&magic))[:], data)
+		if magic != C.ETHASH_DAG_MAGIC_NUM {
+			return nil, errors.New("not a cache file")
+		}
+		data = data[dagMagicSize:]
+	}
+	c, err := cacheFromBytes(epoch, false, data)
+	if err != nil {
+		return nil, err
+	}
+	c.refs.acquire()
+	return wrapCache(c), nil
+}
+
+// cacheFromBytes copies the contents of a cache into C memory. The
+// cache is not in the registry, it is freed when it becomes unreachable
+// or its last reference is released.
+func cacheFromBytes(epoch uint64, test bool, data []byte) (*cache, error) {
+	size := cacheSize(epoch, test)
+	if uint64(len(data)) != size {
+		return nil, fmt.Errorf("cache of epoch %d has %d bytes, want %d", epoch, len(data), size)
+	}
+	light, cdata, err := allocLight(size)
+	if err != nil {
+		return nil, err
+	}
+	copy(cdata, data)
+	c := &cache{epoch: epoch, test: test}
+	c.gen.Do(func() {})
+	c.ready = 1
+	c.setPtr(light, size)
+	return c, nil
+}
+
+// LightVerify checks the seal of block with the serialized cache of its
+// epoch, see LoadCache. It doesn't keep the cache, so it suits
+// verifiers without state that get the cache with each request.
+func LightVerify(cacheData []byte, block pow.Block) (bool, error) {
+	c, err := LoadCache(block.NumberU64()/epochLength, cacheData)
+	if err != nil {
+		return false, err
+	}
+	ok := VerifySeal(ethashAlgorithm{}, c, block)
+	c.cache.release()
+	runtime.SetFinalizer(c, nil)
+	return ok, nil
+}
+
+// wrapCache returns a Cache which takes over the caller's reference.
+func wrapCache(c *cache) *Cache {
+	wrapper := &Cache{c}
+	runtime.SetFinalizer(wrapper, releaseWrapped)
+	return wrapper
+}
+
+// releaseWrapped releases the reference of a Cache once it is
+// unreachable.
+func releaseWrapped(w *Cache) {
+	w.cache.release()
+}
+
+// Epoch returns the epoch the cache belongs to.
+func (c *Cache) Epoch() uint64 {
+	return c.cache.epoch
+}
+
+// cacheBinaryVersion is the first byte of the binary form of a Cache.
+const cacheBinaryVersion = 1
+
+// MarshalBinary returns the cache in a form that UnmarshalBinary reads
+// back: a version byte, a byte that is one for caches of testing
+// instances, the big-endian epoch in 8 bytes and the cache contents.
+func (c *Cache) MarshalBinary() ([]byte, error) {
+	contents, err := cBytes(unsafe.Pointer(c.cache.ptr.cache), c.cache.size)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 10+len(contents))
+	data[0] = cacheBinaryVersion
+	if c.cache.test {
+		data[1] = 1
+	}
+	binary.BigEndian.PutUint64(data[2:], c.cache.epoch)
+	copy(data[10:], contents)
+	runtime.KeepAlive(c)
+	return data, nil
+}
+
+// UnmarshalBinary sets c to the cache returned by MarshalBinary. c must
+// be a zero Cache allocated by itself, e.g. new(Cache), as the cache
+// is released when c becomes unreachable. Like with LoadCache, the
+// contents are not checked.
+func (c *Cache) UnmarshalBinary(data []byte) error {
+	if c.cache != nil {
+		return errors.New("cache already set")
+	}
+	if len(data) < 10 || data[0] != cacheBinaryVersion || data[1] > 1 {
+		return errors.New("not a cache")
+	}
+	epoch := binary.BigEndian.Uint64(data[2:])
+	if epoch >= maxEpoch {
+		return fmt.Errorf("epoch number too high, limit is %d", maxEpoch)
+	}
+	cache, err := cacheFromBytes(epoch, data[1] == 1, data[10:])
+	if err != nil {
+		return err
+	}
+	cache.refs.acquire()
+	c.cache = cache
+	runtime.SetFinalizer(c, releaseWrapped)
+	return nil
+}
+
+// CalcDatasetItem computes the 64 byte dataset item at the given index
+// from the cache, as it is stored in the DAG file of the cache's epoch.
+func CalcDatasetItem(c *Cache, index uint32) []byte {
+	item := make([]byte, dagItemSize)
+	calcDatasetItem(c.cache, index, item)
+	runtime.KeepAlive(c)
+	return item
+}
+
+// maxCBytes bounds the C memory that is accessed as a single Go slice.
+// It is small enough for the array type to be valid on 32 bit platforms.
+const maxCBytes = 1 << 30
+
+// cBytes returns a slice referring to size bytes of C memory at p. The
+// slice is only valid while the owner of the memory is alive and must
+// not be retained, nor passed back to C.
+func cBytes(p unsafe.Pointer, size uint64) ([]byte, error) {
+	if size > maxCBytes {
+		return nil, fmt.Errorf("%d bytes of C memory don't fit into a slice", size)
+	}
+	return (*[maxCBytes]byte)(p)[:size:size], nil
+}
+
+// itemIndex converts a dataset item index for the C code, which uses
+// 32 bit indices. Indices of all supported epochs fit.
+func itemIndex(index uint64) uint32 {
+	if index > math.MaxUint32 {
+		panic(fmt.Sprintf("dataset item index %d overflows uint32", index))
+	}
+	return uint32(index)
+}
+
+// calcDatasetItem computes a dataset item into out, which must have
+// room for dagItemSize bytes. The caller must hold a reference to c.
+func calcDatasetItem(c *cache, index uint32, out []byte) {
+	var item C.node
+	t := cgoCalls.datasetItem.begin()
+	C.ethash_calculate_dag_item(&item, C.uint32_t(index), c.ptr)
+	cgoCalls.datasetItem.end(t)
+	copy(out, (*[dagItemSize]byte)(unsafe.Pointer(&item))[:])
+}
+
+// Dataset is a DAG held for hashing outside of Search, e.g. for
+// benchmarks or external miners sharing the DAG of a Full.
+type Dataset struct {
+	dag *dag
+}
+
+// Dataset returns the DAG for the given block number, generating it if
+// necessary. Release must be called when the Dataset is no longer used.
+func (pow *Full) Dataset(blockNum uint64) (*Dataset, error) {
+	d, err := pow.getDAG(blockNum)
+	if err != nil {
+		return nil, err
+	}
+	return &Dataset{d}, nil
+}
+
+// Epoch returns the epoch the dataset belongs to.
+func (d *Dataset) Epoch() uint64 {
+	return d.dag.epoch
+}
+
+// Hash computes the mix digest and result of a seal for a block of the
+// dataset's epoch. It is safe for concurrent use.
+func (d *Dataset) Hash(headerHash common.Hash, nonce uint64) (mixDigest, result common.Hash) {
+	t := cgoCalls.fullCompute.begin()
+	ret := C.ethash_full_compute(d.dag.ptr, hashToH256(headerHash), C.uint64_t(nonce))
+	cgoCalls.fullCompute.end(t)
+	runtime.KeepAlive(d.dag)
+	return h256ToHash(ret.mix_hash), h256ToHash(ret.result)
+}
+
+// HashBatch is like Hash for many nonces of the same header, which are
+// computed in a single call into the C library. This pays off where the
+// overhead of a call per hash dominates, e.g. when checking batches of
+// shares or generating test vectors.
+func (d *Dataset) HashBatch(headerHash common.Hash, nonces []uint64) (mixDigests, results []common.Hash) {
+	return hashBatch(d.dag.ptr, nil, 0, headerHash, nonces, d.dag)
+}
+
+// hashBatch computes the seals of nonces with full or, if it is nil,
+// with light. owner holds the memory of full or light. The batch counts
+// as a single call in the cgo profile.
+func hashBatch(full *C.struct_ethash_full, light *C.struct_ethash_light, fullSize uint64, headerHash common.Hash, nonces []uint64, owner interface{}) (mixDigests, results []common.Hash) {
+	mixDigests = make([]common.Hash, len(nonces))
+	results = make([]common.Hash, len(nonces))
+	if len(nonces) == 0 {
+		return mixDigests, results
+	}
+	counter := &cgoCalls.fullCompute
+	if full == nil {
+		counter = &cgoCalls.lightCompute
+	}
+	// The slices hold no Go pointers and are only used during the call.
+	t := counter.begin()
+	C.ethashGoHashBatch(
+		full,
+		light,
+		C.uint64_t(fullSize),
+		hashToH256(headerHash),
+		(*C.uint64_t)(unsafe.Pointer(&nonces[0])),
+		C.size_t(len(nonces)),
+		(*C.ethash_h256_t)(unsafe.Pointer(&mixDigests[0])),
+		(*C.ethash_h256_t)(unsafe.Pointer(&results[0])),
+	)
+	counter.end(t)
+	runtime.KeepAlive(owner)
+	return mixDigests, results
+}
+
+// Release drops the reference to the DAG. The Dataset must not be used
+// afterwards.
+func (d *Dataset) Release() {
+	d.dag.release()
+}
@@ -0,0 +1,8 @@
+//go:build !ethash_blake2b
+// +build !ethash_blake2b
+
+package ethash
+
+// dagNameSuffix marks DAG files of research builds, see
+// datasethash_blake2b.go.
+const dagNameSuffix = ""
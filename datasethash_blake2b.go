@@ -0,0 +1,17 @@
+//go:build ethash_blake2b
+// +build ethash_blake2b
+
+// Building with the ethash_blake2b tag replaces the Keccak-512 applied
+// to dataset items by BLAKE2b-512, for experiments with variants of the
+// dataset. Such builds are incompatible with the Ethereum network:
+// their seals don't verify elsewhere and their DAG files are named
+// differently so they can't be mixed up with regular ones.
+
+package ethash
+
+/*
+#cgo CFLAGS: -DETHASH_DATASET_BLAKE2B
+*/
+import "C"
+
+const dagNameSuffix = "-blake2b"
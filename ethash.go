@@ -1,16 +1,19 @@
 package ethash
 
 /*
+#include <stdlib.h>
 #include "src/libethash/internal.h"
 
-int ethashGoCallback_cgo(unsigned);
+ethash_full_t ethashGoFullNew(uintptr_t, char const*, ethash_h256_t, uint64_t, ethash_light_t);
 */
 import "C"
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"os"
@@ -18,11 +21,11 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/pow"
@@ -35,12 +38,29 @@ var (
 
 const (
 	epochLength         uint64     = 30000
+	maxEpoch            uint64     = 2048 // size tables in data_sizes.h end here
 	cacheSizeForTesting C.uint64_t = 1024
 	dagSizeForTesting   C.uint64_t = 1024 * 32
 )
 
 var DefaultDir = defaultDir()
 
+// cacheSize returns the size of the verification cache in bytes.
+func cacheSize(epoch uint64, test bool) uint64 {
+	if test {
+		return uint64(cacheSizeForTesting)
+	}
+	return uint64(C.ethash_get_cachesize(C.uint64_t(epoch * epochLength)))
+}
+
+// datasetSize returns the size of the DAG in bytes.
+func datasetSize(epoch uint64, test bool) uint64 {
+	if test {
+		return uint64(dagSizeForTesting)
+	}
+	return uint64(C.ethash_get_datasize(C.uint64_t(epoch * epochLength)))
+}
+
 func defaultDir() string {
 	home := os.Getenv("HOME")
 	if user, err := user.Current(); err == nil {
@@ -52,76 +72,581 @@ func defaultDir() string {
 	return filepath.Join(home, ".ethash")
 }
 
+// refs counts the references to a C allocation so that it can be
+// freed as soon as the last owner or user is done with it, rather
+// than waiting for the garbage collector to run the finalizer.
+type refs struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (r *refs) acquire() {
+	r.mu.Lock()
+	r.n++
+	r.mu.Unlock()
+}
+
+// release drops a reference. It reports whether it was the last one,
+// in which case the allocation should be freed.
+func (r *refs) release() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.n--
+	return r.n == 0
+}
+
 // cache wraps an ethash_light_t with some metadata
 // and automatic memory management.
 type cache struct {
 	epoch uint64
 	test  bool
+	dir   string // directory the cache file is loaded from and stored in, empty if none
+
+	bootstrap *CacheBootstrap // where to download the cache from, nil if nowhere
+	bad       int32           // set atomically if the downloaded cache turned out wrong
+	ready     int32           // set atomically once generate is done
+	err       error           // set by generate if the cache could not be made
 
-	gen sync.Once // ensures cache is only generated once.
-	ptr *C.struct_ethash_light
+	gen  sync.Once // ensures cache is only generated once.
+	ptr  *C.struct_ethash_light
+	size uint64 // bytes allocated for ptr
+	refs refs   // owners and users of ptr, see release.
 }
 
 // generate creates the actual cache. it can be called from multiple
 // goroutines. the first call will generate the cache, subsequent
-// calls wait until it is generated.
+// calls wait until it is generated. If the cache has a directory, the
+// cache file in it is used instead of generating the cache, or written
+// after generating it.
 func (cache *cache) generate() {
 	cache.gen.Do(func() {
+		defer atomic.StoreInt32(&cache.ready, 1)
 		started := time.Now()
+		size := cacheSize(cache.epoch, cache.test)
+		if cache.dir != "" && !usableDir(cache.dir) {
+			cache.dir = ""
+		}
+		if cache.dir != "" {
+			ptr, err := loadCacheFile(cache.dir, cache.epoch, size)
+			if err == nil {
+				cache.setPtr(ptr, size)
+				cache.record("file", started)
+				return
+			}
+			if !os.IsNotExist(err) {
+				glog.V(logger.Warn).Infof("Regenerating cache for epoch %d: %v", cache.epoch, err)
+			}
+		}
+		if cache.bootstrap != nil {
+			ptr, err := cache.bootstrap.fetch(cache.epoch, size)
+			if err == nil {
+				cache.setPtr(ptr, size)
+				cache.record("download", started)
+				cache.store()
+				cache.refs.acquire()
+				go cache.check()
+				return
+			}
+			glog.V(logger.Warn).Infof("Can't download cache for epoch %d: %v", cache.epoch, err)
+		}
+
 		seedHash := makeSeedHash(cache.epoch)
 		glog.V(logger.Debug).Infof("Generating cache for epoch %d (%x)", cache.epoch, seedHash)
-		size := C.ethash_get_cachesize(C.uint64_t(cache.epoch * epochLength))
-		if cache.test {
-			size = cacheSizeForTesting
+		seed := hashToH256(seedHash)
+		t := cgoCalls.lightNew.begin()
+		ptr := C.ethash_light_new_internal(C.uint64_t(size), &seed)
+		cgoCalls.lightNew.end(t)
+		if ptr == nil {
+			// Don't keep the failed cache for later users, they
+			// should try again.
+			cache.err = fmt.Errorf("can't allocate cache for epoch %d", cache.epoch)
+			shared.evictCache(cache)
+			return
 		}
-		cache.ptr = C.ethash_light_new_internal(size, (*C.ethash_h256_t)(unsafe.Pointer(&seedHash[0])))
-		runtime.SetFinalizer(cache, freeCache)
-		glog.V(logger.Debug).Infof("Done generating cache for epoch %d, it took %v", cache.epoch, time.Since(started))
+		cache.setPtr(ptr, size)
+		cache.record("computed", started)
+		cache.store()
 	})
 }
 
+// record adds the cache, made available from source since started, to
+// the epoch history.
+func (cache *cache) record(source string, started time.Time) {
+	ev := EpochEvent{Kind: "cache", Epoch: cache.epoch, Test: cache.test, Source: source, Started: started, Duration: time.Since(started)}
+	if source == "file" {
+		ev.Dir = cache.dir
+	}
+	recordEpoch(ev)
+}
+
+// store writes the cache file if the cache has a directory.
+func (cache *cache) store() {
+	if cache.dir == "" {
+		return
+	}
+	path := filepath.Join(cache.dir, cacheName(makeSeedHash(cache.epoch)))
+	if err := writeCacheFile(path, cache); err != nil && !os.IsPermission(err) {
+		glog.V(logger.Error).Infof("Can't store cache for epoch %d: %v", cache.epoch, err)
+	}
+}
+
+func (cache *cache) setPtr(ptr *C.struct_ethash_light, size uint64) {
+	cache.ptr = ptr
+	cache.size = size
+	trackAlloc(memory.caches, cache.epoch, cache.size)
+	runtime.SetFinalizer(cache, freeCache)
+}
+
+// release must be called when a reference to the cache obtained from
+// getCache or newCache is no longer used.
+func (cache *cache) release() {
+	if shared.releaseCache(cache) {
+		freeCache(cache)
+	}
+}
+
 func freeCache(cache *cache) {
-	C.ethash_light_delete(cache.ptr)
-	cache.ptr = nil
+	runtime.SetFinalizer(cache, nil)
+	if cache.ptr != nil {
+		C.ethash_light_delete(cache.ptr)
+		cache.ptr = nil
+		trackFree(memory.caches, cache.epoch, cache.size)
+	}
 }
 
 // Light implements the Verify half of the proof of work.
 // It uses a small in-memory cache to verify the nonces
 // found by Full.
+//
+// Light never generates or loads a DAG: it holds no Full and only
+// obtains caches from the registry, so no block passed to its methods
+// can cause a dataset allocation of a gigabyte or more.
 type Light struct {
-	test    bool       // if set use a smaller cache size
-	mu      sync.Mutex // protects current
-	current *cache     // last cache which was generated.
-	// TODO: keep multiple caches.
+	test      bool            // if set use a smaller cache size
+	mu        sync.Mutex      // protects caches, next, dir, bootstrap, chain, head, hasHead, lookahead, floor and trusted
+	caches    lru             // recently used caches
+	next      *cache          // pre-generated cache of an upcoming epoch, see prewarm
+	dir       string          // cache directory, see SetCacheDir
+	bootstrap *CacheBootstrap // see SetCacheBootstrap
+
+	chain     BlockProvider // source of the head, see SetBlockProvider
+	head      uint64        // highest epoch of a block that verified meeting the floor
+	hasHead   bool          // set once such a block has verified
+	lookahead uint64        // epochs past head that may be verified, see SetLookahead
+	floor     *big.Int
+	trusted   uint64 // blocks below this number are not verified
+}
+
+// SetCacheDir makes Light store the caches it generates in dir and
+// load them from there, which is much faster than generating them
+// again after a restart or when verifying blocks of old epochs. Cache
+// files are small and can share the DAG directory. The empty string,
+// the default, keeps caches in memory only.
+//
+// Caches that are in use by other instances in the process are shared
+// regardless of their directory.
+func (l *Light) SetCacheDir(dir string) {
+	l.mu.Lock()
+	l.dir = dir
+	l.mu.Unlock()
+}
+
+// SetCacheBootstrap makes Light download the caches of epochs pinned
+// in b instead of generating them, so that a new node can verify
+// blocks right away. Downloaded caches are also stored in the cache
+// directory, if any. A nil b, the default, disables downloading.
+func (l *Light) SetCacheBootstrap(b *CacheBootstrap) {
+	l.mu.Lock()
+	l.bootstrap = b
+	l.mu.Unlock()
+}
+
+// SetTrustedHeight makes Verify and VerifyUncles accept all blocks with
+// a number below height without checking their seal. This is meant for
+// resyncing history whose validity is known, e.g. up to a checkpoint
+// shipped with the client; blocks below height are not protected in
+// any way. Zero, the default, verifies all blocks.
+func (l *Light) SetTrustedHeight(height uint64) {
+	l.mu.Lock()
+	l.trusted = height
+	l.mu.Unlock()
+}
+
+// isTrusted reports whether the given block number is below the
+// trusted height.
+func (l *Light) isTrusted(blockNum uint64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return blockNum < l.trusted
+}
+
+// ProtocolMinimumDifficulty is the lowest difficulty the Ethereum
+// protocol allows, for use with SetMinDifficulty.
+const ProtocolMinimumDifficulty = 131072
+
+// SetMinDifficulty makes Verify and VerifyUncles reject blocks whose
+// difficulty is lower than min, as required by the chain's rules, e.g.
+// ProtocolMinimumDifficulty. A nil min removes the floor, which is the
+// default.
+func (l *Light) SetMinDifficulty(min *big.Int) {
+	l.mu.Lock()
+	if min != nil {
+		min = new(big.Int).Set(min)
+	}
+	l.floor = min
+	l.mu.Unlock()
+}
+
+// checkFloor returns an error if difficulty is below the minimum set
+// with SetMinDifficulty.
+func (l *Light) checkFloor(difficulty *big.Int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.floor != nil && difficulty != nil && difficulty.Cmp(l.floor) < 0 {
+		return fmt.Errorf("difficulty %v is below the minimum of %v", difficulty, l.floor)
+	}
+	return nil
+}
+
+// defaultLookahead is the number of epochs past the latest verified
+// block for which Light will generate caches.
+const defaultLookahead = 1
+
+// NoLookaheadLimit passed to SetLookahead disables the check, e.g. for
+// test chains whose block numbers jump ahead arbitrarily.
+const NoLookaheadLimit = math.MaxUint64
+
+// ErrTooFarInFuture is returned for blocks whose epoch is further past
+// the latest verified epoch than the lookahead allows.
+var ErrTooFarInFuture = errors.New("block is too far in the future")
+
+// SetLookahead sets how many epochs past the head Verify accepts.
+// Blocks further in the future are rejected with ErrTooFarInFuture
+// before a cache is generated for them. Zero selects the default of one
+// epoch.
+//
+// The head is taken from the BlockProvider if there is one. Otherwise
+// it is the highest of the epoch of the trusted height and the epoch of
+// the highest verified block whose difficulty meets the minimum set
+// with SetMinDifficulty; seals of lower difficulty are cheap to make up,
+// so they don't move the head. Without any of these the lookahead
+// counts from epoch 0, unless a minimum difficulty is set, in which
+// case blocks of any epoch are checked until the first one verifies.
+func (l *Light) SetLookahead(epochs uint64) {
+	l.mu.Lock()
+	l.lookahead = epochs
+	l.mu.Unlock()
+}
+
+// CheckBlockNumber returns an error if Verify would reject blocks with
+// the given number without checking their seal: ErrTooFarInFuture if
+// the block's epoch is beyond the lookahead, or an error if the number
+// is beyond the highest supported epoch.
+func (l *Light) CheckBlockNumber(blockNum uint64) error {
+	if blockNum >= epochLength*maxEpoch {
+		return fmt.Errorf("block number %d too high, limit is %d", blockNum, epochLength*maxEpoch)
+	}
+	return l.checkEpoch(blockNum / epochLength)
+}
+
+// BlockProvider is the part of a chain Light needs: the number of the
+// current head block. Any chain implementation can provide it.
+type BlockProvider interface {
+	CurrentBlockNumber() uint64
+}
+
+// SetBlockProvider makes the lookahead of Verify count from the head
+// of chain rather than from the highest verified block, so it applies
+// from the first block on. nil restores the default.
+func (l *Light) SetBlockProvider(chain BlockProvider) {
+	l.mu.Lock()
+	l.chain = chain
+	l.mu.Unlock()
+}
+
+// checkEpoch returns ErrTooFarInFuture if caches for the given epoch
+// may not be generated yet.
+func (l *Light) checkEpoch(epoch uint64) error {
+	l.mu.Lock()
+	chain, head, hasHead, lookahead := l.chain, l.head, l.hasHead, l.lookahead
+	if trusted := l.trusted / epochLength; l.trusted > 0 && (!hasHead || trusted > head) {
+		head, hasHead = trusted, true
+	}
+	if !hasHead && l.floor == nil {
+		head, hasHead = 0, true
+	}
+	l.mu.Unlock()
+	if chain != nil {
+		// the chain is asked without holding l.mu, it may call back.
+		head, hasHead = chain.CurrentBlockNumber()/epochLength, true
+	}
+	if lookahead == 0 {
+		lookahead = defaultLookahead
+	}
+	if hasHead && epoch > head && epoch-head > lookahead {
+		return ErrTooFarInFuture
+	}
+	return nil
 }
 
-// Verify checks whether the block's nonce is valid.
+// verified records that a block of the given epoch and difficulty
+// passed verification. It only becomes the head if its difficulty meets
+// the floor.
+func (l *Light) verified(epoch uint64, difficulty *big.Int) {
+	l.mu.Lock()
+	if l.floor != nil && difficulty.Cmp(l.floor) >= 0 && (!l.hasHead || epoch > l.head) {
+		l.head, l.hasHead = epoch, true
+	}
+	l.mu.Unlock()
+}
+
+// Verify checks whether the block's nonce is valid. It combines
+// VerifyHeader and VerifySeal, unless the block is below the trusted
+// height, and records the block's epoch as the head for the lookahead.
 func (l *Light) Verify(block pow.Block) bool {
-	// TODO: do ethash_quick_verify before getCache in order
-	// to prevent DOS attacks.
+	if l.isTrusted(block.NumberU64()) {
+		return true
+	}
+	blockNum := block.NumberU64()
+	if err := l.VerifyHeader(block); err != nil {
+		glog.V(logger.Debug).Infof("block %d rejected: %v", blockNum, err)
+		return false
+	}
+	return l.verifyEpoch(blockNum/epochLength, block)
+}
+
+// VerifyWithSeed is like Verify for a block of the epoch of seedHash,
+// e.g. a work package. The block's number is not used, so neither is
+// the trusted height of SetTrustedHeight.
+func (l *Light) VerifyWithSeed(seedHash common.Hash, block pow.Block) bool {
+	epoch, ok := seedEpoch(seedHash)
+	if !ok {
+		glog.V(logger.Debug).Infof("block %x rejected: unknown seed hash %x", block.HashNoNonce(), seedHash)
+		return false
+	}
+	err := l.checkEpoch(epoch)
+	if err == nil {
+		err = l.checkFloor(block.Difficulty())
+	}
+	if err != nil {
+		glog.V(logger.Debug).Infof("block %x rejected: %v", block.HashNoNonce(), err)
+		return false
+	}
+	return l.verifyEpoch(epoch, block)
+}
+
+// VerifyHeader checks what Verify checks besides the seal: that the
+// block's epoch is supported and within the lookahead of the head, see
+// CheckBlockNumber, that its difficulty is positive, see
+// CheckDifficulty, and that it is at least the minimum set with
+// SetMinDifficulty.
+func (l *Light) VerifyHeader(block pow.Block) error {
+	if err := l.CheckBlockNumber(block.NumberU64()); err != nil {
+		return err
+	}
+	if err := CheckDifficulty(block.Difficulty()); err != nil {
+		return err
+	}
+	return l.checkFloor(block.Difficulty())
+}
+
+// VerifySeal checks the block's nonce and mix digest with the cache of
+// the epoch of its number. Unlike Verify it is stateless: the head,
+// lookahead, minimum difficulty and trusted height are neither consulted
+// nor updated. Seals that fail the quick check are rejected before a
+// cache is generated.
+func (l *Light) VerifySeal(block pow.Block) bool {
+	blockNum := block.NumberU64()
+	if blockNum >= epochLength*maxEpoch {
+		return false
+	}
+	return l.verifySeal(blockNum/epochLength, block)
+}
+
+// verifyEpoch checks the block's seal with the cache of epoch and
+// records epoch as verified.
+func (l *Light) verifyEpoch(epoch uint64, block pow.Block) bool {
+	if !l.verifySeal(epoch, block) {
+		return false
+	}
+	l.verified(epoch, block.Difficulty())
+	return true
+}
+
+// verifySeal checks the block's seal with the cache of epoch.
+func (l *Light) verifySeal(epoch uint64, block pow.Block) bool {
+	// Check the seal using the mix digest before getCache, so
+	// bogus blocks don't cause cache generation.
+	if !QuickVerify(block) {
+		glog.V(logger.Debug).Infof("block %d rejected by quick check", block.NumberU64())
+		return false
+	}
+	started := time.Now()
+	cache, built, err := l.getCache(epoch * epochLength)
+	if err != nil {
+		glog.V(logger.Error).Infof("Can't verify block %d: %v", block.NumberU64(), err)
+		return false
+	}
+	defer cache.release()
+	ok := l.verify(cache, block)
+	observeVerify(started, built)
+	return ok
+}
+
+// BlockWithUncles is a block whose uncle seals can be checked
+// with VerifyUncles.
+type BlockWithUncles interface {
+	Uncles() []pow.Block
+}
+
+// VerifyUncles checks the nonces of all uncles included in block.
+// Uncles from the same epoch share a single cache lookup.
+func (l *Light) VerifyUncles(block BlockWithUncles) error {
+	caches := make(map[uint64]*cache)
+	for i, uncle := range block.Uncles() {
+		blockNum := uncle.NumberU64()
+		if l.isTrusted(blockNum) {
+			continue
+		}
+		if err := l.CheckBlockNumber(blockNum); err != nil {
+			return fmt.Errorf("uncle %d: %v", i, err)
+		}
+		if err := CheckDifficulty(uncle.Difficulty()); err != nil {
+			return fmt.Errorf("uncle %d: %v", i, err)
+		}
+		if err := l.checkFloor(uncle.Difficulty()); err != nil {
+			return fmt.Errorf("uncle %d: %v", i, err)
+		}
+		if !QuickVerify(uncle) {
+			hash := uncle.HashNoNonce()
+			return fmt.Errorf("uncle %d (%x) has invalid nonce or mix digest", i, hash[:4])
+		}
+		epoch := blockNum / epochLength
+		started := time.Now()
+		c, built := caches[epoch], false
+		if c == nil {
+			var err error
+			if c, built, err = l.getCache(blockNum); err != nil {
+				return fmt.Errorf("uncle %d: %v", i, err)
+			}
+			defer c.release()
+			caches[epoch] = c
+		}
+		ok := l.verify(c, uncle)
+		observeVerify(started, built)
+		if !ok {
+			hash := uncle.HashNoNonce()
+			return fmt.Errorf("uncle %d (%x) has invalid nonce", i, hash[:4])
+		}
+	}
+	return nil
+}
+
+// DifficultyError is the error for a block whose difficulty is
+// missing, zero or negative. No seal meets such a difficulty.
+type DifficultyError struct {
+	Difficulty *big.Int // nil if the block has no difficulty
+}
+
+func (e *DifficultyError) Error() string {
+	if e.Difficulty == nil {
+		return "block has no difficulty"
+	}
+	return fmt.Sprintf("invalid difficulty %v, must be positive", e.Difficulty)
+}
+
+// CheckDifficulty returns a *DifficultyError unless difficulty is
+// positive. Seals are only checked or searched for difficulties that
+// pass.
+func CheckDifficulty(difficulty *big.Int) error {
+	if difficulty == nil || difficulty.Sign() <= 0 {
+		return &DifficultyError{difficulty}
+	}
+	return nil
+}
+
+// QuickVerify checks whether the block's mix digest and nonce meet its
+// difficulty, see PrecheckSeal. Verify performs this check before
+// using the cache.
+func QuickVerify(block pow.Block) bool {
+	return PrecheckSeal(block.HashNoNonce(), block.Nonce(), block.MixDigest(), block.Difficulty())
+}
+
+// PrecheckSeal checks whether the seal given by nonce and mixDigest
+// meets the difficulty for the header hash (without nonce). It takes
+// two Keccak hashes and no cache, so it is cheap enough to run on every
+// block received from the network, before queuing it for verification.
+//
+// The guarantee is weaker than that of Verify: PrecheckSeal does not
+// check that mixDigest was computed from the dataset. Anyone can pick a
+// mix digest which passes for any header by hashing about as often as
+// difficulty, without the memory that mining requires. It only raises
+// the cost of spam to that of hashing at the block's difficulty; blocks
+// passing it must still be verified before they are trusted.
+func PrecheckSeal(headerHash common.Hash, nonce uint64, mixDigest common.Hash, difficulty *big.Int) bool {
+	if CheckDifficulty(difficulty) != nil {
+		return false
+	}
+	target := new(big.Int).Div(minDifficulty, difficulty)
+	if target.BitLen() > 256 {
+		// difficulty 1, every hash meets it.
+		return true
+	}
 	var (
-		blockNum   = block.NumberU64()
-		difficulty = block.Difficulty()
-		cache      = l.getCache(blockNum)
-		dagSize    = C.ethash_get_datasize(C.uint64_t(blockNum))
+		hash     = hashToH256(headerHash)
+		mix      = hashToH256(mixDigest)
+		boundary = hashToH256(common.BigToHash(target))
 	)
-	if l.test {
-		dagSize = dagSizeForTesting
+	return bool(C.ethash_quick_check_difficulty(&hash, C.uint64_t(nonce), &mix, &boundary))
+}
+
+// ComputeMixDigest computes the mix digest and result of the seal with
+// the given nonce for a header of epoch, using the verification caches
+// of l. This lets a pool fill in the mix digest for miners submitting
+// only nonces; whether the result meets the work's target is up to the
+// caller to check.
+func (l *Light) ComputeMixDigest(epoch uint64, hashNoNonce common.Hash, nonce uint64) (mixDigest, result common.Hash, err error) {
+	if epoch >= maxEpoch {
+		return common.Hash{}, common.Hash{}, fmt.Errorf("epoch number too high, limit is %d", maxEpoch)
 	}
-	if blockNum >= epochLength*2048 {
-		glog.V(logger.Debug).Infof("block number %d too high, limit is %d", epochLength*2048)
+	cache, _, err := l.getCache(epoch * epochLength)
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	defer cache.release()
+	t := cgoCalls.lightCompute.begin()
+	ret := C.ethash_light_compute_internal(cache.ptr, C.uint64_t(datasetSize(epoch, cache.test)), hashToH256(hashNoNonce), C.uint64_t(nonce))
+	cgoCalls.lightCompute.end(t)
+	if !ret.success {
+		return common.Hash{}, common.Hash{}, errors.New("light computation failed")
+	}
+	return h256ToHash(ret.mix_hash), h256ToHash(ret.result), nil
+}
+
+// verify checks the block's nonce and mix digest against the given
+// cache, which must belong to the block's epoch and be held by the
+// caller.
+func (l *Light) verify(cache *cache, block pow.Block) bool {
+	var (
+		difficulty = block.Difficulty()
+		dagSize    = C.uint64_t(datasetSize(cache.epoch, cache.test))
+	)
+	if CheckDifficulty(difficulty) != nil {
 		return false
 	}
 	// Recompute the hash using the cache.
 	hash := hashToH256(block.HashNoNonce())
+	t := cgoCalls.lightCompute.begin()
 	ret := C.ethash_light_compute_internal(cache.ptr, dagSize, hash, C.uint64_t(block.Nonce()))
+	cgoCalls.lightCompute.end(t)
 	if !ret.success {
 		return false
 	}
-	// Make sure cache is live until after the C call.
-	// This is important because a GC might happen and execute
-	// the finalizer before the call completes.
-	_ = cache
+	// The mix digest is not part of HashNoNonce, a block with a
+	// valid nonce but a different mix digest must not verify.
+	if h256ToHash(ret.mix_hash) != block.MixDigest() {
+		return false
+	}
 	// The actual check.
 	target := new(big.Int).Div(minDifficulty, difficulty)
 	return h256ToHash(ret.result).Big().Cmp(target) <= 0
@@ -135,21 +660,117 @@ func hashToH256(in common.Hash) C.ethash_h256_t {
 	return C.ethash_h256_t{b: *(*[32]C.uint8_t)(unsafe.Pointer(&in[0]))}
 }
 
-func (l *Light) getCache(blockNum uint64) *cache {
+// getCache returns the cache for the given block's epoch and whether
+// it had to wait for the cache to be generated or loaded. The caller
+// must release the cache when done with it, unless an error is
+// returned.
+func (l *Light) getCache(blockNum uint64) (*cache, bool, error) {
 	var c *cache
 	epoch := blockNum / epochLength
-	// Update or reuse the last cache.
+	// Reuse a recent cache or get a new one.
 	l.mu.Lock()
-	if l.current != nil && l.current.epoch == epoch {
-		c = l.current
+	if item := l.caches.get(epoch); item != nil && atomic.LoadInt32(&item.(*cache).bad) == 0 {
+		c = item.(*cache)
 	} else {
-		c = &cache{epoch: epoch, test: l.test}
-		l.current = c
+		l.caches.remove(epoch)
+		c = newCache(epoch, l.test, cacheConfig{dir: l.dir, bootstrap: l.bootstrap})
+		l.caches.add(c)
+	}
+	c.refs.acquire()
+	if l.next != nil && l.next.epoch == epoch {
+		// the lru took over the pre-generated cache.
+		l.next.release()
+		l.next = nil
 	}
 	l.mu.Unlock()
 	// Wait for the cache to finish generating.
-	c.generate()
-	return c
+	built := atomic.LoadInt32(&c.ready) == 0
+	if c.generate(); c.err != nil {
+		l.mu.Lock()
+		if item := l.caches.get(epoch); item == c {
+			l.caches.remove(epoch)
+		}
+		l.mu.Unlock()
+		c.release()
+		return nil, built, c.err
+	}
+	return c, built, nil
+}
+
+// prewarm starts generating the cache of the given epoch in the
+// background, so that verifying its first blocks doesn't wait for it.
+// The cache is held outside the lru, which would evict the cache in
+// use, until getCache takes it over. Only the cache of the most
+// recently pre-warmed epoch is held.
+//
+// Epochs beyond the lookahead of the head are not pre-warmed, nor are
+// any before the head is known, so that blocks with made up numbers
+// can't make Light generate caches of far future epochs.
+func (l *Light) prewarm(epoch uint64) {
+	if l.isTrusted(epoch*epochLength) || l.CheckBlockNumber(epoch*epochLength) != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.hasHead && l.chain == nil {
+		return
+	}
+	if l.next != nil && l.next.epoch == epoch {
+		return
+	}
+	for _, item := range l.caches.items {
+		if item.epochNum() == epoch {
+			return
+		}
+	}
+	if l.next != nil {
+		l.next.release()
+	}
+	c := newCache(epoch, l.test, cacheConfig{dir: l.dir, bootstrap: l.bootstrap})
+	l.next = c
+	c.refs.acquire()
+	go func() {
+		defer c.release()
+		glog.V(logger.Info).Infof("Pre-generating cache for epoch %d", epoch)
+		if c.generate(); c.err != nil {
+			glog.V(logger.Error).Infof("Can't pre-generate cache for epoch %d: %v", epoch, c.err)
+		}
+	}()
+}
+
+// SetCachesInMem sets the number of verification caches kept in
+// memory. Caches of the least recently verified epochs are released
+// when the limit is exceeded. The default of one suits following the
+// chain; services verifying blocks of arbitrary epochs, e.g. for
+// several chains or historical data, benefit from more.
+//
+// Each cache takes about 16 MiB at epoch 0, growing by 128 KiB per
+// epoch, and is shared with the other instances verifying the same
+// epoch. See MemoryNeeded for the total.
+func (l *Light) SetCachesInMem(n int) {
+	l.mu.Lock()
+	l.caches.setMax(n)
+	l.mu.Unlock()
+}
+
+// CachesInMem returns the number of verification caches kept in memory.
+func (l *Light) CachesInMem() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.caches.limit()
+}
+
+// FreeCache releases the verification caches. Verify calls that are
+// in progress and other instances keep using them, the memory is freed
+// when the last of them is done. A later Verify regenerates the cache.
+func (l *Light) FreeCache() {
+	l.mu.Lock()
+	l.caches.clear()
+	if l.next != nil {
+		l.next.release()
+		l.next = nil
+	}
+	l.mu.Unlock()
 }
 
 // dag wraps an ethash_full_t with some metadata
@@ -159,70 +780,165 @@ type dag struct {
 	test  bool
 	dir   string
 
-	gen sync.Once // ensures DAG is only generated once.
-	ptr *C.struct_ethash_full
+	gen  sync.Once // ensures DAG is only generated once.
+	ptr  *C.struct_ethash_full
+	size uint64 // bytes mapped for ptr
+	refs refs   // owners and users of ptr, see release.
+
+	limits   GenerationLimits // resource limits while generating in the background
+	urgent   int32            // set atomically once a Search waits for the DAG
+	lastStep time.Time        // time of the last progress report
+	compress bool             // compress the file when the DAG is freed
+	clock    Clock            // times the throttling, nil for the system clock
+	gpu      bool             // generate the file on the GPU, see SetGPUDAG
+	ready    int32            // set atomically once ptr is generated
+	err      error            // why generate failed, ptr is nil then
 }
 
 // generate creates the actual DAG. it can be called from multiple
 // goroutines. the first call will generate the DAG, subsequent
-// calls wait until it is generated.
+// calls wait until it is generated. If it can't be, d.err is set.
 func (d *dag) generate() {
 	d.gen.Do(func() {
+		beginDAGWrite()
+		defer endDAGWrite()
 		var (
-			started   = time.Now()
-			seedHash  = makeSeedHash(d.epoch)
-			blockNum  = C.uint64_t(d.epoch * epochLength)
-			cacheSize = C.ethash_get_cachesize(blockNum)
-			dagSize   = C.ethash_get_datasize(blockNum)
+			started  = time.Now()
+			seedHash = makeSeedHash(d.epoch)
+			blockNum = C.uint64_t(d.epoch * epochLength)
+			dagSize  = C.ethash_get_datasize(blockNum)
 		)
 		if d.test {
-			cacheSize = cacheSizeForTesting
 			dagSize = dagSizeForTesting
 		}
-		if d.dir == "" {
-			d.dir = DefaultDir
-		}
 		glog.V(logger.Info).Infof("Generating DAG for epoch %d (%x)", d.epoch, seedHash)
-		// Generate a temporary cache.
-		// TODO: this could share the cache with Light
-		cache := C.ethash_light_new_internal(cacheSize, (*C.ethash_h256_t)(unsafe.Pointer(&seedHash[0])))
-		defer C.ethash_light_delete(cache)
+		if !usableDir(d.dir) {
+			d.err = fmt.Errorf("DAG directory %s was written by a newer version", d.dir)
+			return
+		}
+		// Get a cache, this shares the generation with a concurrent
+		// Verify for the same epoch.
+		cache := newCache(d.epoch, d.test, cacheConfig{dir: d.dir})
+		defer cache.release()
+		if cache.generate(); cache.err != nil {
+			d.err = cache.err
+			return
+		}
+		// Other processes may use the same DAG directory. The lock
+		// ensures only one of them writes the file, the others map it
+		// read-only once it is complete.
+		// Compressed files are only replaced while holding the lock,
+		// another process may be decompressing the same file. Without
+		// the lock the files are not audited, quarantined or given
+		// checksums either, another process may be writing them.
+		locked := false
+		if unlock, err := d.lock(); err != nil {
+			glog.V(logger.Debug).Infof("Can't lock DAG for epoch %d: %v", d.epoch, err)
+		} else {
+			defer unlock()
+			locked = true
+			if err := decompressDAGFile(d.dir, d.epoch); isCorruptInput(err) {
+				d.quarantine(filepath.Join(d.dir, dagName(seedHash)+compressedSuffix), err)
+			} else if err != nil {
+				glog.V(logger.Error).Infof("Can't decompress DAG for epoch %d: %v", d.epoch, err)
+			}
+		}
+		if locked {
+			d.audit(cache)
+		}
+		source := "computed"
+		if dagFileComplete(d.dir, d.epoch, d.test) {
+			source = "file"
+		} else if d.gpu {
+			if err := d.writeFileGPU(cache); err != nil {
+				glog.V(logger.Warn).Infof("Can't generate DAG for epoch %d on the GPU, using the CPU: %v", d.epoch, err)
+			} else {
+				source = "gpu"
+			}
+		}
 		// Generate the actual DAG.
-		d.ptr = C.ethash_full_new_internal(
-			C.CString(d.dir),
+		// C code must not keep Go pointers, so the progress callback
+		// finds d through a handle and the directory is copied to C
+		// memory, which the C code only uses during the call.
+		handle := progress.add(d)
+		defer progress.remove(handle)
+		dir := C.CString(d.dir)
+		defer C.free(unsafe.Pointer(dir))
+		t := cgoCalls.fullNew.begin()
+		d.ptr = C.ethashGoFullNew(
+			C.uintptr_t(handle),
+			dir,
 			hashToH256(seedHash),
 			dagSize,
-			cache,
-			(C.ethash_callback_t)(unsafe.Pointer(C.ethashGoCallback_cgo)),
+			cache.ptr,
 		)
+		cgoCalls.fullNew.end(t)
 		if d.ptr == nil {
-			panic("ethash_full_new IO or memory error")
+			d.err = fmt.Errorf("can't generate DAG for epoch %d in %s: IO or memory error", d.epoch, d.dir)
+			return
+		}
+		if locked && source != "file" {
+			if err := d.writeChecksums(); err != nil && !os.IsPermission(err) {
+				glog.V(logger.Error).Infof("Can't write DAG checksums for epoch %d: %v", d.epoch, err)
+			}
 		}
+		d.size = uint64(dagSize)
+		trackAlloc(memory.dags, d.epoch, d.size)
 		runtime.SetFinalizer(d, freeDAG)
-		glog.V(logger.Info).Infof("Done generating DAG for epoch %d, it took %v", d.epoch, time.Since(started))
+		recordEpoch(EpochEvent{Kind: "DAG", Epoch: d.epoch, Test: d.test, Source: source, Dir: d.dir, Started: started, Duration: time.Since(started)})
+		atomic.StoreInt32(&d.ready, 1)
 	})
 }
 
-func freeDAG(h *dag) {
-	C.ethash_full_delete(h.ptr)
-	h.ptr = nil
+// release must be called when a reference to the DAG obtained from
+// getDAG or newDAG is no longer used.
+func (d *dag) release() {
+	if shared.releaseDAG(d) {
+		freeDAG(d)
+	}
 }
 
-//export ethashGoCallback
-func ethashGoCallback(percent C.unsigned) C.int {
-	glog.V(logger.Info).Infof("Still generating DAG: %d%%", percent)
-	return 0
+func freeDAG(h *dag) {
+	runtime.SetFinalizer(h, nil)
+	if h.ptr != nil {
+		C.ethash_full_delete(h.ptr)
+		h.ptr = nil
+		trackFree(memory.dags, h.epoch, h.size)
+		if h.compress {
+			compressDAG(h)
+		}
+	}
 }
 
 // MakeDAG pre-generates a DAG file for the given block number in the
 // given directory. If dir is the empty string, the default directory
 // is used.
 func MakeDAG(blockNum uint64, dir string) error {
-	d := &dag{epoch: blockNum / epochLength, dir: dir}
 	if blockNum >= epochLength*2048 {
 		return fmt.Errorf("block number too high, limit is %d", epochLength*2048)
 	}
+	return MakeDataset(blockNum/epochLength, dir)
+}
+
+// MakeDataset pre-generates the DAG file for the given epoch in the
+// given directory. If dir is the empty string, the default directory
+// is used. The DAG is unmapped again once it has been written.
+func MakeDataset(epoch uint64, dir string) error {
+	if epoch >= maxEpoch {
+		return fmt.Errorf("epoch number too high, limit is %d", maxEpoch)
+	}
+	if dir == "" {
+		dir = DefaultDir
+	}
+	if err := checkDir(dir); err != nil {
+		return err
+	}
+	d := newDAG(epoch, false, dir, dagConfig{})
+	defer d.release()
 	d.generate()
+	if d.err != nil {
+		return d.err
+	}
 	if d.ptr == nil {
 		return errors.New("failed")
 	}
@@ -230,82 +946,435 @@ func MakeDAG(blockNum uint64, dir string) error {
 }
 
 // Full implements the Search half of the proof of work.
+//
+// Several processes can use the same DAG directory. Each DAG file is
+// generated by one of them and mapped read-only by all others, so the
+// machine holds a single copy in its page cache. Within a process, all
+// instances using the same directory share the DAG of an epoch, see
+// SetDAGSharing for instances using different directories.
 type Full struct {
 	Dir string // use this to specify a non-default DAG directory
 
-	test     bool // if set use a smaller DAG size
-	turbo    bool
-	hashRate int64
+	test     bool  // if set use a smaller DAG size
+	turbo    int32 // non-zero if turbo is on, accessed atomically
+	pacer    pacer // limits hashing speed when turbo is off
+	hashrate hashrateMeter
+
+	mu        sync.Mutex      // protects dags and the auto DAG settings
+	dags      lru             // recently used DAGs
+	noAutoDAG bool            // if set, DAGs are never generated
+	dagsAhead uint64          // number of future epochs to pre-generate
+	pregen    map[uint64]bool // epochs for which pre-generation was started
+	genLimits GenerationLimits
+	compress  bool       // compress DAG files of unused epochs
+	shareDirs bool       // use DAGs of other directories, see SetDAGSharing
+	gpuDAG    bool       // generate DAG files on the GPU, see SetGPUDAG
+	hook      SearchHook // called every hookEvery hashes of a search
+	hookEvery uint64
+	onSolved  SolutionHook // see SetSolutionHook
+	clock     Clock        // see SetClock, nil for the system clock
+
+	searches int32 // number of search loops started, accessed atomically
+}
+
+// SetDatasetsInMem sets the number of DAGs kept in memory. DAGs of
+// the least recently mined epochs are unmapped when the limit is
+// exceeded. Each DAG takes more than a gigabyte of memory, the default
+// of one is enough unless blocks of several epochs are mined at once,
+// e.g. around an epoch boundary with competing forks.
+//
+// A DAG takes about 1 GiB at epoch 0, growing by 8 MiB per epoch. DAGs
+// pre-generated with EnableAutoDAG are held in addition while they are
+// generated. See MemoryNeeded for the total.
+func (pow *Full) SetDatasetsInMem(n int) {
+	pow.mu.Lock()
+	pow.dags.setMax(n)
+	pow.mu.Unlock()
+}
+
+// DatasetsInMem returns the number of DAGs kept in memory.
+func (pow *Full) DatasetsInMem() int {
+	pow.mu.Lock()
+	defer pow.mu.Unlock()
+	return pow.dags.limit()
+}
 
-	mu      sync.Mutex // protects dag
-	current *dag       // current full DAG
+// getDAG returns the DAG for the given block's epoch, waiting for it
+// to be generated. The caller must release the DAG when done with it.
+func (pow *Full) getDAG(blockNum uint64) (d *dag, err error) {
+	if d, err = pow.acquireDAG(blockNum); err != nil {
+		return nil, err
+	}
+	// Don't throttle the generation if it was started in the background.
+	atomic.StoreInt32(&d.urgent, 1)
+	// wait for it to finish generating.
+	d.generate()
+	if d.err != nil {
+		pow.dropDAG(d)
+		d.release()
+		return nil, d.err
+	}
+	return d, nil
 }
 
-func (pow *Full) getDAG(blockNum uint64) (d *dag) {
+// dropDAG removes d from the DAGs held in memory if it failed to
+// generate, so that the epoch is tried again once it is released by
+// its other users.
+func (pow *Full) dropDAG(d *dag) {
+	pow.mu.Lock()
+	defer pow.mu.Unlock()
+	for _, item := range pow.dags.items {
+		if item == epochItem(d) {
+			pow.dags.remove(d.epoch)
+			return
+		}
+	}
+}
+
+// acquireDAG returns a reference to the DAG for the given block's
+// epoch, which may not be generated yet. The caller must release it.
+func (pow *Full) acquireDAG(blockNum uint64) (d *dag, err error) {
 	epoch := blockNum / epochLength
 	pow.mu.Lock()
-	if pow.current != nil && pow.current.epoch == epoch {
-		d = pow.current
+	defer pow.mu.Unlock()
+	if item := pow.dags.get(epoch); item != nil {
+		d = item.(*dag)
 	} else {
-		d = &dag{epoch: epoch, test: pow.test, dir: pow.Dir}
-		pow.current = d
+		if d = pow.sharedDAG(epoch); d == nil {
+			if pow.noAutoDAG && !dagFileComplete(pow.dir(), epoch, pow.test) && !compressedDAGExists(pow.dir(), epoch) {
+				return nil, fmt.Errorf("no DAG for epoch %d and automatic DAG generation is disabled", epoch)
+			}
+			d = newDAG(epoch, pow.test, pow.Dir, pow.dagConfig())
+		}
+		pow.dags.add(d)
+	}
+	d.refs.acquire()
+	pow.pregenerate(epoch)
+	return d, nil
+}
+
+// WarmDAG starts loading or generating the DAG for the given block's
+// epoch in the background, so that a later Search can start hashing
+// at once. The returned channel receives nil when the DAG is ready,
+// ctx.Err() if ctx is done first, or an error if the DAG can't be
+// made, e.g. because automatic DAG generation is disabled or the
+// directory was written by a newer version. Generation
+// is not interrupted when ctx is done, it stays among the DAGs kept
+// in memory like the ones used by Search.
+//
+// Unlike Search, WarmDAG keeps to the generation limits. Use Ready to
+// check on the DAG without waiting.
+func (pow *Full) WarmDAG(ctx context.Context, blockNum uint64) <-chan error {
+	errc := make(chan error, 1)
+	d, err := pow.acquireDAG(blockNum)
+	if err != nil {
+		errc <- err
+		return errc
 	}
+	done := make(chan struct{})
+	// count the write before the goroutine is scheduled, so that
+	// FlushDAGFiles waits for it.
+	beginDAGWrite()
+	go func() {
+		defer endDAGWrite()
+		defer close(done)
+		defer d.release()
+		d.generate()
+		if d.err != nil {
+			pow.dropDAG(d)
+		}
+	}()
+	go func() {
+		select {
+		case <-done:
+			errc <- d.err
+		case <-ctx.Done():
+			errc <- ctx.Err()
+		}
+	}()
+	return errc
+}
+
+// Ready reports whether the DAG for the given block's epoch is in
+// memory, i.e. whether Search can mine the block without waiting for
+// the DAG.
+func (pow *Full) Ready(blockNum uint64) bool {
+	pow.mu.Lock()
+	defer pow.mu.Unlock()
+	for _, item := range pow.dags.items {
+		if d := item.(*dag); d.epoch == blockNum/epochLength {
+			return atomic.LoadInt32(&d.ready) == 1
+		}
+	}
+	return false
+}
+
+// SetDAGSharing makes pow use the DAGs other instances in the process
+// hold for an epoch even if they are stored in another directory, e.g.
+// for a node with a data directory per chain, instead of mapping its
+// own copy of the dataset. Such DAGs are not stored in pow's directory.
+// It is off by default.
+func (pow *Full) SetDAGSharing(on bool) {
+	pow.mu.Lock()
+	pow.shareDirs = on
+	pow.mu.Unlock()
+}
+
+// sharedDAG returns a reference to the DAG of another directory for
+// the given epoch if DAG sharing is on, or nil. pow.mu must be held.
+func (pow *Full) sharedDAG(epoch uint64) *dag {
+	if !pow.shareDirs {
+		return nil
+	}
+	return shared.findDAG(epoch, pow.test, pow.Dir)
+}
+
+// dir returns the directory containing the DAG files.
+func (pow *Full) dir() string {
+	if pow.Dir == "" {
+		return DefaultDir
+	}
+	return pow.Dir
+}
+
+// EnableAutoDAG allows Search to generate the DAGs it needs, which is
+// the default. In addition, the DAG files of the given number of epochs
+// following the one being mined are generated in the background, so
+// that mining can continue without delay when the chain reaches them.
+func (pow *Full) EnableAutoDAG(ahead uint64) {
+	pow.mu.Lock()
+	pow.noAutoDAG = false
+	pow.dagsAhead = ahead
+	pow.mu.Unlock()
+}
+
+// DisableAutoDAG prevents any DAG generation by this instance. Search
+// then only mines on epochs whose DAG is already in memory or has been
+// completely generated on disk, e.g. with MakeDataset, and returns
+// without a nonce for other epochs.
+func (pow *Full) DisableAutoDAG() {
+	pow.mu.Lock()
+	pow.noAutoDAG = true
+	pow.dagsAhead = 0
 	pow.mu.Unlock()
-	// wait for it to finish generating.
-	d.generate()
-	return d
 }
 
+// dagConfig returns the settings for DAGs created by pow. The caller
+// must hold pow.mu.
+func (pow *Full) dagConfig() dagConfig {
+	return dagConfig{limits: pow.genLimits, compress: pow.compress, clock: pow.clock, gpu: pow.gpuDAG}
+}
+
+// pregenerate starts background generation of the DAG files following
+// the given epoch. pow.mu must be held.
+func (pow *Full) pregenerate(epoch uint64) {
+	if pow.noAutoDAG {
+		return
+	}
+	for next := epoch + 1; next <= epoch+pow.dagsAhead && next < maxEpoch; next++ {
+		if pow.pregen[next] {
+			continue
+		}
+		if pow.pregen == nil {
+			pow.pregen = make(map[uint64]bool)
+		}
+		pow.pregen[next] = true
+		d := pow.sharedDAG(next)
+		if d == nil {
+			d = newDAG(next, pow.test, pow.Dir, pow.dagConfig())
+		}
+		// count the write before the goroutine is scheduled, so that
+		// FlushDAGFiles waits for it.
+		beginDAGWrite()
+		go func() {
+			defer endDAGWrite()
+			defer d.release()
+			glog.V(logger.Info).Infof("Pre-generating DAG for epoch %d", d.epoch)
+			if d.generate(); d.err != nil {
+				glog.V(logger.Error).Infof("Can't pre-generate DAG for epoch %d: %v", d.epoch, d.err)
+			}
+		}()
+	}
+}
+
+// Search looks for a nonce satisfying the block's difficulty until one
+// is found or stop is closed. It waits for the DAG of the block's epoch
+// if it isn't ready, see WarmDAG to make it ahead of time.
 func (pow *Full) Search(block pow.Block, stop <-chan struct{}) (nonce uint64, mixDigest []byte) {
-	dag := pow.getDAG(block.NumberU64())
+	nonce, mixDigest, _ = pow.search(block, stop, nil)
+	return nonce, mixDigest
+}
+
+// SearchWithSeed is like Search for a block of the epoch of seedHash,
+// e.g. a work package, whose number is not used. It returns a nil mix
+// digest for unknown seed hashes.
+func (pow *Full) SearchWithSeed(seedHash common.Hash, block pow.Block, stop <-chan struct{}) (nonce uint64, mixDigest []byte) {
+	epoch, ok := seedEpoch(seedHash)
+	if !ok {
+		glog.V(logger.Warn).Infof("Can't mine block %x: unknown seed hash %x", block.HashNoNonce(), seedHash)
+		return 0, nil
+	}
+	nonce, mixDigest, _ = pow.search(epochBlock{block, epoch * epochLength}, stop, nil)
+	return nonce, mixDigest
+}
+
+// epochBlock presents a block as the first block of an epoch.
+type epochBlock struct {
+	pow.Block
+	number uint64
+}
 
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+func (b epochBlock) NumberU64() uint64 { return b.number }
+
+// search looks for a nonce satisfying the block's difficulty until
+// one is found or stop is closed. If abort is not nil, it is called
+// every workPollInterval and the search is abandoned when it returns
+// true.
+func (pow *Full) search(block pow.Block, stop <-chan struct{}, abort func() bool) (nonce uint64, mixDigest []byte, found bool) {
+	if err := CheckDifficulty(block.Difficulty()); err != nil {
+		glog.V(logger.Warn).Infof("Can't mine block %d: %v", block.NumberU64(), err)
+		return 0, nil, false
+	}
+	dag, err := pow.getDAG(block.NumberU64())
+	if err != nil {
+		glog.V(logger.Warn).Infof("Can't mine block %d: %v", block.NumberU64(), err)
+		return 0, nil, false
+	}
+	defer dag.release()
+
+	pow.mu.Lock()
+	hook, hookEvery, clock := pow.hook, pow.hookEvery, orSystem(pow.clock)
+	pow.mu.Unlock()
 	diff := block.Difficulty()
 
-	i := int64(0)
-	starti := i
-	start := time.Now().UnixNano()
+	pow.hashrate.start()
+	defer pow.hashrate.stop()
+
+	worker := int(atomic.AddInt32(&pow.searches, 1))
+	started := clock.Now()
+	// The nonces are seeded from the system clock rather than pow's,
+	// which may be fake and stand still, and from the worker, so that
+	// concurrent searches don't start from the same nonce.
+	r := rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(worker)<<32))
 
 	nonce = uint64(r.Int63())
 	hash := hashToH256(block.HashNoNonce())
 	target := new(big.Int).Div(minDifficulty, diff)
-	for {
+	lastPoll := clock.Now()
+	for i := 1; ; i++ {
 		select {
 		case <-stop:
-			pow.hashRate = 0
-			return 0, nil
+			return 0, nil, false
 		default:
-			i++
-
-			elapsed := time.Now().UnixNano() - start
-			hashes := ((float64(1e9) / float64(elapsed)) * float64(i-starti)) / 1000
-			pow.hashRate = int64(hashes)
-
+			t := cgoCalls.fullCompute.begin()
 			ret := C.ethash_full_compute(dag.ptr, hash, C.uint64_t(nonce))
+			cgoCalls.fullCompute.end(t)
+			pow.hashrate.mark(1)
+			if hook != nil && uint64(i)%hookEvery == 0 {
+				hook(worker, uint64(i), clock.Now().Sub(started))
+			}
 			result := h256ToHash(ret.result).Big()
 
 			// TODO: disagrees with the spec https://github.com/ethereum/wiki/wiki/Ethash#mining
 			if ret.success && result.Cmp(target) <= 0 {
 				mixDigest = C.GoBytes(unsafe.Pointer(&ret.mix_hash), C.int(32))
-				return nonce, mixDigest
+				pow.solved(block, nonce, mixDigest)
+				return nonce, mixDigest, true
 			}
 			nonce += 1
 		}
 
-		if !pow.turbo {
-			time.Sleep(20 * time.Microsecond)
+		if atomic.LoadInt32(&pow.turbo) == 0 {
+			pow.pacer.wait()
+		}
+		if abort != nil && i%1024 == 0 && clock.Now().Sub(lastPoll) >= workPollInterval {
+			if abort() {
+				return 0, nil, false
+			}
+			lastPoll = clock.Now()
 		}
 	}
 }
 
+// FreeDAG releases the in-memory DAGs. Search calls that are in
+// progress and other instances using the same directory keep using
+// them, the memory is unmapped when the last of them is done. The DAG
+// files stay on disk and are loaded again by the next Search.
+func (pow *Full) FreeDAG() {
+	pow.mu.Lock()
+	pow.dags.clear()
+	pow.mu.Unlock()
+}
+
+// GetHashrate returns the combined hash rate of all running Search
+// calls in kH/s. It is zero when no search is in progress.
 func (pow *Full) GetHashrate() int64 {
-	// TODO: this needs to use an atomic operation.
-	return pow.hashRate
+	return int64(pow.hashrate.rate() / 1000)
 }
 
 func (pow *Full) Turbo(on bool) {
-	// TODO: this needs to use an atomic operation.
-	pow.turbo = on
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&pow.turbo, v)
+}
+
+// SearchHook is called by a search loop every few hashes. worker
+// identifies the Search call, attempts is the number of hashes it has
+// computed and elapsed the time since it started hashing.
+type SearchHook func(worker int, attempts uint64, elapsed time.Duration)
+
+// SetSearchHook installs a hook called every n hashes by each search
+// loop started after the call. The hook runs on the search goroutine
+// and delays it, it should return quickly. A nil hook or n of zero
+// removes the hook.
+func (pow *Full) SetSearchHook(n uint64, hook SearchHook) {
+	pow.mu.Lock()
+	defer pow.mu.Unlock()
+	if n == 0 {
+		hook = nil
+	}
+	pow.hook, pow.hookEvery = hook, n
+}
+
+// SolutionHook is called by a search loop with the block it found a
+// nonce for and the seal, before the Search call returns them.
+type SolutionHook func(block pow.Block, nonce uint64, mixDigest common.Hash)
+
+// SetSolutionHook installs a hook called the moment a search loop
+// finds a nonce, e.g. to start propagating the sealed block before it
+// reaches the caller of Search, Mine or a Controller. It is called for
+// every nonce found, including ones the caller then drops because the
+// work was replaced. The hook runs on the search goroutine, it should
+// return quickly. A nil hook removes it.
+func (pow *Full) SetSolutionHook(hook SolutionHook) {
+	pow.mu.Lock()
+	pow.onSolved = hook
+	pow.mu.Unlock()
+}
+
+// solved calls the solution hook, if any, for a nonce found for block.
+func (pow *Full) solved(block pow.Block, nonce uint64, mixDigest []byte) {
+	pow.mu.Lock()
+	hook := pow.onSolved
+	pow.mu.Unlock()
+	if hook == nil {
+		return
+	}
+	// blocks mined by SearchWithSeed are reported as given.
+	if b, ok := block.(epochBlock); ok {
+		block = b.Block
+	}
+	hook(block, nonce, common.BytesToHash(mixDigest))
+}
+
+// SetPace sets the average time between two hashes while turbo mode
+// is off. The limit applies to all Search calls combined, so a pace of
+// time.Second/n caps the hash rate at n hashes per second. A pace of
+// zero restores the default.
+func (pow *Full) SetPace(interval time.Duration) {
+	pow.pacer.setInterval(interval)
 }
 
 // Ethash combines block verification with Light and
@@ -319,7 +1388,17 @@ type Ethash struct {
 // A single instance of Light is shared across all instances
 // created with New.
 func New() *Ethash {
-	return &Ethash{sharedLight, &Full{turbo: true}}
+	return &Ethash{sharedLight, &Full{turbo: 1}}
+}
+
+// NewWithChain is like New for a chain providing its head block. The
+// instance has its own Light, whose lookahead follows the head of
+// chain, see Light.SetBlockProvider. Caches are still shared with other
+// instances.
+func NewWithChain(chain BlockProvider) *Ethash {
+	light := new(Light)
+	light.SetBlockProvider(chain)
+	return &Ethash{light, &Full{turbo: 1}}
 }
 
 // NewForTesting creates a proof of work for use in unit tests.
@@ -343,10 +1422,3 @@ func GetSeedHash(blockNum uint64) ([]byte, error) {
 	sh := makeSeedHash(blockNum / epochLength)
 	return sh[:], nil
 }
-
-func makeSeedHash(epoch uint64) (sh common.Hash) {
-	for ; epoch > 0; epoch-- {
-		sh = crypto.Sha3Hash(sh[:])
-	}
-	return sh
-}
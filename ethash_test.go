@@ -2,6 +2,7 @@ package ethash
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"log"
@@ -9,8 +10,10 @@ import (
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/pow"
 )
 
 func init() {
@@ -32,6 +35,11 @@ func (b *testBlock) Nonce() uint64            { return b.nonce }
 func (b *testBlock) MixDigest() common.Hash   { return b.mixDigest }
 func (b *testBlock) NumberU64() uint64        { return b.number }
 
+// seal sets the nonce and mix digest returned by Search.
+func (b *testBlock) seal(nonce uint64, mixDigest []byte) {
+	b.nonce, b.mixDigest = nonce, common.BytesToHash(mixDigest)
+}
+
 var validBlocks = []*testBlock{
 	// from proof of concept nine testnet, epoch 0
 	{
@@ -39,6 +47,7 @@ var validBlocks = []*testBlock{
 		hashNoNonce: common.HexToHash("372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d"),
 		difficulty:  big.NewInt(132416),
 		nonce:       0x495732e0ed7a801c,
+		mixDigest:   common.HexToHash("2f74cdeb198af0b9abe65d22d372e22fb2d474371774a9583c1cc427a07939f5"),
 	},
 	// from proof of concept nine testnet, epoch 1
 	{
@@ -46,6 +55,7 @@ var validBlocks = []*testBlock{
 		hashNoNonce: common.HexToHash("7e44356ee3441623bc72a683fd3708fdf75e971bbe294f33e539eedad4b92b34"),
 		difficulty:  big.NewInt(1532671),
 		nonce:       0x318df1c8adef7e5e,
+		mixDigest:   common.HexToHash("144b180aad09ae3c81fb07be92c8e6351b5646dda80e6844ae1b697e55ddde84"),
 	},
 	// from proof of concept nine testnet, epoch 2
 	{
@@ -53,11 +63,13 @@ var validBlocks = []*testBlock{
 		hashNoNonce: common.HexToHash("5fc898f16035bf5ac9c6d9077ae1e3d5fc1ecc3c9fd5bee8bb00e810fdacbaa0"),
 		difficulty:  big.NewInt(2467358),
 		nonce:       0x50377003e5d830ca,
+		mixDigest:   common.HexToHash("ab546a5b73c452ae86dadd36f0ed83a6745226717d3798832d1b20b489e82063"),
 	},
 }
 
 func TestEthashVerifyValid(t *testing.T) {
 	eth := New()
+	eth.SetLookahead(NoLookaheadLimit)
 	for i, block := range validBlocks {
 		if !eth.Verify(block) {
 			t.Errorf("block %d (%x) did not validate.", i, block.hashNoNonce[:6])
@@ -65,6 +77,53 @@ func TestEthashVerifyValid(t *testing.T) {
 	}
 }
 
+func TestEthashQuickVerify(t *testing.T) {
+	for i, block := range validBlocks {
+		if !QuickVerify(block) {
+			t.Errorf("block %d (%x) did not pass the quick check", i, block.hashNoNonce[:6])
+		}
+	}
+
+	b := validBlocks[1]
+	if !PrecheckSeal(b.hashNoNonce, b.nonce, b.mixDigest, b.difficulty) {
+		t.Error("valid seal failed the precheck")
+	}
+	if PrecheckSeal(b.hashNoNonce, b.nonce+1, b.mixDigest, b.difficulty) {
+		t.Error("seal with wrong nonce passed the precheck")
+	}
+	if PrecheckSeal(b.hashNoNonce, b.nonce, b.mixDigest, big.NewInt(0)) {
+		t.Error("seal with zero difficulty passed the precheck")
+	}
+
+	// a block of an epoch without cache is rejected before generating one.
+	bogus := *validBlocks[0]
+	bogus.number = 1000 * epochLength
+	bogus.mixDigest[0] ^= 1
+	light := new(Light)
+	if light.Verify(&bogus) {
+		t.Error("block with wrong mix digest verified")
+	}
+	if len(light.caches.items) != 0 {
+		t.Error("cache generated for block failing the quick check")
+	}
+
+	// a mix digest meeting the difficulty must also match the dataset.
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	block := &testBlock{difficulty: big.NewInt(1)}
+	block.seal(eth.Search(block, nil))
+	block.mixDigest[0] ^= 1
+	if !QuickVerify(block) {
+		t.Fatal("quick check failed at difficulty 1")
+	}
+	if eth.Verify(block) {
+		t.Error("block with modified mix digest verified")
+	}
+}
+
 func TestEthashConcurrentVerify(t *testing.T) {
 	eth, err := NewForTesting()
 	if err != nil {
@@ -73,8 +132,7 @@ func TestEthashConcurrentVerify(t *testing.T) {
 	defer os.RemoveAll(eth.Full.Dir)
 
 	block := &testBlock{difficulty: big.NewInt(10)}
-	nonce, _ := eth.Search(block, nil)
-	block.nonce = nonce
+	block.seal(eth.Search(block, nil))
 
 	// Verify the block concurrently to check for data races.
 	var wg sync.WaitGroup
@@ -90,6 +148,41 @@ func TestEthashConcurrentVerify(t *testing.T) {
 	wg.Wait()
 }
 
+func TestLightNeverMakesDAG(t *testing.T) {
+	light := &Light{test: true}
+	defer light.FreeCache()
+
+	// every verification entry point, with blocks that pass the quick
+	// check and so reach the cache. The epoch is not used by other
+	// tests.
+	const epoch = 19
+	block := &testBlock{number: epoch * epochLength, difficulty: big.NewInt(1)}
+	light.Verify(block)
+	light.VerifySeal(block)
+	light.VerifyHeader(block)
+	light.VerifyWithSeed(makeSeedHash(epoch), block)
+	uncles := NewFakeBlock(epoch*epochLength+1, common.Hash{}, big.NewInt(1))
+	uncles.AddUncle(block)
+	light.VerifyUncles(uncles)
+	if _, _, err := light.ComputeMixDigest(epoch, block.hashNoNonce, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if light.caches.get(epoch) == nil {
+		t.Fatal("no cache generated")
+	}
+	shared.mu.Lock()
+	for key := range shared.dags {
+		if key.epoch == epoch {
+			t.Errorf("DAG of epoch %d registered by verification", epoch)
+		}
+	}
+	shared.mu.Unlock()
+	if n := MemoryStats().DAGs[epoch]; n != 0 {
+		t.Errorf("%d bytes of DAG in memory after verification", n)
+	}
+}
+
 func TestEthashConcurrentSearch(t *testing.T) {
 	eth, err := NewForTesting()
 	if err != nil {
@@ -103,16 +196,17 @@ func TestEthashConcurrentSearch(t *testing.T) {
 		block   = &testBlock{difficulty: big.NewInt(35000)}
 		nsearch = 10
 		wg      = new(sync.WaitGroup)
-		found   = make(chan uint64)
+		found   = make(chan *testBlock)
 		stop    = make(chan struct{})
 	)
 	rand.Read(block.hashNoNonce[:])
 	wg.Add(nsearch)
 	for i := 0; i < nsearch; i++ {
 		go func() {
-			nonce, _ := eth.Search(block, stop)
+			sealed := *block
+			sealed.seal(eth.Search(block, stop))
 			select {
-			case found <- nonce:
+			case found <- &sealed:
 			case <-stop:
 			}
 			wg.Done()
@@ -120,12 +214,12 @@ func TestEthashConcurrentSearch(t *testing.T) {
 	}
 
 	// wait for one of them to find the nonce
-	nonce := <-found
+	sealed := <-found
 	// stop the others
 	close(stop)
 	wg.Wait()
 
-	if block.nonce = nonce; !eth.Verify(block) {
+	if !eth.Verify(sealed) {
 		t.Error("Block could not be verified")
 	}
 }
@@ -140,14 +234,529 @@ func TestEthashSearchAcrossEpoch(t *testing.T) {
 	for i := epochLength - 40; i < epochLength+40; i++ {
 		block := &testBlock{number: i, difficulty: big.NewInt(90)}
 		rand.Read(block.hashNoNonce[:])
-		nonce, _ := eth.Search(block, nil)
-		block.nonce = nonce
+		block.seal(eth.Search(block, nil))
 		if !eth.Verify(block) {
 			t.Fatalf("Block could not be verified")
 		}
 	}
 }
 
+func TestSolutionHook(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	var (
+		calls int
+		got   pow.Block
+		seal  testBlock
+	)
+	eth.SetSolutionHook(func(block pow.Block, nonce uint64, mixDigest common.Hash) {
+		calls++
+		got, seal.nonce, seal.mixDigest = block, nonce, mixDigest
+	})
+	block := &testBlock{difficulty: big.NewInt(10)}
+	nonce, mixDigest := eth.Search(block, nil)
+	if calls != 1 || got != block || seal.nonce != nonce || seal.mixDigest != common.BytesToHash(mixDigest) {
+		t.Errorf("hook called %d times with %v, %x, %x; Search returned %x, %x", calls, got, seal.nonce, seal.mixDigest, nonce, mixDigest)
+	}
+	// SearchWithSeed reports the block it was given.
+	eth.SearchWithSeed(makeSeedHash(0), block, nil)
+	if calls != 2 || got != block {
+		t.Errorf("hook called %d times with %v after SearchWithSeed, want the block searched", calls, got)
+	}
+	eth.SetSolutionHook(nil)
+	eth.Search(block, nil)
+	if calls != 2 {
+		t.Error("removed hook called")
+	}
+}
+
+func TestEthashCachesInMem(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	if n := eth.CachesInMem(); n != 1 {
+		t.Errorf("default of %d caches, want 1", n)
+	}
+	eth.SetCachesInMem(2)
+	if n := eth.CachesInMem(); n != 2 {
+		t.Errorf("CachesInMem returned %d after setting 2", n)
+	}
+
+	var blocks []*testBlock
+	for _, number := range []uint64{10, epochLength + 10} {
+		block := &testBlock{number: number, difficulty: big.NewInt(10)}
+		block.seal(eth.Search(block, nil))
+		blocks = append(blocks, block)
+	}
+	eth.Verify(blocks[0])
+	c := eth.Light.caches.get(0)
+	for _, block := range append(blocks, blocks...) {
+		if !eth.Verify(block) {
+			t.Fatalf("block %d could not be verified", block.number)
+		}
+	}
+	if len(eth.Light.caches.items) != 2 {
+		t.Errorf("%d caches in memory, want 2", len(eth.Light.caches.items))
+	}
+	if eth.Light.caches.get(0) != c {
+		t.Error("cache of epoch 0 replaced while verifying blocks of two epochs")
+	}
+	eth.FreeCache()
+}
+
+type testUncleBlock struct {
+	uncles []pow.Block
+}
+
+func (b *testUncleBlock) Uncles() []pow.Block { return b.uncles }
+
+func TestEthashVerifyUncles(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	// uncles on both sides of an epoch boundary.
+	block := new(testUncleBlock)
+	for _, num := range []uint64{epochLength - 2, epochLength - 1, epochLength} {
+		uncle := &testBlock{number: num, difficulty: big.NewInt(10)}
+		rand.Read(uncle.hashNoNonce[:])
+		uncle.seal(eth.Search(uncle, nil))
+		block.uncles = append(block.uncles, uncle)
+	}
+	if err := eth.VerifyUncles(block); err != nil {
+		t.Fatalf("valid uncles did not verify: %v", err)
+	}
+
+	bad := &testBlock{number: epochLength, difficulty: big.NewInt(1000000)}
+	block.uncles = append(block.uncles, bad)
+	if err := eth.VerifyUncles(block); err == nil {
+		t.Fatal("invalid uncle verified")
+	}
+}
+
+func TestEthashInvalidDifficulty(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	for _, diff := range []*big.Int{nil, big.NewInt(0), big.NewInt(-10)} {
+		if err, ok := CheckDifficulty(diff).(*DifficultyError); !ok {
+			t.Errorf("difficulty %v: got error %v, want *DifficultyError", diff, err)
+		}
+		block := &testBlock{difficulty: diff}
+		if _, mix := eth.Search(block, nil); mix != nil {
+			t.Errorf("difficulty %v: search found a nonce", diff)
+		}
+		if eth.Verify(block) {
+			t.Errorf("difficulty %v: block verified", diff)
+		}
+		if _, ok := eth.Light.VerifyHeader(block).(*DifficultyError); !ok {
+			t.Errorf("difficulty %v: header did not fail with *DifficultyError", diff)
+		}
+		if err := eth.VerifyUncles(&testUncleBlock{[]pow.Block{block}}); err == nil {
+			t.Errorf("difficulty %v: uncle verified", diff)
+		}
+	}
+	if err := CheckDifficulty(big.NewInt(1)); err != nil {
+		t.Errorf("difficulty 1: %v", err)
+	}
+}
+
+func TestEthashMinDifficulty(t *testing.T) {
+	light := new(Light)
+	block := validBlocks[0]
+	light.SetMinDifficulty(big.NewInt(ProtocolMinimumDifficulty))
+	if !light.Verify(block) {
+		t.Fatal("block above the minimum difficulty did not verify")
+	}
+	light.SetMinDifficulty(new(big.Int).Add(block.difficulty, big.NewInt(1)))
+	if light.Verify(block) {
+		t.Error("block below the minimum difficulty verified")
+	}
+	if err := light.VerifyUncles(&testUncleBlock{[]pow.Block{block}}); err == nil {
+		t.Error("uncle below the minimum difficulty verified")
+	}
+	light.SetMinDifficulty(nil)
+	if !light.Verify(block) {
+		t.Error("block did not verify after removing the minimum")
+	}
+}
+
+func TestEthashTrustedHeight(t *testing.T) {
+	light := &Light{test: true}
+	bad := &testBlock{number: 100, difficulty: big.NewInt(1000000)}
+	light.SetTrustedHeight(100)
+	if light.Verify(bad) {
+		t.Error("block at the trusted height verified without a valid seal")
+	}
+	light.SetTrustedHeight(101)
+	if !light.Verify(bad) {
+		t.Error("block below the trusted height rejected")
+	}
+	if err := light.VerifyUncles(&testUncleBlock{[]pow.Block{bad}}); err != nil {
+		t.Errorf("uncle below the trusted height rejected: %v", err)
+	}
+	if len(light.caches.items) != 0 {
+		t.Error("cache generated for trusted block")
+	}
+}
+
+func TestEthashConcurrentUse(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	// results are per call, concurrent searches and verifications of
+	// one instance must not interfere.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				block := &testBlock{difficulty: big.NewInt(50)}
+				rand.Read(block.hashNoNonce[:])
+				block.seal(eth.Search(block, nil))
+				eth.Turbo(j%2 == 0)
+				if !eth.Verify(block) {
+					t.Errorf("block %x could not be verified", block.hashNoNonce)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEthashVerifyDuringSearch(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth.Turbo(true)
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+
+	// a search that won't find a nonce must not hold locks that other
+	// searches, verification or settings need.
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		eth.Search(&testBlock{difficulty: new(big.Int).Lsh(big.NewInt(1), 255)}, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	for eth.GetHashrate() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	finished := make(chan bool)
+	go func() {
+		block := &testBlock{difficulty: big.NewInt(10)}
+		block.seal(eth.Search(block, nil))
+		eth.SetDatasetsInMem(2)
+		eth.SetCachesInMem(2)
+		finished <- eth.Verify(block)
+	}()
+	select {
+	case ok := <-finished:
+		if !ok {
+			t.Error("block mined during another search could not be verified")
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("blocked by a running search")
+	}
+}
+
+func TestEthashWarmDAG(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Full.FreeDAG()
+	defer eth.Light.FreeCache()
+
+	// the epochs are not used by other tests.
+	const epoch, missing = 23, 29
+	eth.SetLookahead(NoLookaheadLimit)
+	if eth.Ready(epoch * epochLength) {
+		t.Fatal("DAG ready before it was generated")
+	}
+	if err := <-eth.WarmDAG(context.Background(), epoch*epochLength); err != nil {
+		t.Fatal(err)
+	}
+	if !eth.Ready(epoch*epochLength + 1) {
+		t.Fatal("DAG not ready after WarmDAG")
+	}
+	if eth.Ready(missing * epochLength) {
+		t.Error("other epoch ready")
+	}
+	eth.DisableAutoDAG()
+	if err := <-eth.WarmDAG(context.Background(), missing*epochLength); err == nil {
+		t.Error("no error warming a DAG with automatic generation disabled")
+	}
+	if eth.Ready(missing * epochLength) {
+		t.Error("DAG ready with automatic generation disabled")
+	}
+	// the warmed DAG is mined on without generation.
+	block := &testBlock{number: epoch * epochLength, difficulty: big.NewInt(10)}
+	block.seal(eth.Search(block, nil))
+	if !eth.Verify(block) {
+		t.Error("block mined on the warmed DAG could not be verified")
+	}
+}
+
+func TestEthashFreeDuringSearch(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth.Turbo(true)
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+	eth.SetLookahead(NoLookaheadLimit)
+
+	// start a search that won't find a nonce, then free the DAG under it.
+	// The epoch is not used by other tests, whose instances share caches.
+	block := &testBlock{number: 7 * epochLength, difficulty: new(big.Int).Lsh(big.NewInt(1), 255)}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		eth.Search(block, stop)
+		close(done)
+	}()
+	for eth.GetHashrate() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d, _ := eth.Full.getDAG(block.number)
+	d.release()
+	eth.FreeDAG()
+	time.Sleep(10 * time.Millisecond)
+	if d.ptr == nil {
+		t.Fatal("DAG freed while search was running")
+	}
+	if stats := MemoryStats(); stats.DAGs[7] < d.size {
+		t.Errorf("DAG of %d bytes missing from memory stats %v", d.size, stats.DAGs)
+	}
+	close(stop)
+	<-done
+	if d.ptr != nil {
+		t.Error("DAG not freed after search returned")
+	}
+
+	// the DAG is loaded again on demand.
+	block.difficulty = big.NewInt(10)
+	if block.seal(eth.Search(block, nil)); !eth.Verify(block) {
+		t.Error("block mined after FreeDAG could not be verified")
+	}
+	c := eth.Light.caches.get(7).(*cache)
+	if MemoryStats().Caches[7] < c.size {
+		t.Errorf("cache of %d bytes missing from memory stats", c.size)
+	}
+	eth.FreeCache()
+	if c.ptr != nil {
+		t.Error("unused cache not freed")
+	}
+	if !eth.Verify(block) {
+		t.Error("block could not be verified after FreeCache")
+	}
+}
+
+func TestEthashVerifyLookahead(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	mine := func(num uint64) *testBlock {
+		block := &testBlock{number: num, difficulty: big.NewInt(10)}
+		rand.Read(block.hashNoNonce[:])
+		block.seal(eth.Search(block, nil))
+		return block
+	}
+	head, next, two, future := mine(0), mine(epochLength), mine(2*epochLength), mine(3*epochLength)
+
+	// without a head, the lookahead counts from epoch 0.
+	if eth.Verify(future) {
+		t.Error("block three epochs ahead of epoch 0 verified without a head")
+	}
+	if err := eth.CheckBlockNumber(future.number); err != ErrTooFarInFuture {
+		t.Errorf("block three epochs ahead: got error %v, want ErrTooFarInFuture", err)
+	}
+	// seals below the floor are cheap to make up and don't move the head.
+	if !eth.Verify(next) {
+		t.Fatal("block one epoch ahead could not be verified")
+	}
+	if eth.Verify(two) {
+		t.Error("block below the floor moved the head")
+	}
+
+	// with a floor, any epoch is checked until the first block verifies.
+	eth.Light = &Light{test: true}
+	eth.SetMinDifficulty(big.NewInt(10))
+	if !eth.Verify(future) {
+		t.Fatal("future block rejected before first verification")
+	}
+	eth.Light = &Light{test: true}
+	eth.SetMinDifficulty(big.NewInt(10))
+	if !eth.Verify(head) {
+		t.Fatal("head block could not be verified")
+	}
+	if eth.Verify(future) {
+		t.Error("block three epochs ahead verified with default lookahead")
+	}
+	if !eth.Verify(next) {
+		t.Error("block one epoch ahead could not be verified")
+	}
+	eth.SetLookahead(2)
+	if !eth.Verify(future) {
+		t.Error("block two epochs ahead of new head rejected with lookahead 2")
+	}
+	eth.SetLookahead(NoLookaheadLimit)
+	if err := eth.CheckBlockNumber(epochLength*maxEpoch - 1); err != nil {
+		t.Errorf("last epoch rejected without lookahead limit: %v", err)
+	}
+
+	// the trusted height is a head.
+	eth.Light = &Light{test: true}
+	eth.SetTrustedHeight(2 * epochLength)
+	if !eth.Verify(future) {
+		t.Error("block one epoch past the trusted height rejected")
+	}
+	if err := eth.CheckBlockNumber(4 * epochLength); err != ErrTooFarInFuture {
+		t.Errorf("block two epochs past the trusted height: got error %v, want ErrTooFarInFuture", err)
+	}
+}
+
+func TestSeedVariants(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+
+	// the number of the block is wrong, only the seed hash counts.
+	seed := makeSeedHash(1)
+	block := &testBlock{difficulty: big.NewInt(10), hashNoNonce: common.HexToHash("0x01")}
+	block.seal(eth.SearchWithSeed(seed, block, nil))
+	if !eth.VerifyWithSeed(seed, block) {
+		t.Error("block sealed for the seed hash did not verify with it")
+	}
+	if eth.Verify(block) {
+		t.Error("block sealed for epoch 1 verified as a block of epoch 0")
+	}
+	if eth.VerifyWithSeed(makeSeedHash(2), block) {
+		t.Error("block verified with the seed hash of another epoch")
+	}
+
+	unknown := common.HexToHash("0x02")
+	if _, mix := eth.SearchWithSeed(unknown, block, nil); mix != nil {
+		t.Error("search with unknown seed hash found a seal")
+	}
+	if eth.VerifyWithSeed(unknown, block) {
+		t.Error("block verified with unknown seed hash")
+	}
+}
+
+func TestVerifyHeaderAndSeal(t *testing.T) {
+	light := new(Light)
+	defer light.FreeCache()
+	block := *validBlocks[0]
+
+	light.SetMinDifficulty(big.NewInt(1 << 20))
+	light.SetBlockProvider(new(testChain))
+	if err := light.VerifyHeader(&block); err == nil {
+		t.Error("header with difficulty below the minimum passed")
+	}
+	if !light.VerifySeal(&block) {
+		t.Error("valid seal rejected because of the minimum difficulty")
+	}
+	if light.Verify(&block) {
+		t.Error("block with difficulty below the minimum verified")
+	}
+	light.SetMinDifficulty(nil)
+
+	future := *validBlocks[2]
+	if err := light.VerifyHeader(&future); err != ErrTooFarInFuture {
+		t.Errorf("header two epochs past the head: got error %v, want ErrTooFarInFuture", err)
+	}
+	if err := light.VerifyHeader(&block); err != nil {
+		t.Errorf("valid header rejected: %v", err)
+	}
+
+	block.mixDigest[0] ^= 1
+	if light.VerifySeal(&block) {
+		t.Error("seal with wrong mix digest verified")
+	}
+	if light.hasHead {
+		t.Error("VerifySeal recorded a head")
+	}
+}
+
+func TestComputeMixDigest(t *testing.T) {
+	light := new(Light)
+	defer light.FreeCache()
+	b := validBlocks[0]
+	mix, result, err := light.ComputeMixDigest(0, b.hashNoNonce, b.nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mix != b.mixDigest {
+		t.Errorf("got mix digest %x, want %x", mix, b.mixDigest)
+	}
+	if !PrecheckSeal(b.hashNoNonce, b.nonce, mix, b.difficulty) || result.Big().Cmp(new(big.Int).Div(minDifficulty, b.difficulty)) > 0 {
+		t.Errorf("result %x does not meet the difficulty of the block", result)
+	}
+	if _, _, err := light.ComputeMixDigest(maxEpoch, b.hashNoNonce, b.nonce); err == nil {
+		t.Error("no error for epoch beyond the limit")
+	}
+}
+
+type testChain uint64
+
+func (c *testChain) CurrentBlockNumber() uint64 { return uint64(*c) }
+
+func TestBlockProvider(t *testing.T) {
+	chain := testChain(5 * epochLength)
+	eth := NewWithChain(&chain)
+	if eth.Light == sharedLight {
+		t.Fatal("instance with chain shares the Light of New")
+	}
+	if err := eth.CheckBlockNumber(6 * epochLength); err != nil {
+		t.Errorf("block one epoch past the chain head rejected: %v", err)
+	}
+	if err := eth.CheckBlockNumber(7 * epochLength); err != ErrTooFarInFuture {
+		t.Errorf("block two epochs past the chain head: got error %v, want ErrTooFarInFuture", err)
+	}
+	chain = testChain(6 * epochLength)
+	if err := eth.CheckBlockNumber(7 * epochLength); err != nil {
+		t.Errorf("block one epoch past the new chain head rejected: %v", err)
+	}
+	eth.SetBlockProvider(nil)
+	if err := eth.CheckBlockNumber(epochLength); err != nil {
+		t.Errorf("block of epoch 1 rejected without chain and verified blocks: %v", err)
+	}
+	if err := eth.CheckBlockNumber(100 * epochLength); err != ErrTooFarInFuture {
+		t.Errorf("block of epoch 100 without chain and verified blocks: got error %v, want ErrTooFarInFuture", err)
+	}
+}
+
 func TestGetSeedHash(t *testing.T) {
 	seed0, err := GetSeedHash(0)
 	if err != nil {
@@ -174,3 +783,83 @@ func TestGetSeedHash(t *testing.T) {
 	}
 
 }
+
+func TestGetEpoch(t *testing.T) {
+	for _, epoch := range []uint64{0, 1, 2047} {
+		seed, err := GetSeedHash(epoch * epochLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := GetEpoch(seed)
+		if err != nil {
+			t.Errorf("epoch %d: %v", epoch, err)
+		} else if got != epoch {
+			t.Errorf("seed hash of epoch %d resolved to epoch %d", epoch, got)
+		}
+	}
+	if _, err := GetEpoch(make([]byte, 31)); err == nil {
+		t.Error("no error for short seed hash")
+	}
+	if _, err := GetEpoch(bytes.Repeat([]byte{1}, 32)); err == nil {
+		t.Error("no error for unknown seed hash")
+	}
+}
+
+func TestEthashAutoDAG(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	eth.DisableAutoDAG()
+	block := &testBlock{difficulty: big.NewInt(10)}
+	if _, mix := eth.Search(block, nil); mix != nil {
+		t.Fatal("search succeeded without a DAG while auto DAG is disabled")
+	}
+	if dagFileComplete(eth.Full.Dir, 0, true) {
+		t.Fatal("DAG file generated while auto DAG is disabled")
+	}
+
+	eth.EnableAutoDAG(2)
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed with auto DAG enabled")
+	}
+	for epoch := uint64(1); epoch <= 2; epoch++ {
+		deadline := time.Now().Add(5 * time.Second)
+		for !dagFileComplete(eth.Full.Dir, epoch, true) {
+			if time.Now().After(deadline) {
+				t.Fatalf("DAG for epoch %d was not pre-generated", epoch)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// existing DAG files are used even if generation is disabled.
+	eth.DisableAutoDAG()
+	block = &testBlock{number: 2 * epochLength, difficulty: big.NewInt(10)}
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Error("search failed with pre-generated DAG file")
+	}
+}
+
+func TestMemoryNeeded(t *testing.T) {
+	c0, d0 := cacheSize(0, false), datasetSize(0, false)
+	c1, d1 := cacheSize(1, false), datasetSize(1, false)
+	tests := []struct {
+		epoch            uint64
+		caches, datasets int
+		want             uint64
+	}{
+		{0, 1, 1, c0 + d0},
+		{0, 0, 0, c0 + d0},
+		{0, 3, 2, c0 + d0}, // no epochs before 0
+		{1, 2, 1, c1 + c0 + d1},
+		{1, 1, 2, c1 + d1 + d0},
+	}
+	for _, test := range tests {
+		if got := MemoryNeeded(test.epoch, test.caches, test.datasets); got != test.want {
+			t.Errorf("MemoryNeeded(%d, %d, %d) = %d, want %d", test.epoch, test.caches, test.datasets, got, test.want)
+		}
+	}
+}
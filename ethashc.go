@@ -12,12 +12,14 @@ package ethash
 */
 
 /*
-#cgo CFLAGS: -std=gnu99 -Wall
+#cgo CFLAGS: -std=gnu99 -Wall -D_FILE_OFFSET_BITS=64
 #cgo windows CFLAGS: -mno-stack-arg-probe
+#cgo windows LDFLAGS: -lshell32
 #cgo LDFLAGS: -lm
 
 #include "src/libethash/internal.c"
 #include "src/libethash/sha3.c"
+#include "src/libethash/blake2b.c"
 #include "src/libethash/io.c"
 
 #ifdef _WIN32
@@ -28,8 +30,78 @@ package ethash
 #endif
 
 // 'gateway function' for calling back into go.
-extern int ethashGoCallback(unsigned);
-int ethashGoCallback_cgo(unsigned percent) { return ethashGoCallback(percent); }
+extern int ethashGoCallback(uintptr_t, unsigned);
+
+// the callback of ethash_full_new_internal has no user data argument, so
+// the handle of the DAG being generated is kept in a thread local variable.
+static __thread uintptr_t ethashGoHandle;
+static int ethashGoCallback_cgo(unsigned percent) { return ethashGoCallback(ethashGoHandle, percent); }
+
+ethash_full_t ethashGoFullNew(
+	uintptr_t handle,
+	char const* dirname,
+	ethash_h256_t const seed_hash,
+	uint64_t full_size,
+	ethash_light_t const light
+)
+{
+	ethashGoHandle = handle;
+	return ethash_full_new_internal(dirname, seed_hash, full_size, light, ethashGoCallback_cgo);
+}
+
+// computes the seals of count nonces for one header hash with the DAG,
+// or with the cache if full is NULL, in a single call. The mix digests
+// and results are stored in mix and result.
+void ethashGoHashBatch(
+	ethash_full_t full,
+	ethash_light_t light,
+	uint64_t full_size,
+	ethash_h256_t const header_hash,
+	uint64_t const* nonces,
+	size_t count,
+	ethash_h256_t* mix,
+	ethash_h256_t* result
+)
+{
+	for (size_t i = 0; i < count; i++) {
+		ethash_return_value_t ret;
+		if (full) {
+			ret = ethash_full_compute(full, header_hash, nonces[i]);
+		} else {
+			ret = ethash_light_compute_internal(light, full_size, header_hash, nonces[i]);
+		}
+		mix[i] = ret.mix_hash;
+		result[i] = ret.result;
+	}
+}
+
+#ifndef _WIN32
+#include <unistd.h>
+
+// maps the complete DAG file open as fd. fd is always taken over: it
+// is closed with the returned handle, or right away on failure.
+ethash_full_t ethashGoFullFromFd(int fd, uint64_t full_size)
+{
+	struct ethash_full* ret = calloc(sizeof(*ret), 1);
+	if (!ret) {
+		close(fd);
+		return NULL;
+	}
+	FILE* f = fdopen(fd, "rb");
+	if (!f) {
+		close(fd);
+		free(ret);
+		return NULL;
+	}
+	ret->file_size = full_size;
+	if (!ethash_mmap(ret, f, false)) {
+		fclose(f);
+		free(ret);
+		return NULL;
+	}
+	return ret;
+}
+#endif
 
 */
 import "C"
@@ -0,0 +1,121 @@
+package ethash
+
+import (
+	"encoding/binary"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+// FakeBlock is a pow.Block for tests. Its fields are set by
+// NewFakeBlock or FakeChain.Block and its seal by SetSeal or Mine.
+type FakeBlock struct {
+	number     uint64
+	headerHash common.Hash
+	difficulty *big.Int
+	nonce      uint64
+	mixDigest  common.Hash
+	uncles     []pow.Block
+}
+
+// NewFakeBlock returns an unsealed block.
+func NewFakeBlock(number uint64, headerHash common.Hash, difficulty *big.Int) *FakeBlock {
+	return &FakeBlock{number: number, headerHash: headerHash, difficulty: difficulty}
+}
+
+func (b *FakeBlock) Difficulty() *big.Int     { return b.difficulty }
+func (b *FakeBlock) HashNoNonce() common.Hash { return b.headerHash }
+func (b *FakeBlock) Nonce() uint64            { return b.nonce }
+func (b *FakeBlock) MixDigest() common.Hash   { return b.mixDigest }
+func (b *FakeBlock) NumberU64() uint64        { return b.number }
+
+// Uncles returns the uncles added with AddUncle, see VerifyUncles.
+func (b *FakeBlock) Uncles() []pow.Block { return b.uncles }
+
+// AddUncle includes an uncle in the block.
+func (b *FakeBlock) AddUncle(uncle pow.Block) { b.uncles = append(b.uncles, uncle) }
+
+// SetSeal sets the nonce and mix digest.
+func (b *FakeBlock) SetSeal(nonce uint64, mixDigest common.Hash) {
+	b.nonce, b.mixDigest = nonce, mixDigest
+}
+
+// Mine seals the block with a nonce found by engine. It returns false if
+// the search was stopped.
+func (b *FakeBlock) Mine(engine pow.PoW, stop <-chan struct{}) bool {
+	nonce, mixDigest := engine.Search(b, stop)
+	if mixDigest == nil {
+		return false
+	}
+	b.SetSeal(nonce, common.BytesToHash(mixDigest))
+	return true
+}
+
+// FakeChain is a BlockProvider and HeadSubscriber for tests with a
+// settable head. Its blocks have deterministic header hashes, the same
+// for every chain, so they can be sealed once with Mine and verified by
+// other instances.
+type FakeChain struct {
+	mu         sync.Mutex
+	head       uint64
+	difficulty *big.Int
+	subs       map[chan pow.Block]struct{}
+}
+
+// NewFakeChain returns a chain whose head is at the given block number
+// and whose blocks have the given difficulty.
+func NewFakeChain(head uint64, difficulty *big.Int) *FakeChain {
+	return &FakeChain{head: head, difficulty: new(big.Int).Set(difficulty)}
+}
+
+// CurrentBlockNumber returns the number of the head block.
+func (c *FakeChain) CurrentBlockNumber() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.head
+}
+
+// SetHead moves the head to the given block number and announces it
+// to the subscribers.
+func (c *FakeChain) SetHead(number uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head = number
+	for ch := range c.subs {
+		// drop the previous block if the subscriber hasn't taken it.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- c.Block(number + 1)
+	}
+}
+
+// SubscribeHeads implements HeadSubscriber. The channel receives the
+// block after the head, starting with the current one.
+func (c *FakeChain) SubscribeHeads() (<-chan pow.Block, func()) {
+	ch := make(chan pow.Block, 1)
+	c.mu.Lock()
+	if c.subs == nil {
+		c.subs = make(map[chan pow.Block]struct{})
+	}
+	c.subs[ch] = struct{}{}
+	ch <- c.Block(c.head + 1)
+	c.mu.Unlock()
+	return ch, func() {
+		c.mu.Lock()
+		delete(c.subs, ch)
+		c.mu.Unlock()
+	}
+}
+
+// Block returns the unsealed block with the given number. Its header
+// hash is the Keccak-256 hash of the big-endian number.
+func (c *FakeChain) Block(number uint64) *FakeBlock {
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], number)
+	return NewFakeBlock(number, crypto.Sha3Hash(enc[:]), new(big.Int).Set(c.difficulty))
+}
@@ -0,0 +1,46 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"testing"
+)
+
+func TestFakeChain(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	chain := NewFakeChain(0, big.NewInt(10))
+	if b1, b2 := chain.Block(1), NewFakeChain(5, big.NewInt(1)).Block(1); b1.HashNoNonce() != b2.HashNoNonce() {
+		t.Error("header hashes differ between chains")
+	}
+	if chain.Block(1).HashNoNonce() == chain.Block(2).HashNoNonce() {
+		t.Error("blocks share a header hash")
+	}
+
+	block := chain.Block(2)
+	if !block.Mine(eth, nil) {
+		t.Fatal("mining stopped without stop channel")
+	}
+	uncle := chain.Block(1)
+	uncle.Mine(eth, nil)
+	block.AddUncle(uncle)
+	if !eth.Verify(block) {
+		t.Error("mined block did not verify")
+	}
+	if err := eth.VerifyUncles(block); err != nil {
+		t.Errorf("mined uncle did not verify: %v", err)
+	}
+
+	eth.SetBlockProvider(chain)
+	if err := eth.CheckBlockNumber(2 * epochLength); err != ErrTooFarInFuture {
+		t.Errorf("block two epochs past the head: got error %v, want ErrTooFarInFuture", err)
+	}
+	chain.SetHead(epochLength)
+	if err := eth.CheckBlockNumber(2 * epochLength); err != nil {
+		t.Errorf("block one epoch past the moved head rejected: %v", err)
+	}
+}
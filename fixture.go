@@ -0,0 +1,93 @@
+package ethash
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Fixture is a miniature cache and dataset of epoch 0, whose seed hash
+// is all zeros, with the sizes of NewForTesting. It is meant for fast
+// regression tests of code built on this package: Seals lists what
+// hashing FixtureHeaderHash with the fixture must produce, byte for
+// byte.
+type Fixture struct {
+	Cache   *Cache
+	Dataset *Dataset
+	Seals   []FixtureSeal
+
+	dir string
+}
+
+// FixtureSeal is the expected outcome of hashing FixtureHeaderHash
+// with a nonce.
+type FixtureSeal struct {
+	Nonce     uint64
+	MixDigest common.Hash
+	Result    common.Hash
+}
+
+// FixtureHeaderHash is the header hash the seals of a Fixture are
+// computed for.
+var FixtureHeaderHash = common.HexToHash("0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d")
+
+// fixtureSeals are the seals of every Fixture. They are fixed, so a
+// change in the sizes, seed or algorithm of the test mode shows up as
+// a failing test rather than changing the fixture along with it.
+var fixtureSeals = []FixtureSeal{
+	{
+		Nonce:     0,
+		MixDigest: common.HexToHash("0x736fbf69ca3d1bc5ad1aa122b59df54ad0177d294d0cb5f0c3cbca03d606095d"),
+		Result:    common.HexToHash("0xf91e94c51bda642ccd52f094c114f1865f21f92aa538fc22892da774d327d21e"),
+	},
+	{
+		Nonce:     1,
+		MixDigest: common.HexToHash("0x81bb08d16d423a8c3f68b4a38e7b2a6dac0cc1156469cb7b852214fb1b04dd5b"),
+		Result:    common.HexToHash("0xe10dcd5426a8e6eec176e58e2a5a75c75881d16c3f9c0820b8c38d9a5ce2dde0"),
+	},
+	{
+		Nonce:     0x495732e0ed7a801c,
+		MixDigest: common.HexToHash("0xb878484ba65d0882abe8f551808cf6f2c2f5f91dc1378fc055f2dd4ec6fafbc6"),
+		Result:    common.HexToHash("0xa60aefab70470b322cceb0164caf41365e5a3b66d4d8c7f30616da3e715c8e2f"),
+	},
+	{
+		Nonce:     0xffffffffffffffff,
+		MixDigest: common.HexToHash("0x71246153df454fe2220e453b1f1a26dd6eeb4085113fe8433f2fe1a2a21e9b04"),
+		Result:    common.HexToHash("0xbd8bda8777e2b76a149f97ec61befe874fed0fd58905e4e78cf644dd9b54b20b"),
+	},
+}
+
+// NewFixture generates the fixture. The dataset is written to a
+// temporary directory, Close removes it.
+func NewFixture() (*Fixture, error) {
+	dir, err := ioutil.TempDir("", "ethash-fixture")
+	if err != nil {
+		return nil, err
+	}
+	full := &Full{Dir: dir, test: true}
+	ds, err := full.Dataset(0)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	c := newCache(0, true, cacheConfig{})
+	if c.generate(); c.err != nil {
+		c.release()
+		ds.Release()
+		os.RemoveAll(dir)
+		return nil, c.err
+	}
+	seals := make([]FixtureSeal, len(fixtureSeals))
+	copy(seals, fixtureSeals)
+	return &Fixture{Cache: wrapCache(c), Dataset: ds, Seals: seals, dir: dir}, nil
+}
+
+// Close releases the cache and dataset and removes the dataset's file.
+func (f *Fixture) Close() error {
+	f.Cache.cache.release()
+	runtime.SetFinalizer(f.Cache, nil)
+	f.Dataset.Release()
+	return os.RemoveAll(f.dir)
+}
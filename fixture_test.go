@@ -0,0 +1,46 @@
+package ethash
+
+import "testing"
+
+func TestFixture(t *testing.T) {
+	f, err := NewFixture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if len(f.Seals) == 0 {
+		t.Fatal("fixture has no seals")
+	}
+	for _, seal := range f.Seals {
+		if mix, result := f.Dataset.Hash(FixtureHeaderHash, seal.Nonce); mix != seal.MixDigest || result != seal.Result {
+			t.Errorf("nonce %x: dataset computed %x, %x, want %x, %x", seal.Nonce, mix, result, seal.MixDigest, seal.Result)
+		}
+		if mix, result := f.Cache.Hash(FixtureHeaderHash, seal.Nonce); mix != seal.MixDigest || result != seal.Result {
+			t.Errorf("nonce %x: cache computed %x, %x, want %x, %x", seal.Nonce, mix, result, seal.MixDigest, seal.Result)
+		}
+	}
+	f.Seals[0].Nonce++
+	if fixtureSeals[0].Nonce == f.Seals[0].Nonce {
+		t.Error("changing the seals of a fixture changed the expected seals")
+	}
+}
+
+func TestFixtureClose(t *testing.T) {
+	f, err := NewFixture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := f.Cache.cache
+	c.refs.mu.Lock()
+	refs := c.refs.n
+	c.refs.mu.Unlock()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	c.refs.mu.Lock()
+	defer c.refs.mu.Unlock()
+	if c.refs.n != refs-1 {
+		t.Errorf("cache has %d references after Close, want %d", c.refs.n, refs-1)
+	}
+}
@@ -0,0 +1,216 @@
+package ethash
+
+/*
+#include "src/libethash/internal.h"
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// Format versions of the DAG and cache files. The revision in the file
+// names only changes with the algorithm, the versions change whenever
+// the way the files are stored does.
+const (
+	dagFormat   = 1 // magic number and dataset, optionally compressed, checksum file
+	cacheFormat = 2 // magic number and cache, checksum file
+)
+
+// formatName is the name of the file recording the revision and format
+// versions of the files in a directory.
+const formatName = "ethash-format.json"
+
+type dirFormat struct {
+	Revision int `json:"revision"`
+	DAG      int `json:"dag"`
+	Cache    int `json:"cache"`
+}
+
+// legacyFormat describes directories written before format versions
+// were recorded.
+var legacyFormat = dirFormat{Revision: C.ETHASH_REVISION, DAG: 1, Cache: 1}
+
+// migration converts the files of one kind in dir from one format
+// version to the next.
+type migration func(dir string) error
+
+// Migrations by the version they convert from. A version without
+// migration is handled by removing the files, so they are regenerated.
+var (
+	dagMigrations   = map[int]migration{}
+	cacheMigrations = map[int]migration{
+		1: addCacheChecksums,
+	}
+)
+
+// migratedDirs holds the result of checkDir by directory.
+var migratedDirs = struct {
+	sync.Mutex
+	errs map[string]error
+}{errs: make(map[string]error)}
+
+// newerFormatError is returned by checkDir for directories written by
+// a newer version of the package.
+type newerFormatError struct {
+	dir    string
+	format dirFormat
+}
+
+func (err *newerFormatError) Error() string {
+	return fmt.Sprintf("%s holds DAG format %d and cache format %d, this version supports up to %d and %d",
+		err.dir, err.format.DAG, err.format.Cache, dagFormat, cacheFormat)
+}
+
+// usableDir runs checkDir and reports whether the DAG and cache files
+// in dir may be used. Other errors are only logged, the files are
+// still in the current format.
+func usableDir(dir string) bool {
+	err := checkDir(dir)
+	if _, ok := err.(*newerFormatError); ok {
+		glog.V(logger.Error).Infof("Can't use %v", err)
+		return false
+	}
+	if err != nil {
+		glog.V(logger.Warn).Infof("Can't check the file format in %s: %v", dir, err)
+	}
+	return true
+}
+
+// checkDir brings the files in dir to the current format versions,
+// once per process. It returns an error if dir was written by a newer
+// version of the package, whose files must not be used.
+func checkDir(dir string) error {
+	migratedDirs.Lock()
+	defer migratedDirs.Unlock()
+	err, done := migratedDirs.errs[dir]
+	if !done {
+		err = migrateDir(dir)
+		migratedDirs.errs[dir] = err
+	}
+	return err
+}
+
+func migrateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// Other processes may use the same directory.
+	if unlock, err := lockFile(filepath.Join(dir, formatName+lockSuffix)); err == nil {
+		defer unlock()
+	}
+
+	have := legacyFormat
+	if buf, err := ioutil.ReadFile(filepath.Join(dir, formatName)); err == nil {
+		if err := json.Unmarshal(buf, &have); err != nil {
+			return fmt.Errorf("%s: %v", formatName, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	want := dirFormat{Revision: C.ETHASH_REVISION, DAG: dagFormat, Cache: cacheFormat}
+	if have == want {
+		return nil
+	}
+	if have.DAG > want.DAG || have.Cache > want.Cache {
+		return &newerFormatError{dir, have}
+	}
+
+	if have.Revision != want.Revision {
+		// Files of the old revision can't be used any more.
+		glog.V(logger.Info).Infof("Removing revision %d DAG and cache files from %s", have.Revision, dir)
+		removeFiles(dir, fmt.Sprintf("full-R%d-", have.Revision))
+		removeFiles(dir, fmt.Sprintf("cache-R%d-", have.Revision))
+	} else {
+		migrate(dir, "DAG", "full-R", have.DAG, want.DAG, dagMigrations)
+		migrate(dir, "cache", "cache-R", have.Cache, want.Cache, cacheMigrations)
+	}
+
+	buf, _ := json.Marshal(want)
+	tmp := filepath.Join(dir, formatName+".tmp")
+	if err := ioutil.WriteFile(tmp, buf, 0644); err != nil {
+		if os.IsPermission(err) {
+			// a read-only directory can't hold outdated files written
+			// by this process.
+			return nil
+		}
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, formatName))
+}
+
+// migrate converts the files with the given name prefix from format
+// version from to version to.
+func migrate(dir, kind, prefix string, from, to int, migrations map[int]migration) {
+	for v := from; v < to; v++ {
+		m := migrations[v]
+		if m == nil {
+			glog.V(logger.Info).Infof("Removing %s files of format %d from %s, they are regenerated when needed", kind, v, dir)
+			removeFiles(dir, prefix)
+			return
+		}
+		if err := m(dir); err != nil {
+			glog.V(logger.Warn).Infof("Can't convert %s files of format %d in %s, removing them: %v", kind, v, dir, err)
+			removeFiles(dir, prefix)
+			return
+		}
+	}
+}
+
+// removeFiles removes the files in dir whose name starts with prefix.
+func removeFiles(dir, prefix string) {
+	files, _ := ioutil.ReadDir(dir)
+	for _, fi := range files {
+		if strings.HasPrefix(fi.Name(), prefix) {
+			os.Remove(filepath.Join(dir, fi.Name()))
+		}
+	}
+}
+
+// addCacheChecksums converts cache files of format 1 by writing the
+// missing checksum files. Files without magic number are incomplete
+// and removed.
+func addCacheChecksums(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("cache-R%d-*", C.ETHASH_REVISION)))
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if strings.Contains(filepath.Base(path), ".") {
+			continue // checksum or temporary file
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err == nil && fi.Size() <= dagMagicSize {
+			err = errors.New("file too short")
+		}
+		if err == nil {
+			size := uint64(fi.Size()) - dagMagicSize
+			if err = checkDAGHeader(f, size); err == nil {
+				var sums *dagChecksums
+				if sums, err = computeDAGChecksums(f, size, dagChunkSize); err == nil {
+					err = writeChecksumFile(path, sums)
+				}
+			}
+		}
+		f.Close()
+		if err != nil {
+			glog.V(logger.Debug).Infof("Removing cache file %s: %v", path, err)
+			os.Remove(path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package ethash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a legacy directory with a cache file without checksums.
+	c := &cache{epoch: 1, test: true}
+	c.generate()
+	defer freeCache(c)
+	path := filepath.Join(dir, cacheName(makeSeedHash(1)))
+	if err := writeCacheFile(path, c); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path + checksumSuffix)
+	if err := checkDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	light, err := loadCacheFile(dir, 1, c.size)
+	if err != nil {
+		t.Fatalf("cache not converted: %v", err)
+	}
+	loaded := &cache{epoch: 1, test: true}
+	loaded.setPtr(light, c.size)
+	freeCache(loaded)
+
+	var format dirFormat
+	buf, err := ioutil.ReadFile(filepath.Join(dir, formatName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf, &format); err != nil {
+		t.Fatal(err)
+	}
+	if want := (dirFormat{Revision: legacyFormat.Revision, DAG: dagFormat, Cache: cacheFormat}); format != want {
+		t.Errorf("format file records %+v, want %+v", format, want)
+	}
+}
+
+func TestMigrateDirRevision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	old := legacyFormat.Revision - 1
+	files := []string{
+		fmt.Sprintf("full-R%d-0123456789abcdef", old),
+		fmt.Sprintf("cache-R%d-0123456789abcdef", old),
+		fmt.Sprintf("full-R%d-0123456789abcdef", legacyFormat.Revision),
+		"other",
+	}
+	for _, name := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	buf, _ := json.Marshal(dirFormat{Revision: old, DAG: dagFormat, Cache: cacheFormat})
+	ioutil.WriteFile(filepath.Join(dir, formatName), buf, 0644)
+
+	if err := checkDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != (i >= 2) {
+			t.Errorf("%s exists: %v", name, exists)
+		}
+	}
+}
+
+func TestMigrateDirNewer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	buf, _ := json.Marshal(dirFormat{Revision: legacyFormat.Revision, DAG: dagFormat + 1, Cache: cacheFormat})
+	ioutil.WriteFile(filepath.Join(dir, formatName), buf, 0644)
+
+	if usableDir(dir) {
+		t.Error("directory of a newer format is usable")
+	}
+	if err := MakeCache(0, dir); err == nil {
+		t.Error("MakeCache wrote to a directory of a newer format")
+	}
+
+	// mining reports the directory instead of crashing the process.
+	pow := &Full{Dir: dir, test: true}
+	if _, mixDigest := pow.Search(&testBlock{difficulty: big.NewInt(10)}, nil); mixDigest != nil {
+		t.Error("mined with a DAG in a directory of a newer format")
+	}
+	if err := <-pow.WarmDAG(context.Background(), 0); err == nil {
+		t.Error("WarmDAG succeeded in a directory of a newer format")
+	}
+	if pow.Ready(0) {
+		t.Error("DAG ready in a directory of a newer format")
+	}
+	if _, err := pow.Dataset(0); err == nil {
+		t.Error("got a dataset in a directory of a newer format")
+	}
+	if n := len(pow.dags.items); n != 0 {
+		t.Errorf("%d failed DAGs held in memory", n)
+	}
+}
@@ -0,0 +1,109 @@
+package ethash
+
+/*
+#include "src/libethash/internal.h"
+*/
+import "C"
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+const (
+	// gpuChunkItems is the number of dataset items computed per GPU
+	// call and written to the file at once, 64 MiB.
+	gpuChunkItems = 1 << 20
+	// gpuDAGSamples is the number of items of a DAG generated on the GPU
+	// that are compared with the CPU before the file is used.
+	gpuDAGSamples = 1024
+)
+
+// gpuGenerator computes dataset items on a GPU, see gpudag_opencl.go.
+type gpuGenerator interface {
+	// chunkItems returns the number of items compute handles at once.
+	chunkItems() int
+	// compute fills out with the items starting at index start. out
+	// holds at most chunkItems items.
+	compute(start uint32, out []byte) error
+	close()
+}
+
+// newGPUGenerator sets up a GPU to compute the items of cache's
+// dataset. It is replaced in tests.
+var newGPUGenerator = openGPU
+
+// GPUDAGSupported reports whether the package was built with OpenCL
+// support, i.e. with the opencl build tag, which SetGPUDAG needs.
+func GPUDAGSupported() bool {
+	return gpuDAGSupported
+}
+
+// SetGPUDAG makes pow generate the DAG files it computes on the first
+// OpenCL GPU, which takes seconds instead of minutes. The items are
+// streamed back and written to the file in chunks, the GPU needs no
+// memory for the whole DAG. A sample of them is compared with items
+// computed on the CPU before the file is used. If the package was
+// built without OpenCL, no GPU is found or anything fails, the DAG is
+// generated on the CPU as usual. It is off by default and applies to
+// DAGs created after the call.
+func (pow *Full) SetGPUDAG(on bool) {
+	pow.mu.Lock()
+	pow.gpuDAG = on
+	pow.mu.Unlock()
+}
+
+// writeFileGPU generates the DAG file of d on the GPU. The caller holds
+// the lock of the file.
+func (d *dag) writeFileGPU(cache *cache) error {
+	gen, err := newGPUGenerator(cache)
+	if err != nil {
+		return err
+	}
+	defer gen.close()
+
+	size := datasetSize(d.epoch, d.test)
+	path := filepath.Join(d.dir, dagName(makeSeedHash(d.epoch)))
+	f, err := ioutil.TempFile(d.dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	// The magic number is written last, like the C code does, so an
+	// interrupted file is never taken for complete.
+	if _, err := f.Write(make([]byte, dagMagicSize)); err != nil {
+		return err
+	}
+	buf := make([]byte, gen.chunkItems()*dagItemSize)
+	for start := uint64(0); start < size; {
+		n := min64(size-start, uint64(len(buf)))
+		if err := gen.compute(uint32(start/dagItemSize), buf[:n]); err != nil {
+			return err
+		}
+		if _, err := f.Write(buf[:n]); err != nil {
+			return err
+		}
+		start += n
+		glog.V(logger.Debug).Infof("Still generating DAG on the GPU: %d%%", start*100/size)
+	}
+	magic := uint64(C.ETHASH_DAG_MAGIC_NUM)
+	if _, err := f.WriteAt((*[dagMagicSize]byte)(unsafe.Pointer(&magic))[:], 0); err != nil {
+		return err
+	}
+	if err := checkDAGItems(f, cache, size, gpuDAGSamples); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
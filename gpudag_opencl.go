@@ -0,0 +1,248 @@
+//go:build opencl && !ethash_blake2b
+// +build opencl,!ethash_blake2b
+
+package ethash
+
+/*
+#cgo LDFLAGS: -lOpenCL
+#cgo darwin LDFLAGS: -framework OpenCL
+
+#define CL_TARGET_OPENCL_VERSION 120
+#define CL_USE_DEPRECATED_OPENCL_1_2_APIS
+#ifdef __APPLE__
+#	include <OpenCL/opencl.h>
+#else
+#	include <CL/cl.h>
+#endif
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+#include "src/libethash/internal.h"
+
+typedef struct {
+	cl_context context;
+	cl_command_queue queue;
+	cl_program program;
+	cl_kernel kernel;
+	cl_mem cache;
+	cl_mem out;
+} ethashGoGPU;
+
+static void ethashGoGPUFree(ethashGoGPU* g)
+{
+	if (g->out) clReleaseMemObject(g->out);
+	if (g->cache) clReleaseMemObject(g->cache);
+	if (g->kernel) clReleaseKernel(g->kernel);
+	if (g->program) clReleaseProgram(g->program);
+	if (g->queue) clReleaseCommandQueue(g->queue);
+	if (g->context) clReleaseContext(g->context);
+	free(g);
+}
+
+// sets up the first little-endian GPU of any platform to compute dataset items from
+// the cache, count items per call. On failure, NULL is returned and err
+// describes the problem.
+static ethashGoGPU* ethashGoGPUNew(
+	char const* source,
+	void const* cache,
+	uint64_t cache_size,
+	size_t count,
+	char* err,
+	size_t err_size
+)
+{
+	cl_platform_id platforms[8];
+	cl_uint num_platforms = 0;
+	cl_device_id device = NULL;
+	cl_int rc = clGetPlatformIDs(8, platforms, &num_platforms);
+	for (cl_uint i = 0; rc == CL_SUCCESS && i < num_platforms && device == NULL; i++) {
+		cl_bool little = CL_FALSE;
+		if (clGetDeviceIDs(platforms[i], CL_DEVICE_TYPE_GPU, 1, &device, NULL) != CL_SUCCESS ||
+			clGetDeviceInfo(device, CL_DEVICE_ENDIAN_LITTLE, sizeof(little), &little, NULL) != CL_SUCCESS ||
+			!little) {
+			device = NULL;
+		}
+	}
+	if (device == NULL) {
+		snprintf(err, err_size, "no OpenCL GPU found");
+		return NULL;
+	}
+	ethashGoGPU* g = calloc(1, sizeof(ethashGoGPU));
+	if (g == NULL) {
+		snprintf(err, err_size, "out of memory");
+		return NULL;
+	}
+	char const* what = "clCreateContext";
+	g->context = clCreateContext(NULL, 1, &device, NULL, NULL, &rc);
+	if (rc != CL_SUCCESS) goto fail;
+	what = "clCreateCommandQueue";
+	g->queue = clCreateCommandQueue(g->context, device, 0, &rc);
+	if (rc != CL_SUCCESS) goto fail;
+	what = "clCreateProgramWithSource";
+	g->program = clCreateProgramWithSource(g->context, 1, &source, NULL, &rc);
+	if (rc != CL_SUCCESS) goto fail;
+	if ((rc = clBuildProgram(g->program, 1, &device, "", NULL, NULL)) != CL_SUCCESS) {
+		size_t n = snprintf(err, err_size, "building the kernel failed: ");
+		if (n < err_size) {
+			clGetProgramBuildInfo(g->program, device, CL_PROGRAM_BUILD_LOG, err_size - n, err + n, NULL);
+			err[err_size - 1] = 0;
+		}
+		ethashGoGPUFree(g);
+		return NULL;
+	}
+	what = "clCreateKernel";
+	g->kernel = clCreateKernel(g->program, "ethash_calculate_dag_item", &rc);
+	if (rc != CL_SUCCESS) goto fail;
+	what = "clCreateBuffer";
+	g->cache = clCreateBuffer(g->context, CL_MEM_READ_ONLY | CL_MEM_COPY_HOST_PTR, cache_size, (void*)cache, &rc);
+	if (rc != CL_SUCCESS) goto fail;
+	g->out = clCreateBuffer(g->context, CL_MEM_WRITE_ONLY, count * 64, NULL, &rc);
+	if (rc != CL_SUCCESS) goto fail;
+	return g;
+
+fail:
+	snprintf(err, err_size, "%s failed with OpenCL error %d", what, (int)rc);
+	ethashGoGPUFree(g);
+	return NULL;
+}
+
+// computes count dataset items from index start into out. It returns
+// the OpenCL error code, which is CL_SUCCESS (0) on success.
+static int ethashGoGPUCompute(ethashGoGPU* g, uint32_t start, uint32_t cache_nodes, size_t count, void* out)
+{
+	cl_int rc = clSetKernelArg(g->kernel, 0, sizeof(cl_uint), &start);
+	if (rc == CL_SUCCESS) rc = clSetKernelArg(g->kernel, 1, sizeof(cl_mem), &g->cache);
+	if (rc == CL_SUCCESS) rc = clSetKernelArg(g->kernel, 2, sizeof(cl_mem), &g->out);
+	if (rc == CL_SUCCESS) rc = clSetKernelArg(g->kernel, 3, sizeof(cl_uint), &cache_nodes);
+	if (rc == CL_SUCCESS) rc = clEnqueueNDRangeKernel(g->queue, g->kernel, 1, NULL, &count, NULL, 0, NULL, NULL);
+	if (rc == CL_SUCCESS) rc = clEnqueueReadBuffer(g->queue, g->out, CL_TRUE, 0, count * 64, out, 0, NULL, NULL);
+	return rc;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+const gpuDAGSupported = true
+
+// openCLGenerator computes dataset items with the OpenCL kernel
+// gpuDAGKernel.
+type openCLGenerator struct {
+	gpu   *C.ethashGoGPU
+	nodes uint32 // cache size in items
+}
+
+func openGPU(cache *cache) (gpuGenerator, error) {
+	source := C.CString(gpuDAGKernel)
+	defer C.free(unsafe.Pointer(source))
+	var errbuf [1024]C.char
+	gpu := C.ethashGoGPUNew(source, cache.ptr.cache, C.uint64_t(cache.size), gpuChunkItems, &errbuf[0], C.size_t(len(errbuf)))
+	if gpu == nil {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+	return &openCLGenerator{gpu: gpu, nodes: uint32(cache.size / dagItemSize)}, nil
+}
+
+func (g *openCLGenerator) chunkItems() int { return gpuChunkItems }
+
+func (g *openCLGenerator) compute(start uint32, out []byte) error {
+	if rc := C.ethashGoGPUCompute(g.gpu, C.uint32_t(start), C.uint32_t(g.nodes), C.size_t(len(out)/dagItemSize), unsafe.Pointer(&out[0])); rc != 0 {
+		return fmt.Errorf("computing dataset items on the GPU failed with OpenCL error %d", rc)
+	}
+	return nil
+}
+
+func (g *openCLGenerator) close() {
+	C.ethashGoGPUFree(g.gpu)
+}
+
+// gpuDAGKernel computes dataset items. It needs a little-endian device,
+// the lanes of Keccak are read from the bytes of the cache.
+const gpuDAGKernel = `#define FNV_PRIME 0x01000193
+#define DATASET_PARENTS 256
+#define NODE_WORDS 16
+
+__constant ulong const keccak_rc[24] = {
+	0x0000000000000001UL, 0x0000000000008082UL, 0x800000000000808aUL, 0x8000000080008000UL,
+	0x000000000000808bUL, 0x0000000080000001UL, 0x8000000080008081UL, 0x8000000000008009UL,
+	0x000000000000008aUL, 0x0000000000000088UL, 0x0000000080008009UL, 0x000000008000000aUL,
+	0x000000008000808bUL, 0x800000000000008bUL, 0x8000000000008089UL, 0x8000000000008003UL,
+	0x8000000000008002UL, 0x8000000000000080UL, 0x000000000000800aUL, 0x800000008000000aUL,
+	0x8000000080008081UL, 0x8000000000008080UL, 0x0000000080000001UL, 0x8000000080008008UL,
+};
+__constant uint const keccak_rotc[24] = {1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14, 27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44};
+__constant uint const keccak_piln[24] = {10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4, 15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1};
+
+// keccak_512 replaces the 64 bytes at h by their Keccak-512 hash.
+void keccak_512(ulong* h)
+{
+	ulong st[25], bc[5], t;
+	for (uint i = 0; i < 8; i++)
+		st[i] = h[i];
+	for (uint i = 8; i < 25; i++)
+		st[i] = 0;
+	// the padding starts and ends in the last lane of the 72 byte rate.
+	st[8] = 0x8000000000000001UL;
+	for (uint r = 0; r < 24; r++) {
+		for (uint i = 0; i < 5; i++)
+			bc[i] = st[i] ^ st[i + 5] ^ st[i + 10] ^ st[i + 15] ^ st[i + 20];
+		for (uint i = 0; i < 5; i++) {
+			t = bc[(i + 4) % 5] ^ rotate(bc[(i + 1) % 5], 1UL);
+			for (uint j = 0; j < 25; j += 5)
+				st[j + i] ^= t;
+		}
+		t = st[1];
+		for (uint i = 0; i < 24; i++) {
+			uint j = keccak_piln[i];
+			bc[0] = st[j];
+			st[j] = rotate(t, (ulong)keccak_rotc[i]);
+			t = bc[0];
+		}
+		for (uint j = 0; j < 25; j += 5) {
+			for (uint i = 0; i < 5; i++)
+				bc[i] = st[j + i];
+			for (uint i = 0; i < 5; i++)
+				st[j + i] ^= ~bc[(i + 1) % 5] & bc[(i + 2) % 5];
+		}
+		st[0] ^= keccak_rc[r];
+	}
+	for (uint i = 0; i < 8; i++)
+		h[i] = st[i];
+}
+
+uint fnv(uint x, uint y)
+{
+	return x * FNV_PRIME ^ y;
+}
+
+// ethash_calculate_dag_item computes the dataset item start + id into
+// dag[id], like ethash_calculate_dag_item of libethash.
+__kernel void ethash_calculate_dag_item(uint start, __global ulong const* cache, __global ulong* dag, uint cache_nodes)
+{
+	uint const id = get_global_id(0);
+	uint const index = start + id;
+	union {
+		ulong lanes[8];
+		uint words[NODE_WORDS];
+	} node;
+	__global ulong const* init = cache + (index % cache_nodes) * 8;
+	for (uint i = 0; i < 8; i++)
+		node.lanes[i] = init[i];
+	node.words[0] ^= index;
+	keccak_512(node.lanes);
+	for (uint i = 0; i < DATASET_PARENTS; i++) {
+		uint parent = fnv(index ^ i, node.words[i % NODE_WORDS]) % cache_nodes;
+		__global uint const* words = (__global uint const*)(cache + parent * 8);
+		for (uint w = 0; w < NODE_WORDS; w++)
+			node.words[w] = fnv(node.words[w], words[w]);
+	}
+	keccak_512(node.lanes);
+	for (uint i = 0; i < 8; i++)
+		dag[id * 8 + i] = node.lanes[i];
+}
+`
@@ -0,0 +1,12 @@
+//go:build !opencl || ethash_blake2b
+// +build !opencl ethash_blake2b
+
+package ethash
+
+import "errors"
+
+const gpuDAGSupported = false
+
+func openGPU(cache *cache) (gpuGenerator, error) {
+	return nil, errors.New("built without OpenCL support")
+}
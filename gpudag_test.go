@@ -0,0 +1,74 @@
+package ethash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cpuGenerator stands in for a GPU, computing a few items per call.
+type cpuGenerator struct {
+	cache   *cache
+	corrupt bool // flip a bit of every item
+}
+
+func (g *cpuGenerator) chunkItems() int { return 7 }
+
+func (g *cpuGenerator) compute(start uint32, out []byte) error {
+	for i := 0; i < len(out)/dagItemSize; i++ {
+		item := out[i*dagItemSize : (i+1)*dagItemSize]
+		calcDatasetItem(g.cache, start+uint32(i), item)
+		if g.corrupt {
+			item[0] ^= 1
+		}
+	}
+	return nil
+}
+
+func (g *cpuGenerator) close() {}
+
+func TestGPUDAG(t *testing.T) {
+	defer func(open func(*cache) (gpuGenerator, error)) { newGPUGenerator = open }(newGPUGenerator)
+	tests := []struct {
+		open   func(*cache) (gpuGenerator, error)
+		source string
+	}{
+		{func(c *cache) (gpuGenerator, error) { return &cpuGenerator{cache: c}, nil }, "gpu"},
+		{func(c *cache) (gpuGenerator, error) { return &cpuGenerator{cache: c, corrupt: true}, nil }, "computed"},
+		{func(c *cache) (gpuGenerator, error) { return nil, errors.New("no GPU") }, "computed"},
+	}
+	for i, test := range tests {
+		eth, err := NewForTesting()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(eth.Full.Dir)
+		eth.SetGPUDAG(true)
+		newGPUGenerator = test.open
+
+		n := len(EpochHistory())
+		ds, err := eth.Full.Dataset(epochLength * 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds.Release()
+		eth.Full.FreeDAG()
+		var source string
+		for _, ev := range newEpochEvents(n) {
+			if ev.Kind == "DAG" && ev.Epoch == 3 && ev.Dir == eth.Full.Dir {
+				source = ev.Source
+			}
+		}
+		if source != test.source {
+			t.Errorf("test %d: DAG source %q, want %q", i, source, test.source)
+		}
+		path := filepath.Join(eth.Full.Dir, dagName(makeSeedHash(3)))
+		if err := verifyDAGFile(path, 3, true, 0); err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if tmp, _ := filepath.Glob(path + ".tmp*"); len(tmp) != 0 {
+			t.Errorf("test %d: temporary files %v left behind", i, tmp)
+		}
+	}
+}
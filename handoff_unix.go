@@ -0,0 +1,184 @@
+//go:build !windows
+// +build !windows
+
+package ethash
+
+/*
+#include "src/libethash/internal.h"
+
+ethash_full_t ethashGoFullFromFd(int, uint64_t);
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// maxHandoffDAGs bounds the number of DAGs passed in one handoff.
+const maxHandoffDAGs = 16
+
+// handoffDAG describes a DAG file passed to another process.
+type handoffDAG struct {
+	Epoch uint64 `json:"epoch"`
+	Test  bool   `json:"test"`
+	Size  uint64 `json:"size"`
+	Dir   string `json:"dir"`
+}
+
+// HandoffDAGs passes the open files of the DAGs held in memory by pow
+// over conn to a process calling ReceiveDAGs, e.g. the replacement of
+// the current process during a restart. The files stay valid for the
+// receiver when the sending process exits. DAGs still being generated
+// are waited for.
+func (pow *Full) HandoffDAGs(conn *net.UnixConn) error {
+	pow.mu.Lock()
+	var dags []*dag
+	for _, item := range pow.dags.items {
+		if len(dags) == maxHandoffDAGs {
+			break
+		}
+		d := item.(*dag)
+		d.refs.acquire()
+		dags = append(dags, d)
+	}
+	pow.mu.Unlock()
+	defer func() {
+		for _, d := range dags {
+			d.release()
+		}
+	}()
+
+	var (
+		meta []handoffDAG
+		fds  []int
+	)
+	for _, d := range dags {
+		if d.generate(); d.ptr == nil {
+			continue // failed to generate
+		}
+		meta = append(meta, handoffDAG{Epoch: d.epoch, Test: d.test, Size: d.size, Dir: d.dir})
+		fds = append(fds, int(C.fileno(d.ptr.file)))
+	}
+	buf, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	_, _, err = conn.WriteMsgUnix(buf, syscall.UnixRights(fds...), nil)
+	runtime.KeepAlive(dags)
+	return err
+}
+
+// ReceiveDAGs takes over the DAG files passed over conn by HandoffDAGs
+// and maps them, without checking their contents again. Only DAGs of
+// pow's directory are used. It returns the epochs of the DAGs taken
+// over.
+func (pow *Full) ReceiveDAGs(conn *net.UnixConn) ([]uint64, error) {
+	buf := make([]byte, 64*1024)
+	oob := make([]byte, syscall.CmsgSpace(4*maxHandoffDAGs))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	for i := range msgs {
+		rights, err := syscall.ParseUnixRights(&msgs[i])
+		if err != nil {
+			return nil, err
+		}
+		fds = append(fds, rights...)
+	}
+	// The mapped DAGs hold their own copies of the files.
+	defer func() {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+	}()
+	var meta []handoffDAG
+	if err := json.Unmarshal(buf[:n], &meta); err != nil {
+		return nil, err
+	}
+	if len(meta) != len(fds) {
+		return nil, fmt.Errorf("received %d DAG descriptions for %d files", len(meta), len(fds))
+	}
+
+	var epochs []uint64
+	for i, m := range meta {
+		if err := pow.adoptDAG(m, fds[i]); err != nil {
+			glog.V(logger.Warn).Infof("Not taking over DAG for epoch %d: %v", m.Epoch, err)
+			continue
+		}
+		epochs = append(epochs, m.Epoch)
+	}
+	return epochs, nil
+}
+
+// adoptDAG maps the DAG file open as fd and makes it the DAG of its
+// epoch for pow. fd is not taken over, the caller closes it.
+func (pow *Full) adoptDAG(m handoffDAG, fd int) error {
+	if m.Test != pow.test || m.Dir != pow.dir() {
+		return errors.New("DAG of another instance")
+	}
+	if m.Epoch >= maxEpoch || m.Size != datasetSize(m.Epoch, m.Test) {
+		return fmt.Errorf("invalid DAG size %d", m.Size)
+	}
+	// check the header through a copy of fd, which f closes.
+	dup, err := syscall.Dup(fd)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(dup), "DAG")
+	err = checkDAGHeader(f, m.Size)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	// the C code takes over its own copy of fd, whether or not it
+	// can map the file, so fd stays the caller's.
+	mapped, err := syscall.Dup(fd)
+	if err != nil {
+		return err
+	}
+	ptr := C.ethashGoFullFromFd(C.int(mapped), C.uint64_t(m.Size))
+	if ptr == nil {
+		return errors.New("can't map the DAG file")
+	}
+
+	pow.mu.Lock()
+	defer pow.mu.Unlock()
+	d := newDAG(m.Epoch, m.Test, pow.Dir, pow.dagConfig())
+	adopted := false
+	d.gen.Do(func() {
+		d.ptr = ptr
+		d.size = m.Size
+		trackAlloc(memory.dags, d.epoch, d.size)
+		runtime.SetFinalizer(d, freeDAG)
+		atomic.StoreInt32(&d.ready, 1)
+		adopted = true
+		recordEpoch(EpochEvent{Kind: "DAG", Epoch: d.epoch, Test: d.test, Source: "handoff", Dir: d.dir, Started: time.Now()})
+	})
+	if !adopted {
+		// the process has the DAG already, the file is not needed.
+		C.ethash_full_delete(ptr)
+	}
+	if pow.dags.get(m.Epoch) == nil {
+		pow.dags.add(d)
+	} else {
+		d.release()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+//go:build !windows
+// +build !windows
+
+package ethash
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func unixPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socket")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = c.(*net.UnixConn)
+	}
+	return conns[0], conns[1]
+}
+
+func TestDAGHandoff(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	block := &testBlock{difficulty: big.NewInt(10)}
+	block.seal(eth.Search(block, nil))
+
+	a, b := unixPair(t)
+	defer a.Close()
+	defer b.Close()
+	if err := eth.Full.HandoffDAGs(a); err != nil {
+		t.Fatal(err)
+	}
+	// the sender exits.
+	eth.FreeDAG()
+
+	successor := &Full{Dir: eth.Full.Dir, test: true}
+	EnableCgoProfiling(true)
+	defer EnableCgoProfiling(false)
+	ResetCgoProfile()
+	epochs, err := successor.ReceiveDAGs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(epochs) != 1 || epochs[0] != 0 {
+		t.Fatalf("took over epochs %v, want [0]", epochs)
+	}
+	ds, err := successor.Dataset(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Release()
+	if mix, _ := ds.Hash(block.hashNoNonce, block.nonce); mix != block.mixDigest {
+		t.Errorf("received DAG computed mix digest %x, want %x", mix, block.mixDigest)
+	}
+	if calls := GetCgoProfile().FullNew.Calls; calls != 0 {
+		t.Errorf("DAG loaded %d times after the handoff", calls)
+	}
+	successor.FreeDAG()
+}
+
+func TestAdoptDAGKeepsFd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := ioutil.TempFile(dir, "full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// the file can't be mapped, fd must stay open for the caller to
+	// close, even if the C code got as far as opening it.
+	pow := &Full{Dir: dir, test: true}
+	m := handoffDAG{Epoch: 0, Test: true, Size: datasetSize(0, true), Dir: dir}
+	if err := pow.adoptDAG(m, int(f.Fd())); err == nil {
+		t.Fatal("adopted an empty DAG file")
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(f.Fd()), &st); err != nil {
+		t.Errorf("fd closed by a failed adoption: %v", err)
+	}
+
+	// an adopted DAG is ready, fd still stays open.
+	eth := &Full{Dir: dir, test: true}
+	eth.Search(&testBlock{difficulty: big.NewInt(10)}, nil)
+	eth.FreeDAG()
+	g, err := os.Open(filepath.Join(dir, dagName(makeSeedHash(0))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if err := pow.adoptDAG(m, int(g.Fd())); err != nil {
+		t.Fatal(err)
+	}
+	defer pow.FreeDAG()
+	if !pow.Ready(0) {
+		t.Error("adopted DAG not ready")
+	}
+	if err := syscall.Fstat(int(g.Fd()), &st); err != nil {
+		t.Errorf("fd closed by an adoption: %v", err)
+	}
+}
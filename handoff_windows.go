@@ -0,0 +1,18 @@
+package ethash
+
+import (
+	"errors"
+	"net"
+)
+
+var errNoHandoff = errors.New("DAG handoff is not supported on Windows")
+
+// HandoffDAGs is not supported on Windows.
+func (pow *Full) HandoffDAGs(conn *net.UnixConn) error {
+	return errNoHandoff
+}
+
+// ReceiveDAGs is not supported on Windows.
+func (pow *Full) ReceiveDAGs(conn *net.UnixConn) ([]uint64, error) {
+	return nil, errNoHandoff
+}
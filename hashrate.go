@@ -0,0 +1,198 @@
+package ethash
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of SetHashrateWindow.
+const (
+	// DefaultHashrateWindow is the period over which the hash rate is
+	// averaged. Samples older than this no longer contribute to the
+	// reported rate.
+	DefaultHashrateWindow = 10 * time.Second
+	// DefaultHashrateSampleInterval is the minimum time between two
+	// samples.
+	DefaultHashrateSampleInterval = time.Second
+)
+
+// hashrateMeter counts the hashes computed by all running search
+// loops and derives a moving hash rate from samples of the total.
+type hashrateMeter struct {
+	hashes  uint64 // hashes computed so far, accessed atomically
+	workers int32  // number of active search loops, accessed atomically
+
+	mu       sync.Mutex // protects the fields below
+	samples  []hashrateSample
+	clock    Clock
+	window   time.Duration // zero for DefaultHashrateWindow
+	interval time.Duration // zero for DefaultHashrateSampleInterval
+}
+
+type hashrateSample struct {
+	time   time.Time
+	hashes uint64
+}
+
+// setClock sets the clock the samples are taken with.
+func (m *hashrateMeter) setClock(c Clock) {
+	m.mu.Lock()
+	m.clock = c
+	m.mu.Unlock()
+}
+
+// setWindow sets the averaging window and sample interval, zero
+// selects the defaults.
+func (m *hashrateMeter) setWindow(window, interval time.Duration) {
+	m.mu.Lock()
+	m.window, m.interval = window, interval
+	m.mu.Unlock()
+}
+
+// settings returns the averaging window and sample interval in use.
+// m.mu must be held.
+func (m *hashrateMeter) settings() (window, interval time.Duration) {
+	window, interval = m.window, m.interval
+	if window <= 0 {
+		window = DefaultHashrateWindow
+	}
+	if interval <= 0 {
+		interval = DefaultHashrateSampleInterval
+	}
+	if interval > window {
+		interval = window
+	}
+	return window, interval
+}
+
+// start registers a search loop with the meter.
+func (m *hashrateMeter) start() {
+	m.mu.Lock()
+	if atomic.AddInt32(&m.workers, 1) == 1 {
+		m.samples = []hashrateSample{{orSystem(m.clock).Now(), atomic.LoadUint64(&m.hashes)}}
+	} else {
+		m.sample()
+	}
+	m.mu.Unlock()
+}
+
+// stop unregisters a search loop. When the last loop stops, all
+// samples are discarded so no stale rate can be reported.
+func (m *hashrateMeter) stop() {
+	m.mu.Lock()
+	if atomic.AddInt32(&m.workers, -1) == 0 {
+		m.samples = nil
+	}
+	m.mu.Unlock()
+}
+
+// hashrateSampleEvery is how many hashes mark counts between taking
+// samples. The search loops call mark for every hash, taking the lock
+// and reading the clock each time would slow them down.
+const hashrateSampleEvery = 1024
+
+// mark records that n hashes have been computed.
+func (m *hashrateMeter) mark(n uint64) {
+	total := atomic.AddUint64(&m.hashes, n)
+	if (total-n)/hashrateSampleEvery == total/hashrateSampleEvery {
+		return
+	}
+	m.mu.Lock()
+	m.sample()
+	m.mu.Unlock()
+}
+
+// sample takes a sample of the total if the interval has passed since
+// the last one and drops the samples that fell out of the window, but
+// keeps at least one so there is always a base to measure against. It
+// returns the current total. m.mu must be held.
+func (m *hashrateMeter) sample() hashrateSample {
+	now := hashrateSample{orSystem(m.clock).Now(), atomic.LoadUint64(&m.hashes)}
+	if len(m.samples) == 0 {
+		return now
+	}
+	window, interval := m.settings()
+	cutoff := now.time.Add(-window)
+	for len(m.samples) > 1 && m.samples[0].time.Before(cutoff) {
+		m.samples = m.samples[1:]
+	}
+	if last := m.samples[len(m.samples)-1]; now.time.Sub(last.time) >= interval {
+		m.samples = append(m.samples, now)
+	}
+	return now
+}
+
+// rate returns the number of hashes per second, averaged over the
+// samples taken within the window.
+func (m *hashrateMeter) rate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if atomic.LoadInt32(&m.workers) == 0 || len(m.samples) == 0 {
+		return 0
+	}
+	now := m.sample()
+	base := m.samples[0]
+	elapsed := now.time.Sub(base.time)
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(now.hashes-base.hashes) / elapsed.Seconds()
+}
+
+// SetHashrateWindow sets how the hash rate reported by GetHashrate and
+// SubscribeHashrate is averaged: over the hashes computed within the
+// last window, sampled at most every interval, e.g. to match the
+// accounting of a pool. Zero selects DefaultHashrateWindow and
+// DefaultHashrateSampleInterval. The interval is capped to the window.
+func (pow *Full) SetHashrateWindow(window, interval time.Duration) {
+	pow.hashrate.setWindow(window, interval)
+}
+
+// HashrateWindow returns the averaging window and sample interval of
+// the hash rate.
+func (pow *Full) HashrateWindow() (window, interval time.Duration) {
+	pow.hashrate.mu.Lock()
+	defer pow.hashrate.mu.Unlock()
+	return pow.hashrate.settings()
+}
+
+// HashrateSample is the combined hash rate of the Search calls of a
+// Full at a point in time.
+type HashrateSample struct {
+	Time time.Time
+	Rate float64 // hashes per second
+}
+
+// SubscribeHashrate returns a channel delivering a hash rate sample
+// every interval, and a function ending the subscription, which closes
+// the channel. Samples are dropped while the receiver hasn't taken the
+// previous one. Their time is taken from pow's clock, the interval is
+// always measured in real time. An interval of zero or less selects
+// DefaultHashrateSampleInterval.
+func (pow *Full) SubscribeHashrate(interval time.Duration) (<-chan HashrateSample, func()) {
+	if interval <= 0 {
+		interval = DefaultHashrateSampleInterval
+	}
+	ch := make(chan HashrateSample, 1)
+	quit := make(chan struct{})
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				select {
+				case ch <- HashrateSample{pow.getClock().Now(), pow.hashrate.rate()}:
+				default:
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return ch, func() { once.Do(func() { close(quit) }) }
+}
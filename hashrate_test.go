@@ -0,0 +1,170 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHashrateMeterIdle(t *testing.T) {
+	var m hashrateMeter
+	if r := m.rate(); r != 0 {
+		t.Errorf("rate without workers: got %v, want 0", r)
+	}
+	m.start()
+	m.mark(1000)
+	m.stop()
+	if r := m.rate(); r != 0 {
+		t.Errorf("rate after last worker stopped: got %v, want 0", r)
+	}
+}
+
+func TestHashrateMeterDecay(t *testing.T) {
+	var m hashrateMeter
+	m.start()
+	defer m.stop()
+
+	// pretend 1000 hashes were computed during the last second.
+	m.samples[0].time = time.Now().Add(-time.Second)
+	m.mark(1000)
+	if r := m.rate(); r < 500 || r > 1000 {
+		t.Errorf("rate of fresh samples: got %v, want ~1000", r)
+	}
+	// the same work seen from far in the past only counts for the window.
+	m.samples = []hashrateSample{{time.Now().Add(-3 * DefaultHashrateWindow), 0}}
+	if r := m.rate(); r > 1000/(3*DefaultHashrateWindow.Seconds())+1 {
+		t.Errorf("rate of stale samples: got %v, want decayed", r)
+	}
+}
+
+func TestHashrateWindow(t *testing.T) {
+	for _, test := range []struct {
+		window, interval time.Duration
+		rate             float64
+		samples          int
+	}{
+		{0, 0, 0, 11}, // defaults, the burst left the window
+		{30 * time.Second, 5 * time.Second, 1000.0 / 22, 5}, // still in the window
+	} {
+		clock := newFakeClock()
+		var m hashrateMeter
+		m.setClock(clock)
+		m.setWindow(test.window, test.interval)
+		m.start()
+
+		// a burst of hashes, then 20s without any.
+		clock.advance(2 * time.Second)
+		m.mark(1000)
+		m.rate()
+		for i := 0; i < 20; i++ {
+			clock.advance(time.Second)
+			m.rate()
+		}
+		if r := m.rate(); r != test.rate {
+			t.Errorf("window %v: got rate %v, want %v", test.window, r, test.rate)
+		}
+		if len(m.samples) != test.samples {
+			t.Errorf("window %v, interval %v: got %d samples, want %d", test.window, test.interval, len(m.samples), test.samples)
+		}
+		m.stop()
+	}
+
+	var pow Full
+	if w, i := pow.HashrateWindow(); w != DefaultHashrateWindow || i != DefaultHashrateSampleInterval {
+		t.Errorf("default window %v, interval %v", w, i)
+	}
+	pow.SetHashrateWindow(0, time.Minute)
+	if w, i := pow.HashrateWindow(); w != DefaultHashrateWindow || i != DefaultHashrateWindow {
+		t.Errorf("interval longer than the window: got window %v, interval %v", w, i)
+	}
+}
+
+func TestHashrateMeterSampleOnMark(t *testing.T) {
+	clock := newFakeClock()
+	var m hashrateMeter
+	m.setClock(clock)
+	m.start()
+	defer m.stop()
+
+	// a steady rate for 10s with no one asking for it.
+	const perSecond = hashrateSampleEvery
+	for i := 0; i < 10; i++ {
+		clock.advance(time.Second)
+		m.mark(perSecond)
+	}
+	if r := m.rate(); r != perSecond {
+		t.Errorf("steady rate: got %v, want %v", r, perSecond)
+	}
+	// halfway through the window after hashing stopped, only the
+	// hashes of its second half count.
+	clock.advance(5 * time.Second)
+	if r := m.rate(); r != perSecond/2 {
+		t.Errorf("rate 5s after hashing stopped: got %v, want %v", r, perSecond/2)
+	}
+	if len(m.samples) > int(DefaultHashrateWindow/DefaultHashrateSampleInterval)+1 {
+		t.Errorf("%d samples kept for a %v window", len(m.samples), DefaultHashrateWindow)
+	}
+}
+
+func TestEthashHashrateAfterSearch(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{difficulty: big.NewInt(10)}
+	eth.Search(block, nil)
+	if r := eth.GetHashrate(); r != 0 {
+		t.Errorf("hashrate after search returned: got %d, want 0", r)
+	}
+}
+
+func TestSubscribeHashrate(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	samples, unsubscribe := eth.SubscribeHashrate(10 * time.Millisecond)
+	first, second := <-samples, <-samples
+	if !second.Time.After(first.Time) {
+		t.Errorf("sample times %v, %v not increasing", first.Time, second.Time)
+	}
+	if first.Rate != 0 {
+		t.Errorf("rate without search: got %v, want 0", first.Rate)
+	}
+	unsubscribe()
+	unsubscribe()
+	for range samples {
+	}
+
+	// time.NewTicker panics for these.
+	for _, interval := range []time.Duration{0, -time.Second} {
+		_, unsubscribe := eth.SubscribeHashrate(interval)
+		unsubscribe()
+	}
+}
+
+func TestSearchHook(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	attempts := make(map[int]uint64)
+	eth.SetSearchHook(1, func(worker int, n uint64, elapsed time.Duration) {
+		if n != attempts[worker]+1 {
+			t.Errorf("worker %d: hook called after %d attempts, previously %d", worker, n, attempts[worker])
+		}
+		attempts[worker] = n
+	})
+	eth.Search(&testBlock{difficulty: big.NewInt(100)}, nil)
+	eth.Search(&testBlock{difficulty: big.NewInt(100)}, nil)
+	if len(attempts) != 2 {
+		t.Errorf("hook saw %d workers, want 2", len(attempts))
+	}
+}
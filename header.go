@@ -0,0 +1,181 @@
+package ethash
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Positions of the seal fields in an RLP encoded block header. The
+// fields before them are the same in all header versions, later
+// versions append fields after the nonce.
+const (
+	headerDifficulty = 7
+	headerNumber     = 8
+	headerMixDigest  = 13
+	headerNonce      = 14
+)
+
+var errHeaderRLP = errors.New("invalid header RLP")
+
+// Header is a block header decoded from RLP by DecodeHeader, holding
+// what the proof of work needs. It implements pow.Block.
+type Header struct {
+	hashNoNonce common.Hash
+	number      uint64
+	difficulty  *big.Int
+	nonce       uint64
+	mixDigest   common.Hash
+}
+
+func (h *Header) Difficulty() *big.Int     { return new(big.Int).Set(h.difficulty) }
+func (h *Header) HashNoNonce() common.Hash { return h.hashNoNonce }
+func (h *Header) Nonce() uint64            { return h.nonce }
+func (h *Header) MixDigest() common.Hash   { return h.mixDigest }
+func (h *Header) NumberU64() uint64        { return h.number }
+
+// SealHash returns the hash of the RLP encoded header without its mix
+// digest and nonce, the header hash a seal is computed for.
+func SealHash(headerRLP []byte) (common.Hash, error) {
+	h, err := DecodeHeader(headerRLP)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return h.hashNoNonce, nil
+}
+
+// DecodeHeader decodes the RLP encoding of a block header, so seals of
+// raw headers can be verified and work packages built from them. Only
+// the fields used by the proof of work are decoded.
+func DecodeHeader(headerRLP []byte) (*Header, error) {
+	content, rest, isList, err := rlpSplit(headerRLP)
+	if err != nil {
+		return nil, err
+	}
+	if !isList || len(rest) != 0 {
+		return nil, errHeaderRLP
+	}
+	// split the list into the encodings of its items.
+	var items [][]byte
+	for len(content) > 0 {
+		_, next, _, err := rlpSplit(content)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, content[:len(content)-len(next)])
+		content = next
+	}
+	if len(items) <= headerNonce {
+		return nil, fmt.Errorf("header has %d fields, want at least %d", len(items), headerNonce+1)
+	}
+
+	h := new(Header)
+	difficulty, err := rlpString(items[headerDifficulty], 32)
+	if err != nil {
+		return nil, fmt.Errorf("difficulty: %v", err)
+	}
+	h.difficulty = new(big.Int).SetBytes(difficulty)
+	number, err := rlpString(items[headerNumber], 8)
+	if err != nil {
+		return nil, fmt.Errorf("number: %v", err)
+	}
+	h.number = new(big.Int).SetBytes(number).Uint64()
+	mix, err := rlpString(items[headerMixDigest], 32)
+	if err != nil || len(mix) != common.HashLength {
+		return nil, errors.New("mix digest: not 32 bytes")
+	}
+	h.mixDigest = common.BytesToHash(mix)
+	nonce, err := rlpString(items[headerNonce], 8)
+	if err != nil || len(nonce) != 8 {
+		return nil, errors.New("nonce: not 8 bytes")
+	}
+	h.nonce = binary.BigEndian.Uint64(nonce)
+
+	var unsealed []byte
+	for i, item := range items {
+		if i != headerMixDigest && i != headerNonce {
+			unsealed = append(unsealed, item...)
+		}
+	}
+	h.hashNoNonce = crypto.Sha3Hash(rlpListHeader(len(unsealed)), unsealed)
+	return h, nil
+}
+
+// rlpSplit splits the first RLP item off b. It returns the item's
+// content, the bytes after it and whether it is a list.
+func rlpSplit(b []byte) (content, rest []byte, isList bool, err error) {
+	if len(b) == 0 {
+		return nil, nil, false, errHeaderRLP
+	}
+	var (
+		prefix = b[0]
+		offset uint64
+		size   uint64
+	)
+	switch {
+	case prefix < 0x80:
+		return b[:1], b[1:], false, nil
+	case prefix < 0xb8:
+		offset, size = 1, uint64(prefix-0x80)
+	case prefix < 0xc0:
+		offset, size, err = rlpLongSize(b, prefix-0xb7)
+	case prefix < 0xf8:
+		offset, size, isList = 1, uint64(prefix-0xc0), true
+	default:
+		offset, size, err = rlpLongSize(b, prefix-0xf7)
+		isList = true
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if size > uint64(len(b))-offset {
+		return nil, nil, false, errHeaderRLP
+	}
+	return b[offset : offset+size], b[offset+size:], isList, nil
+}
+
+// rlpLongSize decodes the size of an item with a size of n bytes.
+func rlpLongSize(b []byte, n byte) (offset, size uint64, err error) {
+	if n > 8 || uint64(len(b)) < 1+uint64(n) || b[1] == 0 {
+		return 0, 0, errHeaderRLP
+	}
+	for _, c := range b[1 : 1+n] {
+		size = size<<8 | uint64(c)
+	}
+	if size < 56 {
+		return 0, 0, errHeaderRLP // should have used the short form
+	}
+	return 1 + uint64(n), size, nil
+}
+
+// rlpString returns the content of an encoded string of at most max
+// bytes.
+func rlpString(item []byte, max int) ([]byte, error) {
+	content, _, isList, err := rlpSplit(item)
+	if err != nil {
+		return nil, err
+	}
+	if isList || len(content) > max {
+		return nil, errHeaderRLP
+	}
+	return content, nil
+}
+
+// rlpListHeader returns the prefix of a list whose items take size
+// bytes.
+func rlpListHeader(size int) []byte {
+	if size < 56 {
+		return []byte{0xc0 + byte(size)}
+	}
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], uint64(size))
+	n := 0
+	for n < 7 && enc[n] == 0 {
+		n++
+	}
+	return append([]byte{0xf7 + byte(8-n)}, enc[n:]...)
+}
@@ -0,0 +1,102 @@
+package ethash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// rlpEncodeString encodes b as an RLP string.
+func rlpEncodeString(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	h := rlpListHeader(len(b))
+	h[0] -= 0x40 // list to string prefix
+	return append(h, b...)
+}
+
+// testHeader returns the RLP encoding of a header with the given seal
+// fields and extra fields after the nonce.
+func testHeader(number uint64, difficulty *big.Int, mix common.Hash, nonce uint64, extra ...[]byte) []byte {
+	var num, n [8]byte
+	binary.BigEndian.PutUint64(num[:], number)
+	binary.BigEndian.PutUint64(n[:], nonce)
+	fields := [][]byte{
+		make([]byte, 32), make([]byte, 32), make([]byte, 20), make([]byte, 32), make([]byte, 32), make([]byte, 32),
+		make([]byte, 256), // bloom
+		difficulty.Bytes(),
+		bytes.TrimLeft(num[:], "\x00"),
+		{0x2f, 0xef, 0xd8}, nil, {0x55, 0xba, 0x42, 0x24},
+		bytes.Repeat([]byte("x"), 40), // extra data
+		mix[:],
+		n[:],
+	}
+	fields = append(fields, extra...)
+	var content []byte
+	for _, f := range fields {
+		content = append(content, rlpEncodeString(f)...)
+	}
+	return append(rlpListHeader(len(content)), content...)
+}
+
+func TestDecodeHeader(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+
+	for _, extra := range [][][]byte{nil, {{0x07}}} {
+		difficulty := big.NewInt(10)
+		unsealed := testHeader(epochLength+1, difficulty, common.Hash{}, 0, extra...)
+		hash, err := SealHash(unsealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := &testBlock{number: epochLength + 1, difficulty: difficulty, hashNoNonce: hash}
+		block.seal(eth.Search(block, nil))
+		sealed := testHeader(block.number, difficulty, block.mixDigest, block.nonce, extra...)
+
+		h, err := DecodeHeader(sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.HashNoNonce() != hash {
+			t.Errorf("seal hash changed by the seal: %x, was %x", h.HashNoNonce(), hash)
+		}
+		if h.NumberU64() != block.number || h.Difficulty().Cmp(difficulty) != 0 || h.Nonce() != block.nonce || h.MixDigest() != block.mixDigest {
+			t.Errorf("decoded %+v from header of %+v", h, block)
+		}
+		if !eth.Verify(h) {
+			t.Error("decoded header did not verify")
+		}
+	}
+
+	// the seal hash covers everything but mix digest and nonce.
+	header := testHeader(3, big.NewInt(7), common.HexToHash("0x01"), 2)
+	hash, _ := SealHash(header)
+	items := header[3:] // long list prefix
+	unsealed := items[:len(items)-33-9]
+	if want := crypto.Sha3Hash(rlpListHeader(len(unsealed)), unsealed); hash != want {
+		t.Errorf("got seal hash %x, want %x", hash, want)
+	}
+
+	for name, b := range map[string][]byte{
+		"empty":      nil,
+		"truncated":  header[:len(header)-1],
+		"trailing":   append(append([]byte(nil), header...), 0),
+		"not a list": rlpEncodeString([]byte("header")),
+		"too short":  {0xc2, 0x01, 0x02},
+	} {
+		if _, err := DecodeHeader(b); err == nil {
+			t.Errorf("%s header decoded", name)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ethash
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// maxEpochHistory bounds the number of events kept for EpochHistory.
+const maxEpochHistory = 256
+
+// EpochEvent describes how a cache or DAG was made available.
+type EpochEvent struct {
+	Kind     string // "cache" or "DAG"
+	Epoch    uint64
+	Test     bool   // sizes of NewForTesting
+	Source   string // "computed", "gpu", "file", "download" or "handoff"
+	Dir      string // directory of the file, if any
+	Started  time.Time
+	Duration time.Duration // including the caches needed by a DAG
+}
+
+// epochHistory holds the most recent epoch events of the process.
+var epochHistory = struct {
+	sync.Mutex
+	events []EpochEvent
+	level  glog.Level
+}{level: logger.Info}
+
+// EpochHistory returns the most recent caches and DAGs made available
+// in the process, oldest first. They are kept across all Light and Full
+// instances, e.g. to plan the memory and time needed per epoch.
+func EpochHistory() []EpochEvent {
+	epochHistory.Lock()
+	defer epochHistory.Unlock()
+	return append([]EpochEvent(nil), epochHistory.events...)
+}
+
+// SetEpochLogLevel sets the verbosity at which the events returned by
+// EpochHistory are logged. The default is logger.Info.
+func SetEpochLogLevel(level glog.Level) {
+	epochHistory.Lock()
+	epochHistory.level = level
+	epochHistory.Unlock()
+}
+
+// recordEpoch logs ev and adds it to the history.
+func recordEpoch(ev EpochEvent) {
+	epochHistory.Lock()
+	defer epochHistory.Unlock()
+	epochHistory.events = append(epochHistory.events, ev)
+	if len(epochHistory.events) > maxEpochHistory {
+		epochHistory.events = epochHistory.events[1:]
+	}
+	v := glog.V(epochHistory.level)
+	switch ev.Source {
+	case "computed":
+		v.Infof("Generated %s for epoch %d, it took %v", ev.Kind, ev.Epoch, ev.Duration)
+	case "gpu":
+		v.Infof("Generated %s for epoch %d on the GPU, it took %v", ev.Kind, ev.Epoch, ev.Duration)
+	case "download":
+		v.Infof("Downloaded %s for epoch %d, it took %v", ev.Kind, ev.Epoch, ev.Duration)
+	case "handoff":
+		v.Infof("Took over %s for epoch %d from the previous process", ev.Kind, ev.Epoch)
+	default:
+		v.Infof("Loaded %s for epoch %d from %s, it took %v", ev.Kind, ev.Epoch, ev.Dir, ev.Duration)
+	}
+}
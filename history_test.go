@@ -0,0 +1,73 @@
+package ethash
+
+import (
+	"os"
+	"testing"
+)
+
+// newEpochEvents returns the events recorded since the history had n
+// entries.
+func newEpochEvents(n int) []EpochEvent {
+	h := EpochHistory()
+	if n > len(h) {
+		return nil
+	}
+	return h[n:]
+}
+
+func TestEpochHistory(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	n := len(EpochHistory())
+	ds, err := eth.Full.Dataset(epochLength * 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Release()
+	var cacheEv, dagEv *EpochEvent
+	for _, ev := range newEpochEvents(n) {
+		ev := ev
+		if ev.Epoch == 2 && ev.Test {
+			switch ev.Kind {
+			case "cache":
+				cacheEv = &ev
+			case "DAG":
+				dagEv = &ev
+			}
+		}
+	}
+	if cacheEv == nil || dagEv == nil {
+		t.Fatalf("cache and DAG of epoch 2 missing from history %v", newEpochEvents(n))
+	}
+	if dagEv.Source != "computed" || dagEv.Dir != eth.Full.Dir || dagEv.Started.IsZero() || dagEv.Duration <= 0 {
+		t.Errorf("unexpected DAG event %+v", *dagEv)
+	}
+	if cacheEv.Duration > dagEv.Duration {
+		t.Errorf("cache took %v, longer than the DAG including it, %v", cacheEv.Duration, dagEv.Duration)
+	}
+
+	// a second instance maps the file written by the first one.
+	n = len(EpochHistory())
+	eth.Full.FreeDAG()
+	other := &Full{Dir: eth.Full.Dir, test: true}
+	if ds, err = other.Dataset(epochLength * 2); err != nil {
+		t.Fatal(err)
+	}
+	ds.Release()
+	found := false
+	for _, ev := range newEpochEvents(n) {
+		if ev.Kind == "DAG" && ev.Epoch == 2 {
+			found = true
+			if ev.Source != "file" {
+				t.Errorf("DAG read from the file recorded as %q", ev.Source)
+			}
+		}
+	}
+	if !found {
+		t.Error("DAG read from the file missing from history")
+	}
+}
@@ -0,0 +1,128 @@
+// Package keccakpow implements a proof of work without cache or DAG,
+// for tests and toy chains which only need some proof of work.
+//
+// A seal is valid if keccak256(hashNoNonce ‖ nonce), with the nonce as
+// 8 big endian bytes, does not exceed 2^256 / difficulty. Seals carry
+// no mix digest, it must be zero. The engine is not secure against
+// ASICs or GPUs and must not be used for chains with real value.
+package keccakpow
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+var maxUint256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
+
+// PoW implements pow.PoW. The zero value is ready to use.
+type PoW struct {
+	mu      sync.Mutex // protects the fields below
+	hashes  uint64     // hashes computed by running searches
+	since   time.Time  // start of the oldest running search
+	running int        // number of running searches
+}
+
+var _ pow.PoW = (*PoW)(nil)
+
+// New returns a PoW.
+func New() *PoW {
+	return new(PoW)
+}
+
+// Hash returns the seal hash for the given header hash and nonce.
+func Hash(hashNoNonce common.Hash, nonce uint64) common.Hash {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], nonce)
+	return crypto.Sha3Hash(hashNoNonce[:], n[:])
+}
+
+// Verify checks whether the block's nonce meets its difficulty.
+func (p *PoW) Verify(block pow.Block) bool {
+	difficulty := block.Difficulty()
+	if difficulty == nil || difficulty.Sign() <= 0 || block.MixDigest() != (common.Hash{}) {
+		return false
+	}
+	target := new(big.Int).Div(maxUint256, difficulty)
+	return Hash(block.HashNoNonce(), block.Nonce()).Big().Cmp(target) <= 0
+}
+
+// Search looks for a nonce meeting the block's difficulty until one is
+// found or stop is closed. The returned mix digest is 32 zero bytes, or
+// nil if the search was stopped.
+func (p *PoW) Search(block pow.Block, stop <-chan struct{}) (nonce uint64, mixDigest []byte) {
+	difficulty := block.Difficulty()
+	if difficulty == nil || difficulty.Sign() <= 0 {
+		return 0, nil
+	}
+	p.start()
+	defer p.stop()
+
+	var (
+		hash   = block.HashNoNonce()
+		target = new(big.Int).Div(maxUint256, difficulty)
+		result = new(big.Int)
+	)
+	nonce = uint64(rand.Int63())
+	for {
+		select {
+		case <-stop:
+			return 0, nil
+		default:
+		}
+		for i := 0; i < 1024; i++ {
+			h := Hash(hash, nonce)
+			if result.SetBytes(h[:]).Cmp(target) <= 0 {
+				p.count(uint64(i) + 1)
+				return nonce, make([]byte, common.HashLength)
+			}
+			nonce++
+		}
+		p.count(1024)
+	}
+}
+
+// GetHashrate returns the combined hash rate of all running Search
+// calls in kH/s, like ethash does.
+func (p *PoW) GetHashrate() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running == 0 {
+		return 0
+	}
+	elapsed := time.Since(p.since).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(p.hashes) / elapsed / 1000)
+}
+
+// Turbo has no effect, searches always use a full core.
+func (p *PoW) Turbo(on bool) {}
+
+func (p *PoW) start() {
+	p.mu.Lock()
+	if p.running == 0 {
+		p.hashes, p.since = 0, time.Now()
+	}
+	p.running++
+	p.mu.Unlock()
+}
+
+func (p *PoW) stop() {
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+}
+
+func (p *PoW) count(n uint64) {
+	p.mu.Lock()
+	p.hashes += n
+	p.mu.Unlock()
+}
@@ -0,0 +1,69 @@
+package keccakpow
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type testBlock struct {
+	difficulty  *big.Int
+	hashNoNonce common.Hash
+	nonce       uint64
+	mixDigest   common.Hash
+}
+
+func (b *testBlock) Difficulty() *big.Int     { return b.difficulty }
+func (b *testBlock) HashNoNonce() common.Hash { return b.hashNoNonce }
+func (b *testBlock) Nonce() uint64            { return b.nonce }
+func (b *testBlock) MixDigest() common.Hash   { return b.mixDigest }
+func (b *testBlock) NumberU64() uint64        { return 0 }
+
+func TestSearchVerify(t *testing.T) {
+	p := New()
+	block := &testBlock{difficulty: big.NewInt(5000), hashNoNonce: common.HexToHash("0x1234")}
+	nonce, mix := p.Search(block, nil)
+	if mix == nil {
+		t.Fatal("search failed")
+	}
+	block.nonce = nonce
+	if !p.Verify(block) {
+		t.Fatal("found nonce did not verify")
+	}
+	block.mixDigest[0] = 1
+	if p.Verify(block) {
+		t.Error("block with mix digest verified")
+	}
+	block.mixDigest = common.Hash{}
+	block.difficulty = new(big.Int).Lsh(big.NewInt(1), 200)
+	if p.Verify(block) {
+		t.Error("nonce verified at much higher difficulty")
+	}
+}
+
+func TestSearchStop(t *testing.T) {
+	p := New()
+	block := &testBlock{difficulty: new(big.Int).Lsh(big.NewInt(1), 255)}
+	stop := make(chan struct{})
+	done := make(chan []byte)
+	go func() {
+		_, mix := p.Search(block, stop)
+		done <- mix
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.GetHashrate() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no hash rate reported during search")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	if mix := <-done; mix != nil {
+		t.Error("stopped search returned a seal")
+	}
+	if rate := p.GetHashrate(); rate != 0 {
+		t.Errorf("hash rate %d after search returned", rate)
+	}
+}
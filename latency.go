@@ -0,0 +1,125 @@
+package ethash
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the histogram buckets, from
+// a hash with a cached cache up to generating the cache of a late
+// epoch. Longer durations are counted in a final bucket.
+var latencyBuckets = func() []time.Duration {
+	b := make([]time.Duration, 21)
+	for i := range b {
+		b[i] = 100 * time.Microsecond << uint(i)
+	}
+	return b
+}()
+
+// Histogram counts durations in buckets.
+type Histogram struct {
+	Bounds []time.Duration // upper bound of each bucket but the last, which is unbounded
+	Counts []uint64        // durations per bucket, one more than Bounds
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Mean returns the average duration.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket holding the q-th
+// quantile, 0 < q <= 1, or the largest bound if it falls in the last
+// bucket.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	var n uint64
+	for i, c := range h.Counts {
+		if n += c; n >= rank && i < len(h.Bounds) {
+			return h.Bounds[i]
+		}
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// VerifyLatency holds the durations of verifications with a cache,
+// split by whether the cache was ready or the verification had to wait
+// for it to be generated or loaded. Many of the latter for old epochs
+// mean caches are rebuilt over and over, see Light.SetCachesInMem.
+type VerifyLatency struct {
+	CacheHit   Histogram
+	CacheBuild Histogram
+}
+
+// latencyCounter is a histogram updated atomically.
+type latencyCounter struct {
+	counts [22]uint64 // len(latencyBuckets) + 1
+	count  uint64
+	nanos  uint64
+}
+
+var verifyLatency struct {
+	hit, build latencyCounter
+}
+
+// GetVerifyLatency returns the verification durations of all Light
+// instances in the process.
+func GetVerifyLatency() VerifyLatency {
+	return VerifyLatency{
+		CacheHit:   verifyLatency.hit.histogram(),
+		CacheBuild: verifyLatency.build.histogram(),
+	}
+}
+
+// ResetVerifyLatency sets all counters to zero.
+func ResetVerifyLatency() {
+	for _, c := range []*latencyCounter{&verifyLatency.hit, &verifyLatency.build} {
+		for i := range c.counts {
+			atomic.StoreUint64(&c.counts[i], 0)
+		}
+		atomic.StoreUint64(&c.count, 0)
+		atomic.StoreUint64(&c.nanos, 0)
+	}
+}
+
+// observeVerify records a verification that started at the given time.
+// built is set if it waited for the cache.
+func observeVerify(started time.Time, built bool) {
+	c := &verifyLatency.hit
+	if built {
+		c = &verifyLatency.build
+	}
+	c.observe(time.Since(started))
+}
+
+// observe records a duration.
+func (c *latencyCounter) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	atomic.AddUint64(&c.counts[i], 1)
+	atomic.AddUint64(&c.count, 1)
+	atomic.AddUint64(&c.nanos, uint64(d))
+}
+
+func (c *latencyCounter) histogram() Histogram {
+	h := Histogram{
+		Bounds: append([]time.Duration(nil), latencyBuckets...),
+		Counts: make([]uint64, len(c.counts)),
+		Count:  atomic.LoadUint64(&c.count),
+		Sum:    time.Duration(atomic.LoadUint64(&c.nanos)),
+	}
+	for i := range c.counts {
+		h.Counts[i] = atomic.LoadUint64(&c.counts[i])
+	}
+	return h
+}
@@ -0,0 +1,51 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVerifyLatency(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+	eth.SetLookahead(NoLookaheadLimit)
+
+	block := &testBlock{difficulty: big.NewInt(10), number: 17 * epochLength}
+	block.seal(eth.Search(block, nil))
+	eth.Full.FreeDAG()
+	ResetVerifyLatency()
+	for i := 0; i < 3; i++ {
+		if !eth.Verify(block) {
+			t.Fatal("block did not verify")
+		}
+	}
+	lat := GetVerifyLatency()
+	if lat.CacheBuild.Count != 1 || lat.CacheHit.Count != 2 {
+		t.Fatalf("got %d verifications building and %d hitting the cache, want 1 and 2", lat.CacheBuild.Count, lat.CacheHit.Count)
+	}
+	for _, h := range []Histogram{lat.CacheBuild, lat.CacheHit} {
+		var n uint64
+		for _, c := range h.Counts {
+			n += c
+		}
+		if n != h.Count || len(h.Counts) != len(h.Bounds)+1 || h.Sum <= 0 {
+			t.Errorf("inconsistent histogram %+v", h)
+		}
+	}
+
+	h := Histogram{Bounds: []time.Duration{1, 2, 4}, Counts: []uint64{1, 2, 0, 1}, Count: 4, Sum: 12}
+	for q, want := range map[float64]time.Duration{0.25: 1, 0.5: 2, 0.75: 2, 1: 4} {
+		if got := h.Quantile(q); got != want {
+			t.Errorf("quantile %v: got %v, want %v", q, got, want)
+		}
+	}
+	if h.Mean() != 3 {
+		t.Errorf("got mean %v, want 3", h.Mean())
+	}
+}
@@ -0,0 +1,7 @@
+package ethash
+
+// lockFile is a no-op on Windows, where DAG files are not shared
+// between processes.
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,86 @@
+package ethash
+
+// epochItem is a cache or DAG held in an lru.
+type epochItem interface {
+	epochNum() uint64
+	release()
+}
+
+func (cache *cache) epochNum() uint64 { return cache.epoch }
+func (d *dag) epochNum() uint64       { return d.epoch }
+
+// lru holds references to the most recently used items of a bounded
+// number of epochs. Evicted items are released, which frees them as
+// soon as no search or verification is using them anymore.
+//
+// lru is not safe for concurrent use.
+type lru struct {
+	max   int         // maximum number of items, zero means one
+	items []epochItem // least recently used first
+}
+
+// get returns the item of the given epoch and marks it as most
+// recently used. It returns nil if there is no such item.
+func (l *lru) get(epoch uint64) epochItem {
+	for i, item := range l.items {
+		if item.epochNum() == epoch {
+			copy(l.items[i:], l.items[i+1:])
+			l.items[len(l.items)-1] = item
+			return item
+		}
+	}
+	return nil
+}
+
+// add inserts item as the most recently used one. The lru takes over
+// the caller's reference.
+func (l *lru) add(item epochItem) {
+	l.items = append(l.items, item)
+	l.evict()
+}
+
+// remove releases the item of the given epoch, if any.
+func (l *lru) remove(epoch uint64) {
+	for i, item := range l.items {
+		if item.epochNum() == epoch {
+			item.release()
+			l.items = append(l.items[:i], l.items[i+1:]...)
+			return
+		}
+	}
+}
+
+// setMax changes the number of items held, evicting items if needed.
+func (l *lru) setMax(max int) {
+	l.max = max
+	l.evict()
+}
+
+// clear releases all items.
+func (l *lru) clear() {
+	for _, item := range l.items {
+		item.release()
+	}
+	l.items = nil
+}
+
+// limit returns the number of items held at most.
+func (l *lru) limit() int {
+	if l.max < 1 {
+		return 1
+	}
+	return l.max
+}
+
+func (l *lru) evict() {
+	l.trim(l.limit())
+}
+
+// trim releases the least recently used items until at most n are
+// left, regardless of the limit.
+func (l *lru) trim(n int) {
+	for len(l.items) > n {
+		l.items[0].release()
+		l.items = l.items[1:]
+	}
+}
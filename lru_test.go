@@ -0,0 +1,37 @@
+package ethash
+
+import "testing"
+
+type testItem struct {
+	epoch    uint64
+	released bool
+}
+
+func (it *testItem) epochNum() uint64 { return it.epoch }
+func (it *testItem) release()         { it.released = true }
+
+func TestLRUEviction(t *testing.T) {
+	l := lru{max: 2}
+	a, b, c := &testItem{epoch: 1}, &testItem{epoch: 2}, &testItem{epoch: 3}
+	l.add(a)
+	l.add(b)
+	if l.get(1) != a {
+		t.Fatal("item 1 not found")
+	}
+	// b is now the least recently used item.
+	l.add(c)
+	if !b.released || a.released || c.released {
+		t.Errorf("wrong item evicted: released a=%t b=%t c=%t", a.released, b.released, c.released)
+	}
+	if l.get(2) != nil {
+		t.Error("evicted item still returned")
+	}
+	l.setMax(0)
+	if !a.released || c.released {
+		t.Errorf("shrinking to default did not evict the older item")
+	}
+	l.clear()
+	if !c.released || len(l.items) != 0 {
+		t.Error("clear did not release all items")
+	}
+}
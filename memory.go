@@ -0,0 +1,93 @@
+package ethash
+
+import "sync"
+
+// MemStats describes the memory held by C allocations of this package.
+type MemStats struct {
+	Caches  map[uint64]uint64 // verification cache bytes by epoch
+	DAGs    map[uint64]uint64 // mapped DAG bytes by epoch
+	Scratch uint64            // temporary caches, e.g. to check downloads
+}
+
+// Total returns the number of bytes in all allocations.
+func (s MemStats) Total() uint64 {
+	total := s.Scratch
+	for _, n := range s.Caches {
+		total += n
+	}
+	for _, n := range s.DAGs {
+		total += n
+	}
+	return total
+}
+
+// MemoryStats returns the amount of memory currently held by caches
+// and DAGs of all Light and Full instances in the process. Caches
+// used while generating DAGs are included, as are the caches generated
+// to check bootstrapped cache downloads.
+func MemoryStats() MemStats {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	s := MemStats{
+		Caches:  make(map[uint64]uint64, len(memory.caches)),
+		DAGs:    make(map[uint64]uint64, len(memory.dags)),
+		Scratch: memory.scratch,
+	}
+	for epoch, n := range memory.caches {
+		s.Caches[epoch] = n
+	}
+	for epoch, n := range memory.dags {
+		s.DAGs[epoch] = n
+	}
+	return s
+}
+
+// MemoryNeeded returns the number of bytes taken by the given numbers of
+// verification caches and DAGs for the most recent epochs up to epoch,
+// as held at most by a Light and Full following a chain at that epoch
+// with SetCachesInMem(caches) and SetDatasetsInMem(datasets). Numbers
+// below one count as one, like for the setters. Generating a DAG takes
+// the cache of its epoch in addition.
+func MemoryNeeded(epoch uint64, caches, datasets int) uint64 {
+	var total uint64
+	for i := 0; i == 0 || i < caches && uint64(i) <= epoch; i++ {
+		total += cacheSize(epoch-uint64(i), false)
+	}
+	for i := 0; i == 0 || i < datasets && uint64(i) <= epoch; i++ {
+		total += datasetSize(epoch-uint64(i), false)
+	}
+	return total
+}
+
+// memory counts allocated bytes. Only sizes are recorded so that
+// the accounting does not keep caches and DAGs reachable.
+var memory = struct {
+	mu      sync.Mutex
+	caches  map[uint64]uint64
+	dags    map[uint64]uint64
+	scratch uint64
+}{
+	caches: make(map[uint64]uint64),
+	dags:   make(map[uint64]uint64),
+}
+
+func trackAlloc(m map[uint64]uint64, epoch, size uint64) {
+	memory.mu.Lock()
+	m[epoch] += size
+	memory.mu.Unlock()
+}
+
+func trackFree(m map[uint64]uint64, epoch, size uint64) {
+	memory.mu.Lock()
+	if m[epoch] -= size; m[epoch] == 0 {
+		delete(m, epoch)
+	}
+	memory.mu.Unlock()
+}
+
+// trackScratch adds delta bytes to the scratch allocations.
+func trackScratch(delta int64) {
+	memory.mu.Lock()
+	memory.scratch = uint64(int64(memory.scratch) + delta)
+	memory.mu.Unlock()
+}
@@ -0,0 +1,56 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"testing"
+)
+
+func TestMemoryStats(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	eth.SetLookahead(NoLookaheadLimit)
+	before := MemoryStats()
+
+	block := &testBlock{number: 9 * epochLength, difficulty: big.NewInt(10)}
+	block.seal(eth.Search(block, nil))
+	if !eth.Verify(block) {
+		t.Fatal("mined block not verified")
+	}
+	stats := MemoryStats()
+	if got, want := stats.DAGs[9], before.DAGs[9]+datasetSize(9, true); got != want {
+		t.Errorf("DAG memory of epoch 9 is %d, want %d", got, want)
+	}
+	if got, want := stats.Caches[9], cacheSize(9, true); got < want {
+		t.Errorf("cache memory of epoch 9 is %d, want at least %d", got, want)
+	}
+	var total uint64
+	for _, n := range stats.Caches {
+		total += n
+	}
+	for _, n := range stats.DAGs {
+		total += n
+	}
+	if got, want := stats.Total(), total+stats.Scratch; got != want {
+		t.Errorf("total is %d, want %d", got, want)
+	}
+
+	trackScratch(100)
+	if got, want := MemoryStats().Scratch, stats.Scratch+100; got != want {
+		t.Errorf("scratch memory is %d, want %d", got, want)
+	}
+	trackScratch(-100)
+
+	eth.FreeDAG()
+	eth.FreeCache()
+	after := MemoryStats()
+	if after.DAGs[9] != before.DAGs[9] {
+		t.Errorf("DAG memory of epoch 9 is %d after FreeDAG, want %d", after.DAGs[9], before.DAGs[9])
+	}
+	if after.Caches[9] > before.Caches[9] {
+		t.Errorf("cache memory of epoch 9 is %d after FreeCache, want %d", after.Caches[9], before.Caches[9])
+	}
+}
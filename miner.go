@@ -0,0 +1,55 @@
+package ethash
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+// workPollInterval is how often Mine asks its WorkSource for the
+// current block.
+const workPollInterval = 250 * time.Millisecond
+
+// WorkSource provides the block that should currently be mined,
+// usually the successor of the chain head.
+type WorkSource interface {
+	Work() pow.Block
+}
+
+// Mine searches a nonce for the block provided by src until a valid
+// nonce is found or stop is closed. The source is polled while
+// searching. When it moves on to a block of a different epoch, e.g.
+// because the chain crossed an epoch boundary, the DAG of the new
+// epoch is loaded and the search restarts on the new block. A new
+// block of the same epoch doesn't interrupt the search, see Controller
+// for switching to every new head.
+//
+// Mine returns the block for which the nonce was found, or nil if
+// stop was closed or the DAG for the block is not available.
+func (pow *Full) Mine(src WorkSource, stop <-chan struct{}) (pow.Block, uint64, []byte) {
+	block := src.Work()
+	for {
+		epoch := block.NumberU64() / epochLength
+		next := block
+		nonce, mixDigest, found := pow.search(block, stop, func() bool {
+			next = src.Work()
+			return next.NumberU64()/epochLength != epoch
+		})
+		if found {
+			return block, nonce, mixDigest
+		}
+		select {
+		case <-stop:
+			return nil, 0, nil
+		default:
+		}
+		if next.NumberU64()/epochLength == epoch {
+			// The search failed without the work having moved.
+			return nil, 0, nil
+		}
+		glog.V(logger.Info).Infof("Work moved from epoch %d to %d, restarting search", epoch, next.NumberU64()/epochLength)
+		block = next
+	}
+}
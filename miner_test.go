@@ -0,0 +1,68 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+type testWorkSource struct {
+	mu    sync.Mutex
+	block pow.Block
+}
+
+func (s *testWorkSource) Work() pow.Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.block
+}
+
+func (s *testWorkSource) set(block pow.Block) {
+	s.mu.Lock()
+	s.block = block
+	s.mu.Unlock()
+}
+
+func TestMineRestartsOnEpochChange(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	// the first block can't be mined in reasonable time.
+	src := &testWorkSource{block: &testBlock{number: epochLength - 1, difficulty: new(big.Int).Lsh(big.NewInt(1), 255)}}
+	next := &testBlock{number: epochLength, difficulty: big.NewInt(10)}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		src.set(next)
+	}()
+
+	block, nonce, mix := eth.Mine(src, nil)
+	if block != next {
+		t.Fatalf("mined block %d, want %d", block.NumberU64(), next.number)
+	}
+	next.seal(nonce, mix)
+	if !eth.Verify(next) {
+		t.Error("nonce found after epoch change could not be verified")
+	}
+}
+
+func TestMineStop(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	src := &testWorkSource{block: &testBlock{difficulty: new(big.Int).Lsh(big.NewInt(1), 255)}}
+	stop := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+	if block, _, _ := eth.Mine(src, stop); block != nil {
+		t.Error("Mine returned a block after stop")
+	}
+}
@@ -0,0 +1,70 @@
+package ethash
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPace is the average time between hashes when turbo
+	// mode is off and no other pace has been configured.
+	defaultPace = 20 * time.Microsecond
+	// paceBurst is the amount of unused hashing time that can be
+	// saved up and spent at full speed later on.
+	paceBurst = 50 * time.Millisecond
+)
+
+// pacer is a token bucket limiting the combined rate of the search
+// loops sharing it. One token accrues per interval, up to a burst of
+// paceBurst worth of tokens, and each hash consumes one.
+//
+// Tokens may go negative; a caller that drives the balance below zero
+// sleeps until its token would have accrued. This keeps the average
+// rate accurate even though sleeps are much coarser than the interval.
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	tokens   float64
+	last     time.Time
+	clock    Clock
+}
+
+// setInterval changes the time between two hashes. Zero selects
+// defaultPace.
+func (p *pacer) setInterval(interval time.Duration) {
+	p.mu.Lock()
+	p.interval = interval
+	p.mu.Unlock()
+}
+
+// setClock sets the clock the tokens accrue and callers sleep by.
+func (p *pacer) setClock(c Clock) {
+	p.mu.Lock()
+	p.clock = c
+	p.mu.Unlock()
+}
+
+// wait takes a token from the bucket, sleeping if none is available.
+func (p *pacer) wait() {
+	p.mu.Lock()
+	interval := p.interval
+	if interval <= 0 {
+		interval = defaultPace
+	}
+	clock := orSystem(p.clock)
+	now := clock.Now()
+	if !p.last.IsZero() {
+		p.tokens += float64(now.Sub(p.last)) / float64(interval)
+	}
+	if burst := float64(paceBurst) / float64(interval); p.tokens > burst {
+		p.tokens = burst
+	}
+	p.last = now
+	p.tokens--
+	debt := -p.tokens
+	p.mu.Unlock()
+
+	if debt > 0 {
+		clock.Sleep(time.Duration(debt * float64(interval)))
+	}
+}
@@ -0,0 +1,36 @@
+package ethash
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPacerRate(t *testing.T) {
+	var p pacer
+	p.setInterval(time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		p.wait()
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("100 tokens at 1ms took %v, want at least 90ms", elapsed)
+	}
+}
+
+func TestPacerBurst(t *testing.T) {
+	var p pacer
+	p.setInterval(time.Millisecond)
+	p.wait()
+
+	// idle time is saved up, but only up to paceBurst.
+	time.Sleep(2 * paceBurst)
+	start := time.Now()
+	n := int(paceBurst/time.Millisecond) + 20
+	for i := 0; i < n; i++ {
+		p.wait()
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("burst of %d tokens took %v, want at least 15ms", n, elapsed)
+	}
+}
@@ -0,0 +1,137 @@
+package ethash
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// MemoryPressure tells how urgently memory should be given back, see
+// RelieveMemoryPressure.
+type MemoryPressure int
+
+const (
+	// NoPressure leaves caches and DAGs alone.
+	NoPressure MemoryPressure = iota
+	// ModeratePressure releases all but the most recently used cache
+	// and DAG.
+	ModeratePressure
+	// CriticalPressure releases all caches and DAGs. They are
+	// regenerated, or loaded from their files, when needed again.
+	CriticalPressure
+)
+
+// moderateMemoryShare is the share of the memory limit above which
+// WatchMemoryLimit reports moderate pressure.
+const moderateMemoryShare = 0.9
+
+// RelieveMemoryPressure releases verification caches as requested by
+// p, e.g. when the host signals that memory is short, instead of
+// waiting for the limit set with SetCachesInMem to evict them. Verify
+// calls in progress keep their cache.
+func (l *Light) RelieveMemoryPressure(p MemoryPressure) {
+	if p == NoPressure {
+		return
+	}
+	keep := 1
+	if p >= CriticalPressure {
+		keep = 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.caches.trim(keep)
+	if l.next != nil {
+		l.next.release()
+		l.next = nil
+	}
+}
+
+// RelieveMemoryPressure releases in-memory DAGs as requested by p, like
+// Light.RelieveMemoryPressure does for caches. Search calls in progress
+// keep their DAG, its memory is unmapped when they are done.
+func (pow *Full) RelieveMemoryPressure(p MemoryPressure) {
+	if p == NoPressure {
+		return
+	}
+	keep := 1
+	if p >= CriticalPressure {
+		keep = 0
+	}
+	pow.mu.Lock()
+	pow.dags.trim(keep)
+	pow.mu.Unlock()
+}
+
+// RelieveMemoryPressure releases the caches and DAGs of e as requested
+// by p.
+func (e *Ethash) RelieveMemoryPressure(p MemoryPressure) {
+	e.Light.RelieveMemoryPressure(p)
+	e.Full.RelieveMemoryPressure(p)
+}
+
+// WatchMemoryLimit checks the memory used by the process every interval
+// against the limit set with debug.SetMemoryLimit or GOMEMLIMIT and
+// calls relieve, e.g. Ethash.RelieveMemoryPressure, while the limit is
+// approached or exceeded. The Go runtime doesn't count the caches and
+// DAGs, which are allocated in C, so it can't keep the process below
+// the limit by collecting garbage alone. Nothing is done while no limit
+// is set. The returned function stops watching.
+func WatchMemoryLimit(interval time.Duration, relieve func(MemoryPressure)) (stop func()) {
+	quit := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := NoPressure
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				limit := debug.SetMemoryLimit(-1)
+				if limit == math.MaxInt64 {
+					continue
+				}
+				used := goMemory() + MemoryStats().Total()
+				p := memoryPressure(used, uint64(limit))
+				if p > last {
+					glog.V(logger.Info).Infof("Using %d of %d bytes of memory, releasing caches and DAGs", used, limit)
+				}
+				if last = p; p != NoPressure {
+					relieve(p)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(quit) }) }
+}
+
+// memoryPressure returns the pressure of using used of limit bytes.
+func memoryPressure(used, limit uint64) MemoryPressure {
+	switch {
+	case used > limit:
+		return CriticalPressure
+	case float64(used) > moderateMemoryShare*float64(limit):
+		return ModeratePressure
+	}
+	return NoPressure
+}
+
+// goMemory returns the memory held by the Go runtime, the amount the
+// memory limit applies to.
+func goMemory() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
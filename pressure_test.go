@@ -0,0 +1,110 @@
+package ethash
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"os"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestMemoryPressure(t *testing.T) {
+	for _, test := range []struct {
+		used, limit uint64
+		want        MemoryPressure
+	}{
+		{0, 100, NoPressure},
+		{90, 100, NoPressure},
+		{91, 100, ModeratePressure},
+		{100, 100, ModeratePressure},
+		{101, 100, CriticalPressure},
+	} {
+		if p := memoryPressure(test.used, test.limit); p != test.want {
+			t.Errorf("%d of %d bytes: got pressure %d, want %d", test.used, test.limit, p, test.want)
+		}
+	}
+	if goMemory() == 0 {
+		t.Error("no memory used by the Go runtime")
+	}
+}
+
+func TestRelieveMemoryPressure(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	defer eth.Light.FreeCache()
+	defer eth.Full.FreeDAG()
+
+	eth.SetLookahead(NoLookaheadLimit)
+	eth.SetCachesInMem(3)
+	eth.SetDatasetsInMem(2)
+	for epoch := uint64(0); epoch < 3; epoch++ {
+		// the blocks pass the quick check, so they reach the cache.
+		eth.Verify(&testBlock{number: epoch * epochLength, difficulty: big.NewInt(1)})
+	}
+	for epoch := uint64(0); epoch < 2; epoch++ {
+		if err := <-eth.WarmDAG(context.Background(), epoch*epochLength); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counts := func() (caches, dags int) {
+		eth.Light.mu.Lock()
+		caches = len(eth.Light.caches.items)
+		eth.Light.mu.Unlock()
+		eth.Full.mu.Lock()
+		dags = len(eth.Full.dags.items)
+		eth.Full.mu.Unlock()
+		return caches, dags
+	}
+	if c, d := counts(); c != 3 || d != 2 {
+		t.Fatalf("holding %d caches and %d DAGs, want 3 and 2", c, d)
+	}
+	eth.RelieveMemoryPressure(NoPressure)
+	if c, d := counts(); c != 3 || d != 2 {
+		t.Errorf("without pressure: holding %d caches and %d DAGs, want 3 and 2", c, d)
+	}
+	eth.RelieveMemoryPressure(ModeratePressure)
+	if c, d := counts(); c != 1 || d != 1 {
+		t.Errorf("moderate pressure: holding %d caches and %d DAGs, want 1 each", c, d)
+	}
+	if !eth.Ready(1 * epochLength) {
+		t.Error("moderate pressure released the most recently used DAG")
+	}
+	eth.RelieveMemoryPressure(CriticalPressure)
+	if c, d := counts(); c != 0 || d != 0 {
+		t.Errorf("critical pressure: holding %d caches and %d DAGs, want none", c, d)
+	}
+}
+
+func TestWatchMemoryLimit(t *testing.T) {
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(math.MaxInt64))
+	got := make(chan MemoryPressure, 1)
+	stop := WatchMemoryLimit(10*time.Millisecond, func(p MemoryPressure) {
+		select {
+		case got <- p:
+		default:
+		}
+	})
+	defer stop()
+
+	// without limit, nothing is released.
+	select {
+	case p := <-got:
+		t.Fatalf("pressure %d reported without memory limit", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// any process exceeds a limit of one byte.
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(1))
+	select {
+	case p := <-got:
+		if p != CriticalPressure {
+			t.Errorf("got pressure %d above the limit, want critical", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pressure reported above the memory limit")
+	}
+}
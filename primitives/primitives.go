@@ -0,0 +1,38 @@
+// Package primitives contains the FNV based mixing steps of ethash.
+//
+// The functions mirror the C implementation used by package ethash
+// word for word and are kept stable so that external implementations
+// and property based tests can be checked against them.
+package primitives
+
+// FnvPrime is the 32 bit FNV prime used by ethash.
+const FnvPrime = 0x01000193
+
+// Fnv combines two words. Unlike FNV-1, ethash multiplies the full
+// 32 bit word x rather than a single byte.
+func Fnv(x, y uint32) uint32 {
+	return x*FnvPrime ^ y
+}
+
+// FnvHash mixes data into mix word by word. It is the step applied for
+// every parent during dataset item generation and for every dataset
+// page accessed during hashing. Only min(len(mix), len(data)) words
+// are processed.
+func FnvHash(mix, data []uint32) {
+	for i := 0; i < len(mix) && i < len(data); i++ {
+		mix[i] = Fnv(mix[i], data[i])
+	}
+}
+
+// ReduceMix compresses a mix into a digest a quarter of its length by
+// folding each group of four words with Fnv. In ethash the 32 word mix
+// becomes the 8 word (32 byte) mix digest. Trailing words that do not
+// form a full group are ignored.
+func ReduceMix(mix []uint32) []uint32 {
+	digest := make([]uint32, len(mix)/4)
+	for i := range digest {
+		w := mix[4*i : 4*i+4]
+		digest[i] = Fnv(Fnv(Fnv(w[0], w[1]), w[2]), w[3])
+	}
+	return digest
+}
@@ -0,0 +1,62 @@
+package primitives
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+func TestFnv(t *testing.T) {
+	tests := []struct{ x, y, want uint32 }{
+		{0, 0, 0},
+		{1, 0, FnvPrime},
+		{0, 0xffffffff, 0xffffffff},
+		{0x12345678, 0x9abcdef0, 0xbad8c018},
+	}
+	for _, tt := range tests {
+		if got := Fnv(tt.x, tt.y); got != tt.want {
+			t.Errorf("Fnv(%#x, %#x) = %#x, want %#x", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestFnvHashMatchesFnv(t *testing.T) {
+	f := func(mix, data []uint32) bool {
+		orig := append([]uint32(nil), mix...)
+		FnvHash(mix, data)
+		for i := range mix {
+			want := orig[i]
+			if i < len(data) {
+				want = Fnv(orig[i], data[i])
+			}
+			if mix[i] != want {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReduceMix(t *testing.T) {
+	f := func(words [32]uint32) bool {
+		digest := ReduceMix(words[:])
+		if len(digest) != 8 {
+			return false
+		}
+		for i, d := range digest {
+			r := words[4*i]
+			for _, w := range words[4*i+1 : 4*i+4] {
+				r = r*FnvPrime ^ w
+			}
+			if d != r {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
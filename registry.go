@@ -0,0 +1,141 @@
+package ethash
+
+import "sync"
+
+// regKey identifies a cache or DAG in the registry.
+type regKey struct {
+	epoch uint64
+	test  bool
+	dir   string // DAG directory, empty for caches
+}
+
+// registry holds the caches and DAGs that are in use in the process.
+// All Light and Full instances share them, so that memory is not
+// spent on several copies of the same epoch and a generation that is
+// in progress is not started again, which for DAGs would also mean
+// several writers of the same file. An entry is removed when its last
+// reference is released.
+type registry struct {
+	mu     sync.Mutex
+	caches map[regKey]*cache
+	dags   map[regKey]*dag
+}
+
+var shared = &registry{
+	caches: make(map[regKey]*cache),
+	dags:   make(map[regKey]*dag),
+}
+
+// cacheConfig holds the settings for obtaining and storing a cache.
+// They are taken from the caller which first requests the cache.
+type cacheConfig struct {
+	dir       string // directory of the cache file, empty if none
+	bootstrap *CacheBootstrap
+}
+
+// newCache returns the cache for the given epoch, which is generated
+// if nobody in the process uses that cache yet. The caller owns a
+// reference to the returned cache.
+func newCache(epoch uint64, test bool, config cacheConfig) *cache {
+	key := regKey{epoch: epoch, test: test}
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	c := shared.caches[key]
+	if c == nil {
+		c = &cache{epoch: epoch, test: test, dir: config.dir, bootstrap: config.bootstrap}
+		shared.caches[key] = c
+	}
+	c.refs.acquire()
+	return c
+}
+
+// dagConfig holds the settings for generating and storing a DAG.
+// They are taken from the Full which first requests the DAG.
+type dagConfig struct {
+	limits   GenerationLimits
+	compress bool
+	clock    Clock // nil for the system clock
+	gpu      bool  // see Full.SetGPUDAG
+}
+
+// newDAG is like newCache, for DAGs stored in dir. If dir is the empty
+// string, the default directory is used.
+func newDAG(epoch uint64, test bool, dir string, config dagConfig) *dag {
+	if dir == "" {
+		dir = DefaultDir
+	}
+	key := regKey{epoch: epoch, test: test, dir: dir}
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	d := shared.dags[key]
+	if d == nil {
+		d = &dag{epoch: epoch, test: test, dir: dir, limits: config.limits, compress: config.compress, clock: config.clock, gpu: config.gpu}
+		shared.dags[key] = d
+	}
+	d.refs.acquire()
+	return d
+}
+
+// findDAG returns a registered DAG of the given epoch stored in any
+// directory, preferring dir, or nil if there is none. The caller owns a
+// reference to the returned DAG.
+func (r *registry) findDAG(epoch uint64, test bool, dir string) *dag {
+	if dir == "" {
+		dir = DefaultDir
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.dags[regKey{epoch: epoch, test: test, dir: dir}]
+	if d == nil {
+		for key, other := range r.dags {
+			if key.epoch == epoch && key.test == test {
+				d = other
+				break
+			}
+		}
+	}
+	if d != nil {
+		d.refs.acquire()
+	}
+	return d
+}
+
+// releaseCache drops a reference to c and removes it from the registry
+// if it was the last one. It reports whether c should be freed.
+func (r *registry) releaseCache(c *cache) bool {
+	key := regKey{epoch: c.epoch, test: c.test}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !c.refs.release() {
+		return false
+	}
+	if r.caches[key] == c {
+		delete(r.caches, key)
+	}
+	return true
+}
+
+// evictCache removes c from the registry, so that the next newCache
+// for its epoch creates a new cache. Current users keep c.
+func (r *registry) evictCache(c *cache) {
+	key := regKey{epoch: c.epoch, test: c.test}
+	r.mu.Lock()
+	if r.caches[key] == c {
+		delete(r.caches, key)
+	}
+	r.mu.Unlock()
+}
+
+// releaseDAG is like releaseCache, for DAGs.
+func (r *registry) releaseDAG(d *dag) bool {
+	key := regKey{epoch: d.epoch, test: d.test, dir: d.dir}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !d.refs.release() {
+		return false
+	}
+	if r.dags[key] == d {
+		delete(r.dags, key)
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"testing"
+)
+
+func TestRegistryShared(t *testing.T) {
+	c1 := newCache(11, true, cacheConfig{})
+	c2 := newCache(11, true, cacheConfig{})
+	if c1 != c2 {
+		t.Fatal("concurrent requests for the same epoch got different caches")
+	}
+	if other := newCache(11, false, cacheConfig{}); other == c1 {
+		t.Error("test and regular cache are shared")
+	} else {
+		other.release()
+	}
+	c1.generate()
+	c2.generate()
+	c3 := newCache(11, true, cacheConfig{})
+	if c3 != c1 {
+		t.Error("generated cache not shared while referenced")
+	}
+	c3.release()
+	c1.release()
+	if c1.ptr == nil {
+		t.Fatal("cache freed while still referenced")
+	}
+	c2.release()
+	if c1.ptr != nil {
+		t.Error("cache not freed after last release")
+	}
+	if c4 := newCache(11, true, cacheConfig{}); c4 == c1 {
+		t.Error("freed cache returned from registry")
+	} else {
+		c4.release()
+	}
+}
+
+func TestRegistrySharedBetweenInstances(t *testing.T) {
+	eth1, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth1.Full.Dir)
+	eth2, _ := NewForTesting()
+	os.RemoveAll(eth2.Full.Dir)
+	eth2.Full.Dir = eth1.Full.Dir
+	before := MemoryStats()
+
+	block := &testBlock{number: 5 * epochLength, difficulty: big.NewInt(10)}
+	for _, eth := range []*Ethash{eth1, eth2} {
+		if _, mix := eth.Search(block, nil); mix == nil {
+			t.Fatal("search failed")
+		}
+	}
+	d1, _ := eth1.Full.getDAG(block.number)
+	d2, _ := eth2.Full.getDAG(block.number)
+	d1.release()
+	d2.release()
+	if d1 != d2 {
+		t.Error("instances with the same directory use different DAGs")
+	}
+	if got, want := MemoryStats().DAGs[5], before.DAGs[5]+uint64(dagSizeForTesting); got != want {
+		t.Errorf("DAG memory of epoch 5 is %d, want %d", got, want)
+	}
+
+	eth1.FreeDAG()
+	if d1.ptr == nil {
+		t.Fatal("DAG freed while used by another instance")
+	}
+	eth2.FreeDAG()
+	if d1.ptr != nil {
+		t.Error("DAG not freed after last instance released it")
+	}
+}
+
+func TestRegistrySharedBetweenDirectories(t *testing.T) {
+	eth1, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth1.Full.Dir)
+	eth2, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth2.Full.Dir)
+	eth2.SetDAGSharing(true)
+	before := MemoryStats()
+
+	block := &testBlock{number: 6 * epochLength, difficulty: big.NewInt(10)}
+	for _, eth := range []*Ethash{eth1, eth2} {
+		if _, mix := eth.Search(block, nil); mix == nil {
+			t.Fatal("search failed")
+		}
+	}
+	d1, _ := eth1.Full.getDAG(block.number)
+	d2, _ := eth2.Full.getDAG(block.number)
+	d1.release()
+	d2.release()
+	if d1 != d2 {
+		t.Error("instances with different directories use different DAGs")
+	}
+	if got, want := MemoryStats().DAGs[6], before.DAGs[6]+uint64(dagSizeForTesting); got != want {
+		t.Errorf("DAG memory of epoch 6 is %d, want %d", got, want)
+	}
+	if dagFileComplete(eth2.Full.Dir, 6, true) {
+		t.Error("shared DAG written to the second directory")
+	}
+	eth1.FreeDAG()
+	eth2.FreeDAG()
+}
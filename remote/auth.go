@@ -0,0 +1,77 @@
+package remote
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// SetTokens restricts the server to miners presenting one of the
+// tokens, which map to the names of the workers using them. A farm
+// sharing a secret has a single token. Over HTTP the token is sent as a
+// bearer token in the Authorization header or as the URL path, e.g.
+// http://proxy:8545/TOKEN for miners that can't set headers. Stratum
+// miners log in with it as the password of eth_submitLogin, or as the
+// login if they send no password. Without tokens, the default, anyone
+// may get work and submit solutions.
+func (s *Server) SetTokens(tokens map[string]string) {
+	copied := make(map[string]string, len(tokens))
+	for token, worker := range tokens {
+		if token != "" {
+			copied[token] = worker
+		}
+	}
+	s.mu.Lock()
+	s.tokens = copied
+	s.mu.Unlock()
+}
+
+// authorize returns the worker name of token and whether it may use
+// the server.
+func (s *Server) authorize(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.tokens) == 0 {
+		return "", true
+	}
+	var (
+		worker string
+		ok     bool
+	)
+	// compare with all tokens in constant time, so response times
+	// don't reveal them.
+	for t, w := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			worker, ok = w, true
+		}
+	}
+	return worker, ok
+}
+
+// requestToken returns the token of an HTTP request, see SetTokens.
+func requestToken(r *http.Request) string {
+	if token := bearerToken(r.Header.Get("Authorization")); token != "" {
+		return token
+	}
+	return strings.Trim(r.URL.Path, "/")
+}
+
+// bearerToken returns the token of an Authorization header value, or
+// "" if it doesn't hold a bearer token.
+func bearerToken(auth string) string {
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// loginToken returns the token of the parameters of eth_submitLogin.
+func loginToken(params []string) string {
+	if len(params) > 1 && params[1] != "" {
+		return params[1]
+	}
+	if len(params) > 0 {
+		return params[0]
+	}
+	return ""
+}
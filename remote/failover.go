@@ -0,0 +1,196 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// DefaultRetryInterval is how long a FailoverUpstream waits before
+// checking whether a failed upstream is back.
+const DefaultRetryInterval = 30 * time.Second
+
+var errNoUpstream = errors.New("no upstream available")
+
+// FailoverUpstream is an Upstream getting work from the first of a list
+// of upstreams that is healthy. An upstream failing to provide work is
+// skipped until it answers a health check, which is done in the
+// background every retry interval, so the preferred upstream takes
+// over again once it is back. Solutions are submitted to the upstream
+// that provided their work.
+type FailoverUpstream struct {
+	upstreams []Upstream
+
+	mu          sync.Mutex
+	retry       time.Duration
+	failed      []time.Time // when each upstream last failed, zero if healthy
+	probing     []bool      // a health check is running
+	active      int         // upstream of the current work, -1 if none
+	issuedBy    map[common.Hash]int
+	issuedOrder []common.Hash // header hashes of issuedBy, most recent last
+
+	stats *upstreamStats
+}
+
+// NewFailoverUpstream returns an upstream failing over between
+// upstreams, the first one preferred.
+func NewFailoverUpstream(upstreams ...Upstream) *FailoverUpstream {
+	names := make([]string, len(upstreams))
+	for i, u := range upstreams {
+		names[i] = upstreamName(u, fmt.Sprintf("%d", i))
+	}
+	return &FailoverUpstream{
+		upstreams: upstreams,
+		retry:     DefaultRetryInterval,
+		failed:    make([]time.Time, len(upstreams)),
+		probing:   make([]bool, len(upstreams)),
+		active:    -1,
+		issuedBy:  make(map[common.Hash]int),
+		stats:     newUpstreamStats(names...),
+	}
+}
+
+// SetRetryInterval sets how often failed upstreams are checked.
+func (f *FailoverUpstream) SetRetryInterval(d time.Duration) {
+	f.mu.Lock()
+	f.retry = d
+	f.mu.Unlock()
+}
+
+// Active returns the index of the upstream that provided the current
+// work, -1 if none did yet.
+func (f *FailoverUpstream) Active() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// GetWork implements Upstream. If all upstreams failed, they are all
+// tried again right away.
+func (f *FailoverUpstream) GetWork() (Work, error) {
+	err := errNoUpstream
+	tried := make([]bool, len(f.upstreams))
+	for i := range f.upstreams {
+		if f.usable(i) {
+			tried[i] = true
+			var work Work
+			if work, err = f.tryWork(i); err == nil {
+				return work, nil
+			}
+		}
+	}
+	for i := range f.upstreams {
+		if !tried[i] && f.isFailed(i) {
+			var work Work
+			if work, err = f.tryWork(i); err == nil {
+				return work, nil
+			}
+		}
+	}
+	return Work{}, err
+}
+
+// usable reports whether upstream i is healthy. For failed upstreams
+// it starts a health check if one is due.
+func (f *FailoverUpstream) usable(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failed[i].IsZero() {
+		return true
+	}
+	if !f.probing[i] && time.Since(f.failed[i]) >= f.retry {
+		f.probing[i] = true
+		go f.probe(i)
+	}
+	return false
+}
+
+func (f *FailoverUpstream) isFailed(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.failed[i].IsZero() && !f.probing[i]
+}
+
+// probe checks whether failed upstream i provides work again.
+func (f *FailoverUpstream) probe(i int) {
+	_, err := f.upstreams[i].GetWork()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.probing[i] = false
+	if err != nil {
+		f.failed[i] = time.Now()
+		return
+	}
+	glog.V(logger.Info).Infof("Upstream %s is back", f.name(i))
+	f.failed[i] = time.Time{}
+}
+
+// tryWork gets work from upstream i, recording whether it failed.
+func (f *FailoverUpstream) tryWork(i int) (Work, error) {
+	work, err := f.upstreams[i].GetWork()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		if f.failed[i].IsZero() {
+			glog.V(logger.Warn).Infof("Upstream %s failed, failing over: %v", f.name(i), err)
+		}
+		f.failed[i] = time.Now()
+		return Work{}, err
+	}
+	f.failed[i] = time.Time{}
+	if f.active != i {
+		glog.V(logger.Info).Infof("Getting work from upstream %s", f.name(i))
+		f.active = i
+	}
+	if _, ok := f.issuedBy[work.HeaderHash]; !ok {
+		f.issuedOrder = append(f.issuedOrder, work.HeaderHash)
+		if len(f.issuedOrder) > staleWorkHistory+1 {
+			delete(f.issuedBy, f.issuedOrder[0])
+			f.issuedOrder = f.issuedOrder[1:]
+		}
+	}
+	f.issuedBy[work.HeaderHash] = i
+	return work, nil
+}
+
+// name returns a description of upstream i for logs.
+func (f *FailoverUpstream) name(i int) string {
+	return upstreamName(f.upstreams[i], fmt.Sprintf("%d", i))
+}
+
+// UpstreamStats returns the counts of the solutions submitted to each
+// upstream, in the order they were given to NewFailoverUpstream.
+func (f *FailoverUpstream) UpstreamStats() []UpstreamStats {
+	return f.stats.snapshot()
+}
+
+// SubmitWork implements Upstream.
+func (f *FailoverUpstream) SubmitWork(s Solution) (bool, error) {
+	f.mu.Lock()
+	i, ok := f.issuedBy[s.HeaderHash]
+	if !ok {
+		i = f.active
+	}
+	f.mu.Unlock()
+	if i < 0 {
+		return false, errNoUpstream
+	}
+	started := time.Now()
+	accepted, err := f.upstreams[i].SubmitWork(s)
+	f.stats.record(i, accepted, err, time.Since(started))
+	return accepted, err
+}
+
+// SubmitHashrate implements Upstream, reporting to the active upstream.
+func (f *FailoverUpstream) SubmitHashrate(rate uint64, id common.Hash) error {
+	i := f.Active()
+	if i < 0 {
+		return errNoUpstream
+	}
+	return f.upstreams[i].SubmitHashrate(rate, id)
+}
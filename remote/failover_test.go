@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// flakyUpstream is a testUpstream that fails while down is set.
+type flakyUpstream struct {
+	testUpstream
+	mu   sync.Mutex
+	down bool
+}
+
+func (u *flakyUpstream) setDown(down bool) {
+	u.mu.Lock()
+	u.down = down
+	u.mu.Unlock()
+}
+
+func (u *flakyUpstream) GetWork() (Work, error) {
+	u.mu.Lock()
+	down := u.down
+	u.mu.Unlock()
+	if down {
+		return Work{}, errors.New("down")
+	}
+	return u.testUpstream.GetWork()
+}
+
+func TestFailoverUpstream(t *testing.T) {
+	primary := &flakyUpstream{testUpstream: testUpstream{work: testWork}}
+	backupWork := testWork
+	backupWork.HeaderHash = common.HexToHash("0x04")
+	backup := &flakyUpstream{testUpstream: testUpstream{work: backupWork}}
+	f := NewFailoverUpstream(primary, backup)
+	f.SetRetryInterval(10 * time.Millisecond)
+
+	if work, err := f.GetWork(); err != nil || work != testWork || f.Active() != 0 {
+		t.Fatalf("got %v, %v from upstream %d, want the primary's work", work, err, f.Active())
+	}
+	primary.setDown(true)
+	if work, err := f.GetWork(); err != nil || work != backupWork || f.Active() != 1 {
+		t.Fatalf("got %v, %v from upstream %d, want the backup's work", work, err, f.Active())
+	}
+	// solutions go to the upstream that provided their work.
+	f.SubmitWork(Solution{Nonce: 1, HeaderHash: testWork.HeaderHash})
+	f.SubmitWork(Solution{Nonce: 2, HeaderHash: backupWork.HeaderHash})
+	if len(primary.solutions) != 1 || len(backup.solutions) != 1 {
+		t.Errorf("primary got %d solutions, backup %d, want 1 each", len(primary.solutions), len(backup.solutions))
+	}
+
+	// the primary takes over again once a health check passes.
+	primary.setDown(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for f.Active() != 0 && time.Now().Before(deadline) {
+		f.GetWork()
+		time.Sleep(time.Millisecond)
+	}
+	if f.Active() != 0 {
+		t.Error("primary didn't take over again")
+	}
+
+	primary.setDown(true)
+	backup.setDown(true)
+	if _, err := f.GetWork(); err == nil {
+		t.Error("no error with all upstreams down")
+	}
+	backup.setDown(false)
+	work, err := f.GetWork()
+	for deadline := time.Now().Add(5 * time.Second); err != nil && time.Now().Before(deadline); {
+		// a health check of the backup may have been running.
+		time.Sleep(time.Millisecond)
+		work, err = f.GetWork()
+	}
+	if err != nil || work != backupWork {
+		t.Errorf("got %v, %v, want the backup's work", work, err)
+	}
+}
+
+// unreachableUpstream is a testUpstream whose submissions fail.
+type unreachableUpstream struct {
+	testUpstream
+}
+
+func (u *unreachableUpstream) SubmitWork(s Solution) (bool, error) {
+	return false, errors.New("unreachable")
+}
+
+func TestFailoverUpstreamStats(t *testing.T) {
+	primary := &flakyUpstream{testUpstream: testUpstream{work: testWork}}
+	backupWork := testWork
+	backupWork.HeaderHash = common.HexToHash("0x04")
+	backup := &unreachableUpstream{testUpstream{work: backupWork}}
+	f := NewFailoverUpstream(primary, backup)
+	f.SetRetryInterval(time.Hour)
+	srv := NewServer(f)
+
+	srv.poll()
+	srv.Submit(Solution{Nonce: 1, HeaderHash: testWork.HeaderHash})
+	primary.setDown(true)
+	srv.poll()
+	srv.Submit(Solution{Nonce: 2, HeaderHash: backupWork.HeaderHash})
+	srv.Submit(Solution{Nonce: 3, HeaderHash: backupWork.HeaderHash})
+
+	stats := srv.UpstreamStats()
+	for i := range stats {
+		stats[i].Latency = 0
+	}
+	want := []UpstreamStats{
+		{Name: "0", Submitted: 1, Accepted: 1},
+		{Name: "1", Submitted: 2, Errors: 2},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
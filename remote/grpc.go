@@ -0,0 +1,156 @@
+//go:build grpc
+// +build grpc
+
+package remote
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/ethash/remote/grpcpb"
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// minHashrateInterval bounds how often Hashrate sends samples.
+const minHashrateInterval = 100 * time.Millisecond
+
+// GRPCServer serves the Ethash service of grpcpb: work and solutions
+// of a Server, seal verification by a VerifyService and the hash rate
+// of the local miner. Register it with grpcpb.RegisterEthashServer.
+// Any of the three may be nil, the methods using it then fail with
+// codes.Unimplemented.
+//
+// The tokens and limits of the Server apply to GetWork, SubmitWork and
+// Hashrate like to the other protocols: the token is sent as a bearer
+// token in the authorization metadata, see Server.SetTokens.
+type GRPCServer struct {
+	grpcpb.UnimplementedEthashServer
+
+	srv    *Server
+	verify *VerifyService
+	pow    *ethash.Full
+}
+
+// NewGRPCServer returns a gRPC server for srv, verify and pow.
+func NewGRPCServer(srv *Server, verify *VerifyService, pow *ethash.Full) *GRPCServer {
+	return &GRPCServer{srv: srv, verify: verify, pow: pow}
+}
+
+// admit checks the address and token of a call against the limits and
+// tokens of the server and returns the address.
+func (g *GRPCServer) admit(ctx context.Context) (string, error) {
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = remoteIP(p.Addr.String())
+	}
+	if !g.srv.allowed(ip) {
+		return "", status.Error(codes.PermissionDenied, "address not allowed")
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if auth := md.Get("authorization"); len(auth) > 0 {
+			token = bearerToken(auth[0])
+		}
+	}
+	if _, ok := g.srv.authorize(token); !ok {
+		return "", status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return ip, nil
+}
+
+func (g *GRPCServer) GetWork(ctx context.Context, req *grpcpb.GetWorkRequest) (*grpcpb.Work, error) {
+	if g.srv == nil {
+		return nil, status.Error(codes.Unimplemented, "no work served")
+	}
+	if _, err := g.admit(ctx); err != nil {
+		return nil, err
+	}
+	work, err := g.srv.Work()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &grpcpb.Work{
+		HeaderHash:  work.HeaderHash[:],
+		SeedHash:    work.SeedHash[:],
+		Target:      work.Target[:],
+		BlockNumber: work.BlockNumber,
+	}, nil
+}
+
+func (g *GRPCServer) SubmitWork(ctx context.Context, sol *grpcpb.Solution) (*grpcpb.SubmitWorkReply, error) {
+	if g.srv == nil {
+		return nil, status.Error(codes.Unimplemented, "no work served")
+	}
+	ip, err := g.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(sol.HeaderHash) != common.HashLength || len(sol.MixDigest) != common.HashLength {
+		return nil, status.Error(codes.InvalidArgument, "hashes must be 32 bytes")
+	}
+	if !g.srv.allowSubmit(ip) {
+		return &grpcpb.SubmitWorkReply{RejectReason: string(RejectRateLimited)}, nil
+	}
+	reason, err := g.srv.submit(Solution{
+		Nonce:      sol.Nonce,
+		HeaderHash: common.BytesToHash(sol.HeaderHash),
+		MixDigest:  common.BytesToHash(sol.MixDigest),
+	})
+	if err == errNoWork {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &grpcpb.SubmitWorkReply{Accepted: reason == "", RejectReason: string(reason)}, nil
+}
+
+func (g *GRPCServer) Verify(ctx context.Context, req *grpcpb.VerifyRequest) (*grpcpb.VerifyReply, error) {
+	if g.verify == nil {
+		return nil, status.Error(codes.Unimplemented, "verification not served")
+	}
+	if len(req.HeaderHash) != common.HashLength || len(req.MixDigest) != common.HashLength {
+		return nil, status.Error(codes.InvalidArgument, "hashes must be 32 bytes")
+	}
+	res := g.verify.verify(&sealBlock{
+		hashNoNonce: common.BytesToHash(req.HeaderHash),
+		number:      req.Number,
+		nonce:       req.Nonce,
+		mixDigest:   common.BytesToHash(req.MixDigest),
+		difficulty:  new(big.Int).SetBytes(req.Difficulty),
+	})
+	return &grpcpb.VerifyReply{Valid: res.Valid, Error: res.Error}, nil
+}
+
+func (g *GRPCServer) Hashrate(req *grpcpb.HashrateRequest, stream grpcpb.Ethash_HashrateServer) error {
+	if g.pow == nil {
+		return status.Error(codes.Unimplemented, "no local miner")
+	}
+	if g.srv != nil {
+		if _, err := g.admit(stream.Context()); err != nil {
+			return err
+		}
+	}
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval < minHashrateInterval {
+		interval = minHashrateInterval
+	}
+	samples, unsubscribe := g.pow.SubscribeHashrate(interval)
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case s := <-samples:
+			if err := stream.Send(&grpcpb.HashrateSample{TimeUnixNano: s.Time.UnixNano(), Rate: s.Rate}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+//go:build grpc
+// +build grpc
+
+package remote
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ethereum/ethash/remote/grpcpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCServer(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	up.work.BlockNumber = 30001
+	srv := NewServer(up)
+	srv.SetTokens(map[string]string{"secret": "rig1"})
+	srv.SetLimits(Limits{SubmitRate: 0.001, SubmitBurst: 1})
+	srv.poll()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer()
+	grpcpb.RegisterEthashServer(gs, NewGRPCServer(srv, nil, nil))
+	go gs.Serve(l)
+	defer gs.Stop()
+	conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := grpcpb.NewEthashClient(conn)
+
+	ctx := context.Background()
+	if _, err := client.GetWork(ctx, &grpcpb.GetWorkRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetWork without token: got %v, want Unauthenticated", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	work, err := client.GetWork(ctx, &grpcpb.GetWorkRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(work.HeaderHash) != string(testWork.HeaderHash[:]) || work.BlockNumber != 30001 {
+		t.Errorf("got work %x for block %d", work.HeaderHash, work.BlockNumber)
+	}
+
+	sol := &grpcpb.Solution{Nonce: 1, HeaderHash: testWork.HeaderHash[:], MixDigest: make([]byte, 32)}
+	if reply, err := client.SubmitWork(ctx, sol); err != nil || !reply.Accepted {
+		t.Errorf("solution: got %v, %v", reply, err)
+	}
+	if reply, err := client.SubmitWork(ctx, sol); err != nil || reply.RejectReason != string(RejectRateLimited) {
+		t.Errorf("solution above the rate limit: got %v, %v", reply, err)
+	}
+
+	_, n, _ := net.ParseCIDR("10.0.0.0/8")
+	srv.SetLimits(Limits{Allow: []*net.IPNet{n}})
+	if _, err := client.GetWork(ctx, &grpcpb.GetWorkRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("GetWork from address not allowed: got %v, want PermissionDenied", err)
+	}
+}
@@ -0,0 +1,587 @@
+//go:build grpc
+// +build grpc
+
+// The gRPC interface of the remote package, for mining farm tooling
+// written in other languages. The Go code in this directory is
+// generated with
+//
+//	go generate github.com/ethereum/ethash/remote/grpcpb
+//
+// which needs protoc with the protoc-gen-go and protoc-gen-go-grpc
+// plugins. The server is built into the remote package with the grpc
+// build tag. Calls carry the miner's token, if the server requires
+// one, as a bearer token in the authorization metadata.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v25.3.0
+// source: ethash.proto
+
+package grpcpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetWorkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkRequest) Reset() {
+	*x = GetWorkRequest{}
+	mi := &file_ethash_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkRequest) ProtoMessage() {}
+
+func (x *GetWorkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ethash_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkRequest.ProtoReflect.Descriptor instead.
+func (*GetWorkRequest) Descriptor() ([]byte, []int) {
+	return file_ethash_proto_rawDescGZIP(), []int{0}
+}
+
+// Work is a work package, all hashes are 32 bytes.
+type Work struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HeaderHash    []byte                 `protobuf:"bytes,1,opt,name=header_hash,json=headerHash,proto3" json:"header_hash,omitempty"` // hash of the header without nonce and mix digest
+	SeedHash      []byte                 `protobuf:"bytes,2,opt,name=seed_hash,json=seedHash,proto3" json:"seed_hash,omitempty"`
+	Target        []byte                 `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`                               // boundary a seal's result must not exceed
+	BlockNumber   uint64                 `protobuf:"varint,4,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"` // zero if the upstream doesn't send it
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Work) Reset() {
+	*x = Work{}
+	mi := &file_ethash_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Work) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Work) ProtoMessage() {}
+
+func (x *Work) ProtoReflect() protoreflect.Message {
+	mi := &file_ethash_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Work.ProtoReflect.Descriptor instead.
+func (*Work) Descriptor() ([]byte, []int) {
+	return file_ethash_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Work) GetHeaderHash() []byte {
+	if x != nil {
+		return x.HeaderHash
+	}
+	return nil
+}
+
+func (x *Work) GetSeedHash() []byte {
+	if x != nil {
+		return x.SeedHash
+	}
+	return nil
+}
+
+func (x *Work) GetTarget() []byte {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+func (x *Work) GetBlockNumber() uint64 {
+	if x != nil {
+		return x.BlockNumber
+	}
+	return 0
+}
+
+type Solution struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nonce         uint64                 `protobuf:"varint,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	HeaderHash    []byte                 `protobuf:"bytes,2,opt,name=header_hash,json=headerHash,proto3" json:"header_hash,omitempty"`
+	MixDigest     []byte                 `protobuf:"bytes,3,opt,name=mix_digest,json=mixDigest,proto3" json:"mix_digest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Solution) Reset() {
+	*x = Solution{}
+	mi := &file_ethash_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Solution) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Solution) ProtoMessage() {}
+
+func (x *Solution) ProtoReflect() protoreflect.Message {
+	mi := &file_ethash_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Solution.ProtoReflect.Descriptor instead.
+func (*Solution) Descriptor() ([]byte, []int) {
+	return file_ethash_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Solution) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *Solution) GetHeaderHash() []byte {
+	if x != nil {
+		return x.HeaderHash
+	}
+	return nil
+}
+
+func (x *Solution) GetMixDigest() []byte {
+	if x != nil {
+		return x.MixDigest
+	}
+	return nil
+}
+
+type SubmitWorkReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      bool                   `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	RejectReason  string                 `protobuf:"bytes,2,opt,name=reject_reason,json=rejectReason,proto3" json:"reject_reason,omitempty"` // why the solution was rejected, see remote.RejectReason
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitWorkReply) Reset() {
+	*x = SubmitWorkReply{}
+	mi := &file_ethash_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitWorkReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitWorkReply) ProtoMessage() {}
+
+func (x *SubmitWorkReply) ProtoReflect() protoreflect.Message {
+	mi := &file_ethash_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitWorkReply.ProtoReflect.Descriptor instead.
+func (*SubmitWorkReply) Descriptor() ([]byte, []int) {
+	return file_ethash_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SubmitWorkReply) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *SubmitWorkReply) GetRejectReason() string {
+	if x != nil {
+		return x.RejectReason
+	}
+	return ""
+}
+
+type VerifyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HeaderHash    []byte                 `protobuf:"bytes,1,opt,name=header_hash,json=headerHash,proto3" json:"header_hash,omitempty"` // hash of the header without nonce and mix digest
+	Number        uint64                 `protobuf:"varint,2,opt,name=number,proto3" json:"number,omitempty"`
+	Nonce         uint64                 `protobuf:"varint,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	MixDigest     []byte                 `protobuf:"bytes,4,opt,name=mix_digest,json=mixDigest,proto3" json:"mix_digest,omitempty"`
+	Difficulty    []byte                 `protobuf:"bytes,5,opt,name=difficulty,proto3" json:"difficulty,omitempty"` // big endian
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyRequest) Reset() {
+	*x = VerifyRequest{}
+	mi := &file_ethash_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyRequest) ProtoMessage() {}
+
+func (x *VerifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ethash_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyRequest.ProtoReflect.Descriptor instead.
+func (*VerifyRequest) Descriptor() ([]byte, []int) {
+	return file_ethash_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *VerifyRequest) GetHeaderHash() []byte {
+	if x != nil {
+		return x.HeaderHash
+	}
+	return nil
+}
+
+func (x *VerifyRequest) GetNumber() uint64 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *VerifyRequest) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *VerifyRequest) GetMixDigest() []byte {
+	if x != nil {
+		return x.MixDigest
+	}
+	return nil
+}
+
+func (x *VerifyRequest) GetDifficulty() []byte {
+	if x != nil {
+		return x.Difficulty
+	}
+	return nil
+}
+
+type VerifyReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Valid         bool                   `protobuf:"varint,1,opt,name=valid,proto3" json:"valid,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"` // why the seal is invalid
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyReply) Reset() {
+	*x = VerifyReply{}
+	mi := &file_ethash_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyReply) ProtoMessage() {}
+
+func (x *VerifyReply) ProtoReflect() protoreflect.Message {
+	mi := &file_ethash_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyReply.ProtoReflect.Descriptor instead.
+func (*VerifyReply) Descriptor() ([]byte, []int) {
+	return file_ethash_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *VerifyReply) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+func (x *VerifyReply) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type HashrateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IntervalMs    int64                  `protobuf:"varint,1,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"` // time between two samples
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HashrateRequest) Reset() {
+	*x = HashrateRequest{}
+	mi := &file_ethash_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HashrateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HashrateRequest) ProtoMessage() {}
+
+func (x *HashrateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ethash_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HashrateRequest.ProtoReflect.Descriptor instead.
+func (*HashrateRequest) Descriptor() ([]byte, []int) {
+	return file_ethash_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HashrateRequest) GetIntervalMs() int64 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+type HashrateSample struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimeUnixNano  int64                  `protobuf:"varint,1,opt,name=time_unix_nano,json=timeUnixNano,proto3" json:"time_unix_nano,omitempty"`
+	Rate          float64                `protobuf:"fixed64,2,opt,name=rate,proto3" json:"rate,omitempty"` // hashes per second
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HashrateSample) Reset() {
+	*x = HashrateSample{}
+	mi := &file_ethash_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HashrateSample) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HashrateSample) ProtoMessage() {}
+
+func (x *HashrateSample) ProtoReflect() protoreflect.Message {
+	mi := &file_ethash_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HashrateSample.ProtoReflect.Descriptor instead.
+func (*HashrateSample) Descriptor() ([]byte, []int) {
+	return file_ethash_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HashrateSample) GetTimeUnixNano() int64 {
+	if x != nil {
+		return x.TimeUnixNano
+	}
+	return 0
+}
+
+func (x *HashrateSample) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+var File_ethash_proto protoreflect.FileDescriptor
+
+const file_ethash_proto_rawDesc = "" +
+	"\n" +
+	"\fethash.proto\x12\rethash.remote\"\x10\n" +
+	"\x0eGetWorkRequest\"\x7f\n" +
+	"\x04Work\x12\x1f\n" +
+	"\vheader_hash\x18\x01 \x01(\fR\n" +
+	"headerHash\x12\x1b\n" +
+	"\tseed_hash\x18\x02 \x01(\fR\bseedHash\x12\x16\n" +
+	"\x06target\x18\x03 \x01(\fR\x06target\x12!\n" +
+	"\fblock_number\x18\x04 \x01(\x04R\vblockNumber\"`\n" +
+	"\bSolution\x12\x14\n" +
+	"\x05nonce\x18\x01 \x01(\x04R\x05nonce\x12\x1f\n" +
+	"\vheader_hash\x18\x02 \x01(\fR\n" +
+	"headerHash\x12\x1d\n" +
+	"\n" +
+	"mix_digest\x18\x03 \x01(\fR\tmixDigest\"R\n" +
+	"\x0fSubmitWorkReply\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\bR\baccepted\x12#\n" +
+	"\rreject_reason\x18\x02 \x01(\tR\frejectReason\"\x9d\x01\n" +
+	"\rVerifyRequest\x12\x1f\n" +
+	"\vheader_hash\x18\x01 \x01(\fR\n" +
+	"headerHash\x12\x16\n" +
+	"\x06number\x18\x02 \x01(\x04R\x06number\x12\x14\n" +
+	"\x05nonce\x18\x03 \x01(\x04R\x05nonce\x12\x1d\n" +
+	"\n" +
+	"mix_digest\x18\x04 \x01(\fR\tmixDigest\x12\x1e\n" +
+	"\n" +
+	"difficulty\x18\x05 \x01(\fR\n" +
+	"difficulty\"9\n" +
+	"\vVerifyReply\x12\x14\n" +
+	"\x05valid\x18\x01 \x01(\bR\x05valid\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"2\n" +
+	"\x0fHashrateRequest\x12\x1f\n" +
+	"\vinterval_ms\x18\x01 \x01(\x03R\n" +
+	"intervalMs\"J\n" +
+	"\x0eHashrateSample\x12$\n" +
+	"\x0etime_unix_nano\x18\x01 \x01(\x03R\ftimeUnixNano\x12\x12\n" +
+	"\x04rate\x18\x02 \x01(\x01R\x04rate2\x9f\x02\n" +
+	"\x06Ethash\x12=\n" +
+	"\aGetWork\x12\x1d.ethash.remote.GetWorkRequest\x1a\x13.ethash.remote.Work\x12E\n" +
+	"\n" +
+	"SubmitWork\x12\x17.ethash.remote.Solution\x1a\x1e.ethash.remote.SubmitWorkReply\x12B\n" +
+	"\x06Verify\x12\x1c.ethash.remote.VerifyRequest\x1a\x1a.ethash.remote.VerifyReply\x12K\n" +
+	"\bHashrate\x12\x1e.ethash.remote.HashrateRequest\x1a\x1d.ethash.remote.HashrateSample0\x01B*Z(github.com/ethereum/ethash/remote/grpcpbb\x06proto3"
+
+var (
+	file_ethash_proto_rawDescOnce sync.Once
+	file_ethash_proto_rawDescData []byte
+)
+
+func file_ethash_proto_rawDescGZIP() []byte {
+	file_ethash_proto_rawDescOnce.Do(func() {
+		file_ethash_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_ethash_proto_rawDesc), len(file_ethash_proto_rawDesc)))
+	})
+	return file_ethash_proto_rawDescData
+}
+
+var file_ethash_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_ethash_proto_goTypes = []any{
+	(*GetWorkRequest)(nil),  // 0: ethash.remote.GetWorkRequest
+	(*Work)(nil),            // 1: ethash.remote.Work
+	(*Solution)(nil),        // 2: ethash.remote.Solution
+	(*SubmitWorkReply)(nil), // 3: ethash.remote.SubmitWorkReply
+	(*VerifyRequest)(nil),   // 4: ethash.remote.VerifyRequest
+	(*VerifyReply)(nil),     // 5: ethash.remote.VerifyReply
+	(*HashrateRequest)(nil), // 6: ethash.remote.HashrateRequest
+	(*HashrateSample)(nil),  // 7: ethash.remote.HashrateSample
+}
+var file_ethash_proto_depIdxs = []int32{
+	0, // 0: ethash.remote.Ethash.GetWork:input_type -> ethash.remote.GetWorkRequest
+	2, // 1: ethash.remote.Ethash.SubmitWork:input_type -> ethash.remote.Solution
+	4, // 2: ethash.remote.Ethash.Verify:input_type -> ethash.remote.VerifyRequest
+	6, // 3: ethash.remote.Ethash.Hashrate:input_type -> ethash.remote.HashrateRequest
+	1, // 4: ethash.remote.Ethash.GetWork:output_type -> ethash.remote.Work
+	3, // 5: ethash.remote.Ethash.SubmitWork:output_type -> ethash.remote.SubmitWorkReply
+	5, // 6: ethash.remote.Ethash.Verify:output_type -> ethash.remote.VerifyReply
+	7, // 7: ethash.remote.Ethash.Hashrate:output_type -> ethash.remote.HashrateSample
+	4, // [4:8] is the sub-list for method output_type
+	0, // [0:4] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_ethash_proto_init() }
+func file_ethash_proto_init() {
+	if File_ethash_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_ethash_proto_rawDesc), len(file_ethash_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_ethash_proto_goTypes,
+		DependencyIndexes: file_ethash_proto_depIdxs,
+		MessageInfos:      file_ethash_proto_msgTypes,
+	}.Build()
+	File_ethash_proto = out.File
+	file_ethash_proto_goTypes = nil
+	file_ethash_proto_depIdxs = nil
+}
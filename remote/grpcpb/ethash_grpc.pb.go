@@ -0,0 +1,261 @@
+//go:build grpc
+// +build grpc
+
+// The gRPC interface of the remote package, for mining farm tooling
+// written in other languages. The Go code in this directory is
+// generated with
+//
+//	go generate github.com/ethereum/ethash/remote/grpcpb
+//
+// which needs protoc with the protoc-gen-go and protoc-gen-go-grpc
+// plugins. The server is built into the remote package with the grpc
+// build tag. Calls carry the miner's token, if the server requires
+// one, as a bearer token in the authorization metadata.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v25.3.0
+// source: ethash.proto
+
+package grpcpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Ethash_GetWork_FullMethodName    = "/ethash.remote.Ethash/GetWork"
+	Ethash_SubmitWork_FullMethodName = "/ethash.remote.Ethash/SubmitWork"
+	Ethash_Verify_FullMethodName     = "/ethash.remote.Ethash/Verify"
+	Ethash_Hashrate_FullMethodName   = "/ethash.remote.Ethash/Hashrate"
+)
+
+// EthashClient is the client API for Ethash service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EthashClient interface {
+	// GetWork returns the current work package.
+	GetWork(ctx context.Context, in *GetWorkRequest, opts ...grpc.CallOption) (*Work, error)
+	// SubmitWork checks a solution and forwards it upstream.
+	SubmitWork(ctx context.Context, in *Solution, opts ...grpc.CallOption) (*SubmitWorkReply, error)
+	// Verify checks the seal of a block.
+	Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyReply, error)
+	// Hashrate streams the hash rate of the local miner.
+	Hashrate(ctx context.Context, in *HashrateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HashrateSample], error)
+}
+
+type ethashClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEthashClient(cc grpc.ClientConnInterface) EthashClient {
+	return &ethashClient{cc}
+}
+
+func (c *ethashClient) GetWork(ctx context.Context, in *GetWorkRequest, opts ...grpc.CallOption) (*Work, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Work)
+	err := c.cc.Invoke(ctx, Ethash_GetWork_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethashClient) SubmitWork(ctx context.Context, in *Solution, opts ...grpc.CallOption) (*SubmitWorkReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitWorkReply)
+	err := c.cc.Invoke(ctx, Ethash_SubmitWork_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethashClient) Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyReply)
+	err := c.cc.Invoke(ctx, Ethash_Verify_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethashClient) Hashrate(ctx context.Context, in *HashrateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HashrateSample], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Ethash_ServiceDesc.Streams[0], Ethash_Hashrate_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[HashrateRequest, HashrateSample]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Ethash_HashrateClient = grpc.ServerStreamingClient[HashrateSample]
+
+// EthashServer is the server API for Ethash service.
+// All implementations must embed UnimplementedEthashServer
+// for forward compatibility.
+type EthashServer interface {
+	// GetWork returns the current work package.
+	GetWork(context.Context, *GetWorkRequest) (*Work, error)
+	// SubmitWork checks a solution and forwards it upstream.
+	SubmitWork(context.Context, *Solution) (*SubmitWorkReply, error)
+	// Verify checks the seal of a block.
+	Verify(context.Context, *VerifyRequest) (*VerifyReply, error)
+	// Hashrate streams the hash rate of the local miner.
+	Hashrate(*HashrateRequest, grpc.ServerStreamingServer[HashrateSample]) error
+	mustEmbedUnimplementedEthashServer()
+}
+
+// UnimplementedEthashServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEthashServer struct{}
+
+func (UnimplementedEthashServer) GetWork(context.Context, *GetWorkRequest) (*Work, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWork not implemented")
+}
+func (UnimplementedEthashServer) SubmitWork(context.Context, *Solution) (*SubmitWorkReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitWork not implemented")
+}
+func (UnimplementedEthashServer) Verify(context.Context, *VerifyRequest) (*VerifyReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Verify not implemented")
+}
+func (UnimplementedEthashServer) Hashrate(*HashrateRequest, grpc.ServerStreamingServer[HashrateSample]) error {
+	return status.Errorf(codes.Unimplemented, "method Hashrate not implemented")
+}
+func (UnimplementedEthashServer) mustEmbedUnimplementedEthashServer() {}
+func (UnimplementedEthashServer) testEmbeddedByValue()                {}
+
+// UnsafeEthashServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EthashServer will
+// result in compilation errors.
+type UnsafeEthashServer interface {
+	mustEmbedUnimplementedEthashServer()
+}
+
+func RegisterEthashServer(s grpc.ServiceRegistrar, srv EthashServer) {
+	// If the following call pancis, it indicates UnimplementedEthashServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Ethash_ServiceDesc, srv)
+}
+
+func _Ethash_GetWork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWorkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthashServer).GetWork(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ethash_GetWork_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthashServer).GetWork(ctx, req.(*GetWorkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ethash_SubmitWork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Solution)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthashServer).SubmitWork(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ethash_SubmitWork_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthashServer).SubmitWork(ctx, req.(*Solution))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ethash_Verify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthashServer).Verify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ethash_Verify_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthashServer).Verify(ctx, req.(*VerifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ethash_Hashrate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(HashrateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EthashServer).Hashrate(m, &grpc.GenericServerStream[HashrateRequest, HashrateSample]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Ethash_HashrateServer = grpc.ServerStreamingServer[HashrateSample]
+
+// Ethash_ServiceDesc is the grpc.ServiceDesc for Ethash service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Ethash_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ethash.remote.Ethash",
+	HandlerType: (*EthashServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetWork",
+			Handler:    _Ethash_GetWork_Handler,
+		},
+		{
+			MethodName: "SubmitWork",
+			Handler:    _Ethash_SubmitWork_Handler,
+		},
+		{
+			MethodName: "Verify",
+			Handler:    _Ethash_Verify_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Hashrate",
+			Handler:       _Ethash_Hashrate_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ethash.proto",
+}
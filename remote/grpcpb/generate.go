@@ -0,0 +1,8 @@
+// Package grpcpb holds the code generated from ethash.proto. The
+// generated code is only built with the grpc build tag, like the server
+// in the remote package, and needs google.golang.org/protobuf v1.36.11
+// or newer.
+package grpcpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ethash.proto
+//go:generate go run tag.go
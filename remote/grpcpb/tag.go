@@ -0,0 +1,35 @@
+//go:build ignore
+// +build ignore
+
+// tag puts the generated files of the current directory behind the grpc
+// build tag, so that the package builds without the protobuf and gRPC
+// modules unless the tag is set.
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+)
+
+var constraint = []byte("//go:build grpc\n// +build grpc\n\n")
+
+func main() {
+	names, err := filepath.Glob("*.pb.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range names {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if bytes.HasPrefix(src, constraint) {
+			continue
+		}
+		if err := ioutil.WriteFile(name, append(constraint, src...), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package remote
+
+import (
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// maxSubmitBuckets bounds the number of addresses whose submission
+// rate is tracked before idle ones are dropped.
+const maxSubmitBuckets = 4096
+
+// Limits protects a Server from abusive or misconfigured miners. The
+// zero value imposes no limits.
+type Limits struct {
+	// Allow lists the networks miners may connect from. Any address
+	// may connect if it is empty.
+	Allow []*net.IPNet
+	// ConnsPerIP caps the open stratum and websocket connections of
+	// one address, zero means unlimited.
+	ConnsPerIP int
+	// SubmitRate is the number of solutions an address may submit per
+	// second on average, zero means unlimited. SubmitBurst is how many
+	// it may submit at once, at least one.
+	SubmitRate  float64
+	SubmitBurst int
+}
+
+// submitBucket is the token bucket limiting the submissions of an
+// address.
+type submitBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// SetLimits sets the limits for miners connecting to the server. They
+// apply to getWork requests, stratum and websocket connections. Open
+// connections from addresses no longer allowed are kept.
+func (s *Server) SetLimits(l Limits) {
+	l.Allow = append([]*net.IPNet(nil), l.Allow...)
+	s.mu.Lock()
+	s.limits = l
+	s.buckets = make(map[string]*submitBucket)
+	s.mu.Unlock()
+}
+
+// remoteIP returns the IP address of a remote address as a string.
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// allowed reports whether miners may connect from ip.
+func (s *Server) allowed(ip string) bool {
+	s.mu.Lock()
+	allow := s.limits.Allow
+	s.mu.Unlock()
+	if len(allow) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	for _, n := range allow {
+		if parsed != nil && n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// openConn counts a connection from ip, unless that exceeds the cap,
+// and reports whether it may stay open. Counted connections must be
+// released with closeConn.
+func (s *Server) openConn(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limits.ConnsPerIP > 0 && s.connsPerIP[ip] >= s.limits.ConnsPerIP {
+		return false
+	}
+	s.connsPerIP[ip]++
+	return true
+}
+
+func (s *Server) closeConn(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connsPerIP[ip]--; s.connsPerIP[ip] <= 0 {
+		delete(s.connsPerIP, ip)
+	}
+}
+
+// admit checks a new stratum or websocket connection from ip against
+// the allowlist and the connection cap. If it returns true, the
+// connection must be released with closeConn.
+func (s *Server) admit(ip string) bool {
+	if !s.allowed(ip) {
+		glog.V(logger.Debug).Infof("Connection from %s not allowed", ip)
+		return false
+	}
+	if !s.openConn(ip) {
+		glog.V(logger.Debug).Infof("Too many connections from %s", ip)
+		return false
+	}
+	return true
+}
+
+// allowSubmit reports whether ip may submit another solution now.
+func (s *Server) allowSubmit(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limits.SubmitRate <= 0 {
+		return true
+	}
+	burst := float64(s.limits.SubmitBurst)
+	if burst < 1 {
+		burst = 1
+	}
+	now := time.Now()
+	b := s.buckets[ip]
+	if b == nil {
+		if len(s.buckets) >= maxSubmitBuckets {
+			s.pruneBuckets(now, burst)
+		}
+		b = &submitBucket{tokens: burst, last: now}
+		s.buckets[ip] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * s.limits.SubmitRate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		s.stats.RateLimited++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// pruneBuckets drops the buckets of addresses that have been idle long
+// enough for their bucket to be full again.
+func (s *Server) pruneBuckets(now time.Time, burst float64) {
+	for ip, b := range s.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*s.limits.SubmitRate >= burst {
+			delete(s.buckets, ip)
+		}
+	}
+}
+
+// limitSubmit returns the response to a submission from ip exceeding
+// the rate limit, or nil if req may be handled.
+func (s *Server) limitSubmit(req *rpcRequest, ip string) *rpcResponse {
+	if req.Method != "eth_submitWork" || s.allowSubmit(ip) {
+		return nil
+	}
+	glog.V(logger.Debug).Infof("Solution from %s rate limited", ip)
+	return rejectResponse(req.ID, &RejectError{RejectRateLimited})
+}
@@ -0,0 +1,163 @@
+package remote
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// workBlock presents a work package as a block to search a nonce for.
+// Its number is unknown, the epoch is given by the seed hash.
+type workBlock struct {
+	work Work
+}
+
+func (b *workBlock) Difficulty() *big.Int     { return b.work.Difficulty() }
+func (b *workBlock) HashNoNonce() common.Hash { return b.work.HeaderHash }
+func (b *workBlock) Nonce() uint64            { return 0 }
+func (b *workBlock) MixDigest() common.Hash   { return common.Hash{} }
+func (b *workBlock) NumberU64() uint64        { return 0 }
+
+// Miner mines the work of a Server on local threads and submits the
+// solutions it finds through the server.
+type Miner struct {
+	srv *Server
+	pow *ethash.Full
+
+	mu     sync.Mutex
+	quits  []chan struct{} // per running thread, closed to stop it
+	solved []common.Hash   // recent work packages a solution was submitted for
+	stats  MinerStats
+	wg     sync.WaitGroup
+}
+
+// MinerStats counts the solutions found by a Miner.
+type MinerStats struct {
+	Found     uint64 // submitted to the server
+	Redundant uint64 // found for work another thread had already solved
+}
+
+// NewMiner returns a miner for the work of srv. It doesn't mine until
+// SetThreads is called.
+func NewMiner(srv *Server, pow *ethash.Full) *Miner {
+	return &Miner{srv: srv, pow: pow}
+}
+
+// Threads returns the number of mining threads.
+func (m *Miner) Threads() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.quits)
+}
+
+// SetThreads sets the number of mining threads. Threads are started or
+// stopped as needed, the remaining ones continue their current search.
+// Zero stops mining.
+func (m *Miner) SetThreads(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(m.quits) > n {
+		close(m.quits[len(m.quits)-1])
+		m.quits = m.quits[:len(m.quits)-1]
+	}
+	for len(m.quits) < n {
+		quit := make(chan struct{})
+		m.quits = append(m.quits, quit)
+		m.wg.Add(1)
+		go m.mine(quit)
+	}
+}
+
+// Stats returns the counts of solutions found so far.
+func (m *Miner) Stats() MinerStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// claim reports whether sol is the first solution found for its work.
+// Only the first one is submitted, solutions found concurrently by
+// other threads are counted as redundant.
+func (m *Miner) claim(sol Solution) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.solved {
+		if h == sol.HeaderHash {
+			m.stats.Redundant++
+			return false
+		}
+	}
+	m.solved = append(m.solved, sol.HeaderHash)
+	if len(m.solved) > staleWorkHistory+1 {
+		m.solved = m.solved[1:]
+	}
+	m.stats.Found++
+	return true
+}
+
+// Stop stops all mining threads and waits for them to exit.
+func (m *Miner) Stop() {
+	m.SetThreads(0)
+	m.wg.Wait()
+}
+
+// mine searches nonces for the server's current work until quit is
+// closed, restarting whenever the work changes.
+func (m *Miner) mine(quit <-chan struct{}) {
+	defer m.wg.Done()
+	updates := m.srv.subscribe()
+	defer m.srv.unsubscribe(updates)
+
+	var work Work
+	select {
+	case work = <-updates:
+	case <-quit:
+		return
+	}
+	for {
+		// abort is closed when the work changes or the thread is to
+		// exit. The new work, if any, is sent on next first.
+		abort := make(chan struct{})
+		next := make(chan Work, 1)
+		go func() {
+			select {
+			case w := <-updates:
+				next <- w
+			case <-quit:
+			}
+			close(abort)
+		}()
+
+		if sol, ok := m.search(work, abort); ok && m.claim(sol) {
+			reason, err := m.srv.submit(sol)
+			switch {
+			case err != nil:
+				glog.V(logger.Warn).Infof("Can't submit solution for %x: %v", sol.HeaderHash, err)
+			case reason == "":
+				glog.V(logger.Info).Infof("Solution %x for %x accepted", sol.Nonce, sol.HeaderHash)
+			default:
+				glog.V(logger.Info).Infof("Solution %x for %x rejected: %s", sol.Nonce, sol.HeaderHash, rejectMessages[reason])
+			}
+		}
+		<-abort
+		select {
+		case work = <-next:
+		default:
+			return
+		}
+	}
+}
+
+// search looks for a solution of work until one is found or abort is
+// closed.
+func (m *Miner) search(work Work, abort <-chan struct{}) (Solution, bool) {
+	nonce, mixDigest := m.pow.SearchWithSeed(work.SeedHash, &workBlock{work}, abort)
+	if mixDigest == nil {
+		return Solution{}, false
+	}
+	return Solution{Nonce: nonce, HeaderHash: work.HeaderHash, MixDigest: common.BytesToHash(mixDigest)}, true
+}
@@ -0,0 +1,81 @@
+package remote
+
+import (
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMiner(t *testing.T) {
+	eth, err := ethash.NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+	eth.Turbo(true)
+
+	work := Work{
+		HeaderHash: testWork.HeaderHash,
+		Target:     common.BigToHash(new(big.Int).Div(maxUint256, big.NewInt(10))),
+	}
+	up := &testUpstream{work: work, rates: make(map[common.Hash]uint64)}
+	srv := NewServer(up)
+	srv.poll()
+	m := NewMiner(srv, eth.Full)
+	m.SetThreads(2)
+	defer m.Stop()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		up.mu.Lock()
+		n := len(up.solutions)
+		up.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no solution submitted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Stop()
+
+	// both threads likely solved the work, only one may submit.
+	if len(up.solutions) != 1 {
+		t.Errorf("submitted %d solutions, want 1", len(up.solutions))
+	}
+	if stats := m.Stats(); stats.Found != 1 {
+		t.Errorf("got stats %+v, want one found solution", stats)
+	}
+	sol := up.solutions[0]
+	ds, err := eth.Full.Dataset(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Release()
+	if mix, _ := ds.Hash(sol.HeaderHash, sol.Nonce); sol.HeaderHash != work.HeaderHash || mix != sol.MixDigest {
+		t.Errorf("submitted invalid solution %+v", sol)
+	}
+}
+
+func TestMinerClaim(t *testing.T) {
+	m := NewMiner(nil, nil)
+	sol := Solution{Nonce: 1, HeaderHash: testWork.HeaderHash}
+	if !m.claim(sol) {
+		t.Fatal("first solution not claimed")
+	}
+	sol.Nonce = 2
+	if m.claim(sol) {
+		t.Error("second solution for the same work claimed")
+	}
+	if !m.claim(Solution{HeaderHash: common.HexToHash("0x01")}) {
+		t.Error("solution for other work not claimed")
+	}
+	if want := (MinerStats{Found: 2, Redundant: 1}); m.Stats() != want {
+		t.Errorf("got stats %+v, want %+v", m.Stats(), want)
+	}
+}
@@ -0,0 +1,628 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// DefaultPollInterval is how often a Server asks its upstream for work.
+const DefaultPollInterval = 500 * time.Millisecond
+
+// DefaultLongPollTimeout is how long an eth_getWork request naming the
+// work a miner already has waits for new work if it gives no timeout.
+// maxLongPollTimeout bounds the timeouts miners may ask for.
+const (
+	DefaultLongPollTimeout = 30 * time.Second
+	maxLongPollTimeout     = 2 * time.Minute
+)
+
+// staleWorkHistory is the number of replaced work packages for which
+// solutions are recognized as stale rather than invalid. Solutions
+// found just before a new head arrived can then still be checked and,
+// with SetSubmitStale, propagated as uncles.
+const staleWorkHistory = 8
+
+// maxNoncesPerWork bounds the number of nonces remembered per work
+// package to detect duplicate submissions.
+const maxNoncesPerWork = 1 << 16
+
+// maxRPCRequestSize bounds the body of a getWork request, like
+// maxStratumLine bounds stratum requests.
+const maxRPCRequestSize = 16 * 1024
+
+var (
+	errNoWork        = errors.New("no work available yet")
+	errInvalidParams = errors.New("invalid parameters")
+)
+
+type rpcRequest struct {
+	ID      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc,omitempty"`
+	Method  string          `json:"method"`
+	Params  []string        `json:"params"`
+}
+
+type rpcResponse struct {
+	ID      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Server relays work between an upstream and local miners.
+type Server struct {
+	mu           sync.Mutex
+	upstream     Upstream
+	submits      *upstreamStats // submissions to upstream, unless it counts them itself
+	pollInterval time.Duration
+	work         Work
+	hasWork      bool
+	stale        map[common.Hash]Work // replaced work packages by header hash
+	staleOrder   []common.Hash        // header hashes of stale, most recent last
+	submitStale  bool
+	nonces       map[common.Hash]map[uint64]struct{} // submitted nonces by header hash
+	stats        ShareStats
+	light        *ethash.Light     // checks mix digests if set
+	tokens       map[string]string // worker names by token, see SetTokens
+	limits       Limits
+	connsPerIP   map[string]int           // open stratum and websocket connections
+	buckets      map[string]*submitBucket // submission rate by address
+	subs         map[chan Work]struct{}
+	conns        map[*stratumConn]struct{} // open stratum connections
+}
+
+// NewServer returns a server relaying the work of upstream. Run must
+// be called for the server to pick up work.
+func NewServer(upstream Upstream) *Server {
+	return &Server{
+		upstream:     upstream,
+		submits:      newUpstreamStats(upstreamName(upstream, "upstream")),
+		pollInterval: DefaultPollInterval,
+		stale:        make(map[common.Hash]Work),
+		subs:         make(map[chan Work]struct{}),
+		conns:        make(map[*stratumConn]struct{}),
+		nonces:       make(map[common.Hash]map[uint64]struct{}),
+		connsPerIP:   make(map[string]int),
+		buckets:      make(map[string]*submitBucket),
+	}
+}
+
+// SetUpstream replaces the upstream. The current work stays valid
+// until the new upstream provides different work. The counts returned
+// by UpstreamStats start over.
+func (s *Server) SetUpstream(upstream Upstream) {
+	s.mu.Lock()
+	s.upstream = upstream
+	s.submits = newUpstreamStats(upstreamName(upstream, "upstream"))
+	s.mu.Unlock()
+}
+
+func (s *Server) getUpstream() Upstream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.upstream
+}
+
+// SetPollInterval sets how often the upstream is asked for new work.
+func (s *Server) SetPollInterval(d time.Duration) {
+	s.mu.Lock()
+	s.pollInterval = d
+	s.mu.Unlock()
+}
+
+// Run polls the upstream for work until stop is closed. Miners
+// subscribed over stratum are notified when the work changes.
+func (s *Server) Run(stop <-chan struct{}) {
+	for {
+		s.poll()
+		s.mu.Lock()
+		interval := s.pollInterval
+		s.mu.Unlock()
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *Server) poll() {
+	work, err := s.getUpstream().GetWork()
+	if err != nil {
+		glog.V(logger.Debug).Infof("Can't get work from upstream: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hasWork && s.work == work {
+		return
+	}
+	glog.V(logger.Debug).Infof("New work %x", work.HeaderHash)
+	if _, ok := s.stale[work.HeaderHash]; ok {
+		// the upstream went back to an earlier head after a reorg, the
+		// package is current again and keeps its submitted nonces.
+		glog.V(logger.Debug).Infof("Work %x reinstated", work.HeaderHash)
+		s.removeStale(work.HeaderHash)
+	}
+	if s.hasWork {
+		s.stale[s.work.HeaderHash] = s.work
+		s.staleOrder = append(s.staleOrder, s.work.HeaderHash)
+		if len(s.staleOrder) > staleWorkHistory {
+			oldest := s.staleOrder[0]
+			s.removeStale(oldest)
+			delete(s.nonces, oldest)
+		}
+	}
+	s.work, s.hasWork = work, true
+	for ch := range s.subs {
+		// drop the previous package if the subscriber hasn't taken it.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- work
+	}
+}
+
+// removeStale removes a package from the replaced ones.
+func (s *Server) removeStale(headerHash common.Hash) {
+	delete(s.stale, headerHash)
+	for i, h := range s.staleOrder {
+		if h == headerHash {
+			s.staleOrder = append(s.staleOrder[:i], s.staleOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// Work returns the current work package.
+func (s *Server) Work() (Work, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.hasWork {
+		return Work{}, errNoWork
+	}
+	return s.work, nil
+}
+
+// subscribe returns a channel receiving the current work package and
+// then new packages as they change. The channel buffers only the most
+// recent package.
+func (s *Server) subscribe() chan Work {
+	ch := make(chan Work, 1)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	if s.hasWork {
+		ch <- s.work
+	}
+	s.mu.Unlock()
+	return ch
+}
+
+func (s *Server) unsubscribe(ch chan Work) {
+	s.mu.Lock()
+	delete(s.subs, ch)
+	s.mu.Unlock()
+}
+
+// waitWork waits until the current work package differs from the one
+// with header hash known and returns it. After timeout, or once cancel
+// is closed, it returns the current package even if unchanged.
+func (s *Server) waitWork(known common.Hash, timeout time.Duration, cancel <-chan struct{}) (Work, error) {
+	updates := s.subscribe()
+	defer s.unsubscribe(updates)
+	expire := time.NewTimer(timeout)
+	defer expire.Stop()
+	for {
+		select {
+		case work := <-updates:
+			if work.HeaderHash != known {
+				return work, nil
+			}
+		case <-expire.C:
+			return s.Work()
+		case <-cancel:
+			return s.Work()
+		}
+	}
+}
+
+// ShareStats counts the solutions submitted to a Server. Stale
+// solutions forwarded upstream are also counted as accepted or
+// rejected.
+type ShareStats struct {
+	Accepted     uint64 // forwarded and accepted by the upstream
+	Rejected     uint64 // forwarded and rejected by the upstream
+	Stale        uint64 // for work that has been replaced
+	Invalid      uint64 // the sum of UnknownWork, AboveTarget and BadMixDigest
+	Duplicate    uint64 // nonces submitted before for the same work
+	UnknownWork  uint64 // for work that was never issued or replaced long ago
+	AboveTarget  uint64 // not meeting the work's target
+	BadMixDigest uint64 // with a mix digest not matching the nonce
+	RateLimited  uint64 // exceeding the submission rate limit, see Limits
+}
+
+// RejectReason tells why a Server rejected a solution. It is sent as
+// the reason in the data of the error answering eth_submitWork.
+type RejectReason string
+
+const (
+	RejectUnknownWork  RejectReason = "unknown-work"
+	RejectStale        RejectReason = "stale"
+	RejectDuplicate    RejectReason = "duplicate"
+	RejectAboveTarget  RejectReason = "above-target"
+	RejectBadMixDigest RejectReason = "bad-mix-digest"
+	RejectUpstream     RejectReason = "upstream" // the upstream didn't accept it
+	RejectRateLimited  RejectReason = "rate-limited"
+)
+
+var rejectMessages = map[RejectReason]string{
+	RejectUnknownWork:  "unknown work",
+	RejectStale:        "stale work",
+	RejectDuplicate:    "duplicate nonce",
+	RejectAboveTarget:  "above target",
+	RejectBadMixDigest: "bad mix digest",
+	RejectUpstream:     "rejected by upstream",
+	RejectRateLimited:  "too many submissions",
+}
+
+// RejectError is the error of eth_submitWork calls for rejected
+// solutions, as returned by RPCUpstream.SubmitWork.
+type RejectError struct {
+	Reason RejectReason
+}
+
+func (err *RejectError) Error() string {
+	if msg, ok := rejectMessages[err.Reason]; ok {
+		return "solution rejected: " + msg
+	}
+	return "solution rejected: " + string(err.Reason)
+}
+
+// rejectData is the data of the error answering eth_submitWork for a
+// rejected solution.
+type rejectData struct {
+	Reason RejectReason `json:"reason"`
+}
+
+func rejectResponse(id json.RawMessage, err *RejectError) *rpcResponse {
+	return &rpcResponse{ID: id, Version: "2.0", Error: &rpcError{Code: -32000, Message: err.Error(), Data: rejectData{err.Reason}}}
+}
+
+// Stats returns the counts of solutions submitted so far.
+func (s *Server) Stats() ShareStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// UpstreamStats returns the counts of solutions forwarded to each
+// endpoint of the upstream, one unless it is made of several like a
+// FailoverUpstream.
+func (s *Server) UpstreamStats() []UpstreamStats {
+	s.mu.Lock()
+	upstream, submits := s.upstream, s.submits
+	s.mu.Unlock()
+	if u, ok := upstream.(statsUpstream); ok {
+		return u.UpstreamStats()
+	}
+	return submits.snapshot()
+}
+
+// SetSubmitStale sets whether solutions for recently replaced work are
+// still forwarded upstream, which may include them as uncles. By
+// default they are dropped.
+func (s *Server) SetSubmitStale(on bool) {
+	s.mu.Lock()
+	s.submitStale = on
+	s.mu.Unlock()
+}
+
+// findWork returns the work package a solution was found for and
+// whether that package has been replaced.
+func (s *Server) findWork(headerHash common.Hash) (work Work, stale, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hasWork && s.work.HeaderHash == headerHash {
+		return s.work, false, true
+	}
+	if work, ok := s.stale[headerHash]; ok {
+		return work, true, true
+	}
+	return Work{}, false, false
+}
+
+// seen records the nonce of a solution and reports whether it was
+// submitted before for the same work.
+func (s *Server) seen(sol Solution) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nonces := s.nonces[sol.HeaderHash]
+	if _, ok := nonces[sol.Nonce]; ok {
+		s.stats.Duplicate++
+		return true
+	}
+	if nonces == nil {
+		nonces = make(map[uint64]struct{})
+		s.nonces[sol.HeaderHash] = nonces
+	}
+	if len(nonces) < maxNoncesPerWork {
+		nonces[sol.Nonce] = struct{}{}
+	}
+	return false
+}
+
+func (s *Server) count(fields ...*uint64) {
+	s.mu.Lock()
+	for _, f := range fields {
+		*f++
+	}
+	s.mu.Unlock()
+}
+
+// SetLight sets the verification caches used to check the mix digest
+// of solutions before forwarding them. Without, the upstream rejects
+// solutions with a wrong mix digest or they don't meet the target.
+// Checking costs a cache per epoch, see Light.SetCachesInMem.
+func (s *Server) SetLight(l *ethash.Light) {
+	s.mu.Lock()
+	s.light = l
+	s.mu.Unlock()
+}
+
+// Submit checks a solution against the work it was found for and
+// forwards it upstream if it meets the work's target. Nonces submitted
+// before for the same work are rejected without checking them. Solutions for
+// replaced work are only forwarded if enabled with SetSubmitStale.
+// Submit reports whether the upstream accepted the solution.
+func (s *Server) Submit(sol Solution) (bool, error) {
+	reason, err := s.submit(sol)
+	return reason == "" && err == nil, err
+}
+
+// submit is Submit, returning why the solution was rejected or "" if
+// the upstream accepted it.
+func (s *Server) submit(sol Solution) (RejectReason, error) {
+	if _, err := s.Work(); err != nil {
+		return "", err
+	}
+	work, stale, ok := s.findWork(sol.HeaderHash)
+	if !ok {
+		glog.V(logger.Debug).Infof("Solution for unknown work %x rejected", sol.HeaderHash)
+		s.count(&s.stats.Invalid, &s.stats.UnknownWork)
+		return RejectUnknownWork, nil
+	}
+	if s.seen(sol) {
+		glog.V(logger.Debug).Infof("Duplicate solution %x for %x rejected", sol.Nonce, sol.HeaderHash)
+		return RejectDuplicate, nil
+	}
+	if !ethash.PrecheckSeal(sol.HeaderHash, sol.Nonce, sol.MixDigest, work.Difficulty()) {
+		glog.V(logger.Debug).Infof("Solution %x for %x above target rejected", sol.Nonce, sol.HeaderHash)
+		s.count(&s.stats.Invalid, &s.stats.AboveTarget)
+		return RejectAboveTarget, nil
+	}
+	if !s.checkMixDigest(work, sol) {
+		glog.V(logger.Debug).Infof("Solution %x for %x with bad mix digest rejected", sol.Nonce, sol.HeaderHash)
+		s.count(&s.stats.Invalid, &s.stats.BadMixDigest)
+		return RejectBadMixDigest, nil
+	}
+	if stale {
+		s.mu.Lock()
+		s.stats.Stale++
+		submit := s.submitStale
+		s.mu.Unlock()
+		if !submit {
+			glog.V(logger.Debug).Infof("Stale solution %x for %x dropped", sol.Nonce, sol.HeaderHash)
+			return RejectStale, nil
+		}
+	}
+	s.mu.Lock()
+	upstream, submits := s.upstream, s.submits
+	s.mu.Unlock()
+	started := time.Now()
+	accepted, err := upstream.SubmitWork(sol)
+	if _, ok := upstream.(statsUpstream); !ok {
+		submits.record(0, accepted, err, time.Since(started))
+	}
+	if err != nil {
+		return "", err
+	}
+	if !accepted {
+		s.count(&s.stats.Rejected)
+		return RejectUpstream, nil
+	}
+	s.count(&s.stats.Accepted)
+	return "", nil
+}
+
+// checkMixDigest reports whether the mix digest of sol is the one its
+// nonce yields, or whether it can't be checked.
+func (s *Server) checkMixDigest(work Work, sol Solution) bool {
+	s.mu.Lock()
+	light := s.light
+	s.mu.Unlock()
+	if light == nil {
+		return true
+	}
+	epoch, err := ethash.GetEpoch(work.SeedHash[:])
+	if err != nil {
+		return true
+	}
+	mixDigest, _, err := light.ComputeMixDigest(epoch, sol.HeaderHash, sol.Nonce)
+	if err != nil {
+		glog.V(logger.Warn).Infof("Can't check the mix digest of solution %x for %x: %v", sol.Nonce, sol.HeaderHash, err)
+		return true
+	}
+	return mixDigest == sol.MixDigest
+}
+
+// ServeHTTP serves the getWork JSON-RPC methods eth_getWork,
+// eth_submitWork and eth_submitHashrate.
+//
+// Miners may long-poll by passing the header hash of the work they have
+// as the first parameter of eth_getWork. The request then only returns
+// once there is different work, or after the timeout in seconds given
+// as the optional second parameter, DefaultLongPollTimeout by default,
+// with the unchanged work.
+//
+// A GET request upgrading to a websocket subscribes to the work: the
+// current package and every new one is pushed as a text message with
+// the JSON encoding of ethash.WorkPackage.
+//
+// If tokens are set, requests without a valid one are answered with
+// status 401, see SetTokens. Requests from addresses not allowed by the
+// limits are answered with status 403, see SetLimits.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip := remoteIP(r.RemoteAddr)
+	if !s.allowed(ip) {
+		glog.V(logger.Debug).Infof("Request from %s not allowed", ip)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if _, ok := s.authorize(requestToken(r)); !ok {
+		glog.V(logger.Debug).Infof("Unauthorized request from %s", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ethash"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if isWebSocket(r) {
+		if !s.openConn(ip) {
+			glog.V(logger.Debug).Infof("Too many connections from %s", ip)
+			http.Error(w, "too many connections", http.StatusTooManyRequests)
+			return
+		}
+		defer s.closeConn(ip)
+		s.serveWebSocket(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req rpcRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxRPCRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), decodeStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if res := s.limitSubmit(&req, ip); res != nil {
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if req.Method == "eth_getWork" && len(req.Params) > 0 {
+		json.NewEncoder(w).Encode(s.longPoll(&req, r.Context().Done()))
+		return
+	}
+	json.NewEncoder(w).Encode(s.handle(&req))
+}
+
+// decodeStatus returns the HTTP status answering a request whose body
+// can't be decoded because of err.
+func decodeStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// longPoll executes an eth_getWork request naming the work the miner
+// already has, see ServeHTTP.
+func (s *Server) longPoll(req *rpcRequest, cancel <-chan struct{}) *rpcResponse {
+	timeout := DefaultLongPollTimeout
+	if len(req.Params) > 1 {
+		secs, err := strconv.ParseUint(req.Params[1], 0, 32)
+		if err != nil {
+			return &rpcResponse{ID: req.ID, Version: "2.0", Error: &rpcError{Code: -32000, Message: errInvalidParams.Error()}}
+		}
+		timeout = time.Duration(secs) * time.Second
+	}
+	if timeout > maxLongPollTimeout {
+		timeout = maxLongPollTimeout
+	}
+	if _, err := s.waitWork(common.HexToHash(req.Params[0]), timeout, cancel); err != nil {
+		return &rpcResponse{ID: req.ID, Version: "2.0", Error: &rpcError{Code: -32000, Message: err.Error()}}
+	}
+	return s.handle(req)
+}
+
+// handle executes a JSON-RPC request and returns its response.
+func (s *Server) handle(req *rpcRequest) *rpcResponse {
+	var (
+		result interface{}
+		err    error
+	)
+	switch req.Method {
+	case "eth_getWork":
+		var work Work
+		if work, err = s.Work(); err == nil {
+			result = work.strings()
+		}
+	case "eth_submitWork":
+		var (
+			sol    Solution
+			reason RejectReason
+		)
+		if sol, err = parseSolution(req.Params); err == nil {
+			reason, err = s.submit(sol)
+		}
+		if err == nil && reason != "" {
+			err = &RejectError{reason}
+		}
+		result = err == nil
+	case "eth_submitHashrate":
+		if len(req.Params) < 2 {
+			err = errInvalidParams
+			break
+		}
+		var rate uint64
+		if rate, err = strconv.ParseUint(req.Params[0], 0, 64); err != nil {
+			err = errInvalidParams
+			break
+		}
+		if err = s.getUpstream().SubmitHashrate(rate, common.HexToHash(req.Params[1])); err == nil {
+			result = true
+		}
+	default:
+		return &rpcResponse{ID: req.ID, Version: "2.0", Error: &rpcError{Code: -32601, Message: "method not found"}}
+	}
+	if rerr, ok := err.(*RejectError); ok {
+		return rejectResponse(req.ID, rerr)
+	}
+	if err != nil {
+		return &rpcResponse{ID: req.ID, Version: "2.0", Error: &rpcError{Code: -32000, Message: err.Error()}}
+	}
+	enc, _ := json.Marshal(result)
+	return &rpcResponse{ID: req.ID, Version: "2.0", Result: enc}
+}
+
+func parseSolution(params []string) (Solution, error) {
+	if len(params) < 3 {
+		return Solution{}, errInvalidParams
+	}
+	nonce, err := strconv.ParseUint(params[0], 0, 64)
+	if err != nil {
+		return Solution{}, errInvalidParams
+	}
+	return Solution{
+		Nonce:      nonce,
+		HeaderHash: common.HexToHash(params[1]),
+		MixDigest:  common.HexToHash(params[2]),
+	}, nil
+}
@@ -0,0 +1,647 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type testUpstream struct {
+	mu        sync.Mutex
+	work      Work
+	solutions []Solution
+	rates     map[common.Hash]uint64
+}
+
+func (u *testUpstream) GetWork() (Work, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.work, nil
+}
+
+func (u *testUpstream) SubmitWork(s Solution) (bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.solutions = append(u.solutions, s)
+	return true, nil
+}
+
+func (u *testUpstream) SubmitHashrate(rate uint64, id common.Hash) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rates[id] = rate
+	return nil
+}
+
+var testWork = Work{
+	HeaderHash: common.HexToHash("0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d"),
+	SeedHash:   common.HexToHash("0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563"),
+	// difficulty 1, any seal passes the quick check.
+	Target: common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+}
+
+func TestServerHTTP(t *testing.T) {
+	up := &testUpstream{work: testWork, rates: make(map[common.Hash]uint64)}
+	srv := NewServer(up)
+	srv.poll()
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+
+	// talk to the server the way a proxy chained behind it would.
+	client := NewRPCUpstream(hs.URL)
+	work, err := client.GetWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work != testWork {
+		t.Errorf("got work %v, want %v", work, testWork)
+	}
+	if d := work.Difficulty(); d.Int64() != 1 {
+		t.Errorf("got difficulty %v, want 1", d)
+	}
+
+	sol := Solution{Nonce: 0x495732e0ed7a801c, HeaderHash: work.HeaderHash, MixDigest: common.HexToHash("0x01")}
+	if ok, err := client.SubmitWork(sol); !ok || err != nil {
+		t.Errorf("solution not accepted: %v", err)
+	}
+	stale := Solution{Nonce: 1, HeaderHash: common.HexToHash("0x02")}
+	if ok, err := client.SubmitWork(stale); ok || err != nil {
+		t.Errorf("solution for unknown work: got %v, %v", ok, err)
+	}
+	if len(up.solutions) != 1 || up.solutions[0] != sol {
+		t.Errorf("upstream got solutions %v, want %v", up.solutions, sol)
+	}
+
+	id := common.HexToHash("0x03")
+	if err := client.SubmitHashrate(1000, id); err != nil {
+		t.Fatal(err)
+	}
+	if up.rates[id] != 1000 {
+		t.Errorf("upstream got hashrate %d, want 1000", up.rates[id])
+	}
+
+	body := `{"method":"eth_getWork","params":["` + strings.Repeat("0", maxRPCRequestSize) + `"]}`
+	resp, err := http.Post(hs.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized request answered with status %d", resp.StatusCode)
+	}
+}
+
+func TestServerStratum(t *testing.T) {
+	up := &testUpstream{work: testWork, rates: make(map[common.Hash]uint64)}
+	srv := NewServer(up)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.ServeStratum(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	read := func() (res rpcResponse) {
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	conn.Write([]byte(`{"id":1,"method":"eth_submitLogin","params":["miner"]}` + "\n"))
+	if res := read(); string(res.ID) != "1" || string(res.Result) != "true" {
+		t.Fatalf("login: got %s %s", res.ID, res.Result)
+	}
+
+	// work is pushed once the server has it.
+	srv.poll()
+	res := read()
+	var work []string
+	if err := json.Unmarshal(res.Result, &work); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.ID) != "0" || len(work) != 3 || common.HexToHash(work[0]) != testWork.HeaderHash {
+		t.Fatalf("got push %s %s", res.ID, res.Result)
+	}
+
+	conn.Write([]byte(`{"id":2,"method":"eth_submitWork","params":["0x495732e0ed7a801c","` + work[0] + `","0x01"]}` + "\n"))
+	if res := read(); string(res.ID) != "2" || string(res.Result) != "true" {
+		t.Fatalf("submit: got %s %s %v", res.ID, res.Result, res.Error)
+	}
+}
+
+func TestCloseStratum(t *testing.T) {
+	srv := NewServer(&testUpstream{work: testWork})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.ServeStratum(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	conn.Write([]byte(`{"id":1,"method":"eth_submitLogin","params":["miner"]}` + "\n"))
+	var res rpcResponse
+	if err := dec.Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.CloseStratum("shutting down")
+	if err := dec.Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error == nil || res.Error.Message != "shutting down" {
+		t.Errorf("got %+v, want shutdown error", res)
+	}
+	if err := dec.Decode(&res); err == nil {
+		t.Error("connection still open")
+	}
+}
+
+func TestServerStaleSolutions(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.poll()
+	next := testWork
+	next.HeaderHash = common.HexToHash("0x04")
+	up.work = next
+	srv.poll()
+
+	sol := Solution{Nonce: 1, HeaderHash: testWork.HeaderHash}
+	if ok, err := srv.Submit(sol); ok || err != nil {
+		t.Errorf("stale solution: got %v, %v", ok, err)
+	}
+	if len(up.solutions) != 0 {
+		t.Error("stale solution forwarded")
+	}
+	srv.SetSubmitStale(true)
+	sol.Nonce = 2
+	if ok, err := srv.Submit(sol); !ok || err != nil {
+		t.Errorf("stale solution not forwarded: %v", err)
+	}
+	if ok, _ := srv.Submit(Solution{Nonce: 1, HeaderHash: common.HexToHash("0x05")}); ok {
+		t.Error("solution for unknown work accepted")
+	}
+	want := ShareStats{Accepted: 1, Stale: 2, Invalid: 1, UnknownWork: 1}
+	if stats := srv.Stats(); stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+}
+
+func TestServerUpstreamStats(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.poll()
+	srv.Submit(Solution{Nonce: 1, HeaderHash: testWork.HeaderHash})
+	srv.Submit(Solution{Nonce: 2, HeaderHash: testWork.HeaderHash})
+	srv.Submit(Solution{Nonce: 1, HeaderHash: common.HexToHash("0x05")}) // not forwarded
+	stats := srv.UpstreamStats()
+	if len(stats) == 1 {
+		stats[0].Latency = 0
+	}
+	if want := []UpstreamStats{{Name: "upstream", Submitted: 2, Accepted: 2}}; !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+	// the counts start over with a new upstream.
+	srv.SetUpstream(NewRPCUpstream("http://127.0.0.1:1"))
+	if want := []UpstreamStats{{Name: "http://127.0.0.1:1"}}; !reflect.DeepEqual(srv.UpstreamStats(), want) {
+		t.Errorf("got %+v after SetUpstream, want %+v", srv.UpstreamStats(), want)
+	}
+}
+
+func TestServerDuplicateSolutions(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.poll()
+
+	sol := Solution{Nonce: 1, HeaderHash: testWork.HeaderHash}
+	if ok, err := srv.Submit(sol); !ok || err != nil {
+		t.Fatalf("solution not accepted: %v", err)
+	}
+	if ok, err := srv.Submit(sol); ok || err != nil {
+		t.Errorf("duplicate solution: got %v, %v", ok, err)
+	}
+	if len(up.solutions) != 1 {
+		t.Errorf("upstream got %d solutions, want 1", len(up.solutions))
+	}
+	want := ShareStats{Accepted: 1, Duplicate: 1}
+	if stats := srv.Stats(); stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+}
+
+func TestServerReorgedWork(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.poll()
+	first := Solution{Nonce: 1, HeaderHash: testWork.HeaderHash}
+	if ok, _ := srv.Submit(first); !ok {
+		t.Fatal("solution not accepted")
+	}
+
+	// a reorg replaces the head and then goes back to it.
+	next := testWork
+	next.HeaderHash = common.HexToHash("0x04")
+	up.work = next
+	srv.poll()
+	up.work = testWork
+	srv.poll()
+
+	if ok, _ := srv.Submit(Solution{Nonce: 2, HeaderHash: testWork.HeaderHash}); !ok {
+		t.Error("solution for reinstated work not accepted")
+	}
+	if ok, _ := srv.Submit(first); ok {
+		t.Error("nonce submitted before the reorg accepted again")
+	}
+	if ok, _ := srv.Submit(Solution{Nonce: 1, HeaderHash: next.HeaderHash}); ok {
+		t.Error("stale solution forwarded")
+	}
+	want := ShareStats{Accepted: 2, Stale: 1, Duplicate: 1}
+	if stats := srv.Stats(); stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+}
+
+func TestServerStaleHistory(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.poll()
+	for i := 0; i < staleWorkHistory; i++ {
+		up.work.HeaderHash = common.BigToHash(big.NewInt(int64(i + 1)))
+		srv.poll()
+	}
+	srv.SetSubmitStale(true)
+	if ok, _ := srv.Submit(Solution{Nonce: 1, HeaderHash: testWork.HeaderHash}); !ok {
+		t.Errorf("solution for work replaced %d times not forwarded", staleWorkHistory)
+	}
+	up.work.HeaderHash = common.HexToHash("0xff")
+	srv.poll()
+	if ok, _ := srv.Submit(Solution{Nonce: 2, HeaderHash: testWork.HeaderHash}); ok {
+		t.Errorf("solution for work replaced %d times forwarded", staleWorkHistory+1)
+	}
+	if n := len(srv.nonces); n > staleWorkHistory+1 {
+		t.Errorf("nonces kept for %d packages, want at most %d", n, staleWorkHistory+1)
+	}
+}
+
+func TestServerLongPoll(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.poll()
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+	getWork := func(params ...string) (*rpcResponse, time.Duration) {
+		body, _ := json.Marshal(rpcRequest{ID: json.RawMessage("1"), Method: "eth_getWork", Params: params})
+		start := time.Now()
+		resp, err := http.Post(hs.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var res rpcResponse
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		return &res, time.Since(start)
+	}
+	headerOf := func(res *rpcResponse) common.Hash {
+		var fields []string
+		if err := json.Unmarshal(res.Result, &fields); err != nil || len(fields) < 3 {
+			t.Fatalf("invalid work %s, error %v", res.Result, res.Error)
+		}
+		return common.HexToHash(fields[0])
+	}
+
+	// work other than the known one is returned right away.
+	if res, _ := getWork("0x01"); headerOf(res) != testWork.HeaderHash {
+		t.Errorf("got work %s, want %x", res.Result, testWork.HeaderHash)
+	}
+	// unchanged work is returned after the timeout.
+	if res, d := getWork(testWork.HeaderHash.Hex(), "1"); headerOf(res) != testWork.HeaderHash || d < time.Second {
+		t.Errorf("got work %s after %v, want the known work after 1s", res.Result, d)
+	}
+	// new work ends the wait.
+	next := testWork
+	next.HeaderHash = common.HexToHash("0x04")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		up.mu.Lock()
+		up.work = next
+		up.mu.Unlock()
+		srv.poll()
+	}()
+	if res, d := getWork(testWork.HeaderHash.Hex(), "10"); headerOf(res) != next.HeaderHash || d > 5*time.Second {
+		t.Errorf("got work %s after %v, want the new work", res.Result, d)
+	}
+	if res, _ := getWork(next.HeaderHash.Hex(), "x"); res.Error == nil {
+		t.Error("no error for invalid timeout")
+	}
+}
+
+func TestServerWebSocket(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.poll()
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+
+	conn, err := net.Dial("tcp", hs.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: miner\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the accept key of the example in RFC 6455.
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake failed: %v %v", resp.Status, resp.Header)
+	}
+	// readWork reads a server frame, which is unmasked and has a 16 bit
+	// length.
+	readWork := func() ethash.WorkPackage {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			t.Fatal(err)
+		}
+		if hdr[0] != 0x80|wsText || hdr[1] != 126 {
+			t.Fatalf("unexpected frame header %x", hdr)
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(hdr[2:]))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			t.Fatal(err)
+		}
+		var w ethash.WorkPackage
+		if err := json.Unmarshal(payload, &w); err != nil {
+			t.Fatalf("invalid work %s: %v", payload, err)
+		}
+		return w
+	}
+	if w := readWork(); w != testWork.Package() {
+		t.Errorf("got %+v, want %+v", w, testWork.Package())
+	}
+
+	next := testWork
+	next.HeaderHash = common.HexToHash("0x04")
+	next.BlockNumber = 30001
+	up.mu.Lock()
+	up.work = next
+	up.mu.Unlock()
+	srv.poll()
+	if w := readWork(); w.HeaderHash != next.HeaderHash || w.BlockNumber != 30001 {
+		t.Errorf("got %+v, want %+v", w, next.Package())
+	}
+
+	// a masked ping is answered with a pong, a close frame with a close.
+	conn.Write([]byte{0x80 | wsPing, 0x80 | 2, 1, 2, 3, 4, 'h' ^ 1, 'i' ^ 2})
+	var pong [4]byte
+	if _, err := io.ReadFull(r, pong[:]); err != nil || pong != [4]byte{0x80 | wsPong, 2, 'h', 'i'} {
+		t.Errorf("got pong %x, %v", pong, err)
+	}
+	conn.Write([]byte{0x80 | wsClose, 0x80, 1, 2, 3, 4})
+	var closing [2]byte
+	if _, err := io.ReadFull(r, closing[:]); err != nil || closing != [2]byte{0x80 | wsClose, 0} {
+		t.Errorf("got close %x, %v", closing, err)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("connection still open: %v", err)
+	}
+}
+
+func TestServerRejectReasons(t *testing.T) {
+	// block 22 of proof of concept nine testnet, with difficulty 1.
+	work := testWork
+	work.SeedHash = common.Hash{}
+	up := &testUpstream{work: work}
+	srv := NewServer(up)
+	srv.SetLight(new(ethash.Light))
+	srv.poll()
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+
+	mixDigest := common.HexToHash("0x2f74cdeb198af0b9abe65d22d372e22fb2d474371774a9583c1cc427a07939f5")
+	submit := func(sol Solution) RejectReason {
+		params := sol.strings()
+		body, _ := json.Marshal(rpcRequest{ID: json.RawMessage("1"), Method: "eth_submitWork", Params: params[:]})
+		resp, err := http.Post(hs.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var res struct {
+			Result bool
+			Error  *struct {
+				Message string
+				Data    rejectData
+			}
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Error == nil {
+			if !res.Result {
+				t.Error("neither accepted nor rejected")
+			}
+			return ""
+		}
+		return res.Error.Data.Reason
+	}
+
+	valid := Solution{Nonce: 0x495732e0ed7a801c, HeaderHash: work.HeaderHash, MixDigest: mixDigest}
+	tests := []struct {
+		sol  Solution
+		want RejectReason
+	}{
+		{valid, ""},
+		{valid, RejectDuplicate},
+		{Solution{Nonce: 0x495732e0ed7a801d, HeaderHash: work.HeaderHash, MixDigest: mixDigest}, RejectBadMixDigest},
+		{Solution{Nonce: 1, HeaderHash: common.HexToHash("0x05")}, RejectUnknownWork},
+	}
+	for i, test := range tests {
+		if reason := submit(test.sol); reason != test.want {
+			t.Errorf("solution %d: got reason %q, want %q", i, reason, test.want)
+		}
+	}
+
+	up.mu.Lock()
+	up.work = Work{HeaderHash: common.HexToHash("0x06"), Target: common.HexToHash("0x01")}
+	up.mu.Unlock()
+	srv.poll()
+	if reason := submit(Solution{Nonce: 1, HeaderHash: common.HexToHash("0x06")}); reason != RejectAboveTarget {
+		t.Errorf("got reason %q, want %q", reason, RejectAboveTarget)
+	}
+	if reason := submit(Solution{Nonce: 0x495732e0ed7a801c, HeaderHash: work.HeaderHash, MixDigest: mixDigest}); reason != RejectDuplicate {
+		t.Errorf("got reason %q, want %q", reason, RejectDuplicate)
+	}
+	want := ShareStats{Accepted: 1, Invalid: 3, Duplicate: 2, UnknownWork: 1, AboveTarget: 1, BadMixDigest: 1}
+	if stats := srv.Stats(); stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+}
+
+func TestServerTokens(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.SetTokens(map[string]string{"secret": "rig1"})
+	srv.poll()
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+
+	if _, err := NewRPCUpstream(hs.URL).GetWork(); err == nil {
+		t.Error("got work without token")
+	}
+	if _, err := NewRPCUpstream(hs.URL + "/wrong").GetWork(); err == nil {
+		t.Error("got work with wrong token")
+	}
+	if _, err := NewRPCUpstream(hs.URL + "/secret").GetWork(); err != nil {
+		t.Errorf("token in path: %v", err)
+	}
+	req, _ := http.NewRequest("POST", hs.URL, strings.NewReader(`{"id":1,"method":"eth_getWork"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("bearer token: status %v", resp.Status)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.ServeStratum(l)
+	stratum := func(lines ...string) []rpcResponse {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		dec := json.NewDecoder(conn)
+		var res []rpcResponse
+		for _, line := range lines {
+			io.WriteString(conn, line+"\n")
+			var r rpcResponse
+			if err := dec.Decode(&r); err != nil {
+				break
+			}
+			res = append(res, r)
+		}
+		return res
+	}
+	if res := stratum(`{"id":1,"method":"eth_getWork"}`); len(res) != 1 || res[0].Error == nil {
+		t.Errorf("work before login: %+v", res)
+	}
+	res := stratum(`{"id":1,"method":"eth_submitLogin","params":["wallet.rig1","wrong"]}`, `{"id":2,"method":"eth_getWork"}`)
+	if len(res) != 1 || res[0].Error == nil {
+		t.Errorf("login with wrong token: %+v", res)
+	}
+	res = stratum(`{"id":1,"method":"eth_submitLogin","params":["wallet.rig1","secret"]}`, `{"id":2,"method":"eth_getWork"}`)
+	if len(res) != 2 || string(res[0].Result) != "true" || res[1].Error != nil {
+		t.Errorf("login with token: %+v", res)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	up := &testUpstream{work: testWork}
+	srv := NewServer(up)
+	srv.poll()
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.ServeStratum(l)
+
+	_, other, _ := net.ParseCIDR("10.0.0.0/8")
+	srv.SetLimits(Limits{Allow: []*net.IPNet{other}})
+	if _, err := NewRPCUpstream(hs.URL).GetWork(); err == nil {
+		t.Error("got work from an address not allowed")
+	}
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("stratum connection from an address not allowed: %v", err)
+	}
+	conn.Close()
+
+	_, local, _ := net.ParseCIDR("127.0.0.0/8")
+	srv.SetLimits(Limits{Allow: []*net.IPNet{other, local}, ConnsPerIP: 1, SubmitRate: 0.001, SubmitBurst: 2})
+	first, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	first.SetDeadline(time.Now().Add(10 * time.Second))
+	dec := json.NewDecoder(first)
+	var pushed rpcResponse
+	if err := dec.Decode(&pushed); err != nil {
+		t.Fatalf("no work pushed: %v", err)
+	}
+	second, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("connection beyond the cap: %v", err)
+	}
+	second.Close()
+
+	// two submissions pass, the third exceeds the rate.
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(first, `{"id":%d,"method":"eth_submitWork","params":["0x%x","%s","%s"]}`+"\n", i+1, i, testWork.HeaderHash.Hex(), common.Hash{}.Hex())
+		var res struct {
+			Error *struct{ Data rejectData }
+		}
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		limited := res.Error != nil && res.Error.Data.Reason == RejectRateLimited
+		if limited != (i == 2) {
+			t.Errorf("submission %d: rate limited %v", i, limited)
+		}
+	}
+	if n := srv.Stats().RateLimited; n != 1 {
+		t.Errorf("%d submissions rate limited, want 1", n)
+	}
+}
@@ -0,0 +1,74 @@
+package remote
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/ethash"
+)
+
+// Status is a snapshot of a Miner and its Server for dashboards, e.g.
+// polled over HTTP or written to a file with WriteStatusFile. It
+// encodes to JSON as is.
+type Status struct {
+	Time      time.Time       `json:"time"`
+	HasWork   bool            `json:"hasWork"`  // the upstream provided work
+	Epoch     uint64          `json:"epoch"`    // of the current work
+	Ready     bool            `json:"ready"`    // the DAG of the epoch is in memory
+	Threads   int             `json:"threads"`  // local mining threads
+	Hashrate  int64           `json:"hashrate"` // of the local threads in kH/s, as GetHashrate
+	Shares    ShareStats      `json:"shares"`
+	Upstreams []UpstreamStats `json:"upstreams"` // solutions forwarded by endpoint
+	Found     MinerStats      `json:"found"`
+	Memory    ethash.MemStats `json:"memory"`
+}
+
+// Status returns the current status of the miner and its server.
+func (m *Miner) Status() Status {
+	s := Status{
+		Time:      time.Now(),
+		Threads:   m.Threads(),
+		Hashrate:  m.pow.GetHashrate(),
+		Shares:    m.srv.Stats(),
+		Upstreams: m.srv.UpstreamStats(),
+		Found:     m.Stats(),
+		Memory:    ethash.MemoryStats(),
+	}
+	if work, err := m.srv.Work(); err == nil {
+		if epoch, err := ethash.GetEpoch(work.SeedHash[:]); err == nil {
+			s.HasWork, s.Epoch = true, epoch
+			_, s.Ready = s.Memory.DAGs[epoch]
+		}
+	}
+	return s
+}
+
+// WriteStatusFile writes s as JSON to path. The file is replaced
+// atomically, so readers never see a partial status.
+func WriteStatusFile(path string, s Status) error {
+	buf, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(buf, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,57 @@
+package remote
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMinerStatus(t *testing.T) {
+	eth, err := ethash.NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	srv := NewServer(&testUpstream{work: testWork, rates: make(map[common.Hash]uint64)})
+	m := NewMiner(srv, eth.Full)
+	if s := m.Status(); s.HasWork || s.Ready {
+		t.Errorf("status %+v before the first work", s)
+	}
+	srv.poll()
+	ds, err := eth.Full.Dataset(epochLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Release()
+	s := m.Status()
+	if !s.HasWork || s.Epoch != 1 || !s.Ready || s.Time.IsZero() {
+		t.Errorf("got status %+v, want the DAG of epoch 1 ready", s)
+	}
+
+	path := filepath.Join(eth.Full.Dir, "status.json")
+	if err := WriteStatusFile(path, s); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"time", "epoch", "ready", "hashrate", "shares", "upstreams", "memory"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("%s missing from status file %s", key, buf)
+		}
+	}
+	if decoded["epoch"] != 1.0 || decoded["ready"] != true {
+		t.Errorf("status file %s does not match %+v", buf, s)
+	}
+}
@@ -0,0 +1,149 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// maxStratumLine bounds the size of a single stratum request.
+const maxStratumLine = 16 * 1024
+
+// ServeStratum accepts stratum connections on l until it is closed.
+// It speaks the eth-proxy dialect of stratum: newline separated
+// JSON-RPC requests using the getWork method names, with new work
+// pushed to the miner as a response with id 0. eth_submitLogin is
+// accepted without checking unless tokens are set, see SetTokens. Then
+// miners must log in first and are disconnected if their token is
+// invalid. Connections from addresses not allowed by the limits or
+// exceeding their connection cap are closed right away, see SetLimits.
+func (s *Server) ServeStratum(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return err
+		}
+		go s.serveStratumConn(conn)
+	}
+}
+
+type stratumConn struct {
+	conn net.Conn
+	mu   sync.Mutex // serializes writes
+	enc  *json.Encoder
+}
+
+func (c *stratumConn) send(res *rpcResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(res)
+}
+
+// CloseStratum disconnects all stratum miners, sending them an error
+// with the given reason first.
+func (s *Server) CloseStratum(reason string) {
+	s.mu.Lock()
+	conns := make([]*stratumConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+	for _, c := range conns {
+		c.send(&rpcResponse{ID: json.RawMessage("null"), Version: "2.0", Error: &rpcError{Code: -32000, Message: reason}})
+		c.conn.Close()
+	}
+}
+
+func (s *Server) serveStratumConn(conn net.Conn) {
+	defer conn.Close()
+	ip := remoteIP(conn.RemoteAddr().String())
+	if !s.admit(ip) {
+		return
+	}
+	defer s.closeConn(ip)
+	c := &stratumConn{conn: conn, enc: json.NewEncoder(conn)}
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+	glog.V(logger.Debug).Infof("Stratum connection from %v", conn.RemoteAddr())
+
+	done := make(chan struct{})
+	defer close(done)
+	pushing := false
+	// push starts pushing work to the miner.
+	push := func() {
+		if pushing {
+			return
+		}
+		pushing = true
+		work := s.subscribe()
+		go func() {
+			defer s.unsubscribe(work)
+			for {
+				select {
+				case <-done:
+					return
+				case w := <-work:
+					enc, _ := json.Marshal(w.strings())
+					if c.send(&rpcResponse{ID: json.RawMessage("0"), Version: "2.0", Result: enc}) != nil {
+						conn.Close()
+						return
+					}
+				}
+			}
+		}()
+	}
+	_, loggedIn := s.authorize("")
+	if loggedIn {
+		push()
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxStratumLine)
+	for scanner.Scan() {
+		var req rpcRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			glog.V(logger.Debug).Infof("Invalid stratum request from %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+		var res *rpcResponse
+		switch {
+		case req.Method == "eth_submitLogin":
+			worker, ok := s.authorize(loginToken(req.Params))
+			if !ok {
+				glog.V(logger.Debug).Infof("Stratum login from %v rejected", conn.RemoteAddr())
+				c.send(&rpcResponse{ID: req.ID, Version: "2.0", Error: &rpcError{Code: -32000, Message: "unauthorized"}})
+				return
+			}
+			if worker != "" {
+				glog.V(logger.Debug).Infof("Stratum connection from %v logged in as %s", conn.RemoteAddr(), worker)
+			}
+			loggedIn = true
+			res = &rpcResponse{ID: req.ID, Version: "2.0", Result: json.RawMessage("true")}
+		case !loggedIn:
+			res = &rpcResponse{ID: req.ID, Version: "2.0", Error: &rpcError{Code: -32000, Message: "not logged in"}}
+		default:
+			if res = s.limitSubmit(&req, ip); res == nil {
+				res = s.handle(&req)
+			}
+		}
+		if c.send(res) != nil {
+			return
+		}
+		if loggedIn {
+			push()
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package remote hands out ethash work to external miners. It fetches
+// work from an upstream node, serves it over the getWork JSON-RPC
+// protocol and stratum and pushes it over websockets, forwarding
+// solutions back upstream. It also serves seal verification to
+// services that don't hold caches.
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+var maxUint256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// Work is a work package as returned by eth_getWork.
+type Work struct {
+	HeaderHash common.Hash // hash of the header without nonce and mix digest
+	SeedHash   common.Hash // seed hash of the block's epoch
+	Target     common.Hash // boundary a seal's result must not exceed
+	// BlockNumber is the number of the block being mined, zero if the
+	// upstream doesn't send it.
+	BlockNumber uint64
+}
+
+// Difficulty returns the difficulty corresponding to the work's target.
+func (w Work) Difficulty() *big.Int {
+	target := w.Target.Big()
+	if target.Sign() == 0 {
+		return new(big.Int).Set(maxUint256)
+	}
+	return target.Div(maxUint256, target)
+}
+
+func (w Work) strings() [3]string {
+	return [3]string{w.HeaderHash.Hex(), w.SeedHash.Hex(), w.Target.Hex()}
+}
+
+// Solution is a seal found by a miner for a work package.
+type Solution struct {
+	Nonce      uint64
+	HeaderHash common.Hash
+	MixDigest  common.Hash
+}
+
+func (s Solution) strings() [3]string {
+	return [3]string{fmt.Sprintf("0x%016x", s.Nonce), s.HeaderHash.Hex(), s.MixDigest.Hex()}
+}
+
+// Upstream is the source of work packages, usually a node.
+type Upstream interface {
+	// GetWork returns the current work package.
+	GetWork() (Work, error)
+	// SubmitWork hands a solution to the upstream and reports whether
+	// it was accepted.
+	SubmitWork(s Solution) (bool, error)
+	// SubmitHashrate reports the hashrate of the miner with the given id.
+	SubmitHashrate(rate uint64, id common.Hash) error
+}
+
+// RPCUpstream is an Upstream speaking JSON-RPC over HTTP.
+type RPCUpstream struct {
+	URL    string
+	Client *http.Client // http.DefaultClient if nil
+
+	id uint64
+}
+
+// NewRPCUpstream returns an upstream for the node RPC endpoint at url.
+func NewRPCUpstream(url string) *RPCUpstream {
+	return &RPCUpstream{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// String returns the URL of u.
+func (u *RPCUpstream) String() string { return u.URL }
+
+// GetWork implements Upstream.
+func (u *RPCUpstream) GetWork() (Work, error) {
+	var res []string
+	if err := u.call("eth_getWork", nil, &res); err != nil {
+		return Work{}, err
+	}
+	return parseWork(res)
+}
+
+// SubmitWork implements Upstream. Solutions rejected with a
+// RejectError, as done by a Server, are reported as not accepted.
+func (u *RPCUpstream) SubmitWork(s Solution) (bool, error) {
+	var ok bool
+	params := s.strings()
+	err := u.call("eth_submitWork", params[:], &ok)
+	if _, rejected := err.(*RejectError); rejected {
+		glog.V(logger.Debug).Infof("Upstream %s: %v", u.URL, err)
+		return false, nil
+	}
+	return ok, err
+}
+
+// SubmitHashrate implements Upstream.
+func (u *RPCUpstream) SubmitHashrate(rate uint64, id common.Hash) error {
+	var ok bool
+	return u.call("eth_submitHashrate", []string{fmt.Sprintf("0x%x", rate), id.Hex()}, &ok)
+}
+
+func (u *RPCUpstream) call(method string, params []string, result interface{}) error {
+	if params == nil {
+		params = []string{}
+	}
+	req, err := json.Marshal(rpcRequest{
+		ID:      json.RawMessage(strconv.FormatUint(atomic.AddUint64(&u.id, 1), 10)),
+		Version: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(u.URL, "application/json", bytes.NewReader(req))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", method, resp.Status)
+	}
+	var res rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	if res.Error != nil {
+		if data, ok := res.Error.Data.(map[string]interface{}); ok {
+			if reason, ok := data["reason"].(string); ok && reason != "" {
+				return &RejectError{RejectReason(reason)}
+			}
+		}
+		return fmt.Errorf("%s: %s", method, res.Error.Message)
+	}
+	return json.Unmarshal(res.Result, result)
+}
+
+var errInvalidWork = errors.New("invalid work package")
+
+func parseWork(res []string) (Work, error) {
+	if len(res) < 3 {
+		return Work{}, errInvalidWork
+	}
+	work := Work{
+		HeaderHash: common.HexToHash(res[0]),
+		SeedHash:   common.HexToHash(res[1]),
+		Target:     common.HexToHash(res[2]),
+	}
+	if len(res) > 3 {
+		// newer nodes add the block number.
+		number, err := strconv.ParseUint(res[3], 0, 64)
+		if err != nil {
+			return Work{}, errInvalidWork
+		}
+		work.BlockNumber = number
+	}
+	return work, nil
+}
@@ -0,0 +1,74 @@
+package remote
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// UpstreamStats counts the solutions forwarded to an upstream endpoint,
+// e.g. to compare the pools a FailoverUpstream switches between.
+type UpstreamStats struct {
+	Name      string        // URL of the endpoint if known
+	Submitted uint64        // solutions forwarded
+	Accepted  uint64        // accepted by the endpoint
+	Rejected  uint64        // rejected by the endpoint
+	Errors    uint64        // not answered, e.g. the endpoint was unreachable
+	Latency   time.Duration // mean time the endpoint took to answer
+}
+
+// statsUpstream is implemented by upstreams counting the solutions of
+// each of their endpoints, like FailoverUpstream.
+type statsUpstream interface {
+	UpstreamStats() []UpstreamStats
+}
+
+// upstreamStats counts the solutions submitted to a list of endpoints.
+type upstreamStats struct {
+	mu      sync.Mutex
+	stats   []UpstreamStats
+	elapsed []time.Duration // total latency of the answered submissions
+}
+
+func newUpstreamStats(names ...string) *upstreamStats {
+	u := &upstreamStats{stats: make([]UpstreamStats, len(names)), elapsed: make([]time.Duration, len(names))}
+	for i, name := range names {
+		u.stats[i].Name = name
+	}
+	return u
+}
+
+// record counts a submission to endpoint i that took latency.
+func (u *upstreamStats) record(i int, accepted bool, err error, latency time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	s := &u.stats[i]
+	s.Submitted++
+	switch {
+	case err != nil:
+		s.Errors++
+		return
+	case accepted:
+		s.Accepted++
+	default:
+		s.Rejected++
+	}
+	u.elapsed[i] += latency
+	s.Latency = u.elapsed[i] / time.Duration(s.Accepted+s.Rejected)
+}
+
+// snapshot returns a copy of the counts.
+func (u *upstreamStats) snapshot() []UpstreamStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]UpstreamStats(nil), u.stats...)
+}
+
+// upstreamName describes u for logs and statistics, using fallback
+// if u doesn't describe itself.
+func upstreamName(u Upstream, fallback string) string {
+	if s, ok := u.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fallback
+}
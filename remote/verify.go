@@ -0,0 +1,180 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// VerifyRequest is the body of a request to a VerifyService. Hashes
+// are hex encoded, nonce and difficulty are given as decimal numbers or
+// hex with 0x prefix.
+type VerifyRequest struct {
+	HeaderHash string `json:"headerHash"` // hash of the header without nonce and mix digest
+	Number     uint64 `json:"number"`
+	Nonce      string `json:"nonce"`
+	MixDigest  string `json:"mixDigest"`
+	Difficulty string `json:"difficulty"`
+}
+
+// VerifyResponse is the answer of a VerifyService. Error gives the
+// reason a seal is invalid.
+type VerifyResponse struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// sealBlock presents a verification request as a block.
+type sealBlock struct {
+	hashNoNonce common.Hash
+	number      uint64
+	nonce       uint64
+	mixDigest   common.Hash
+	difficulty  *big.Int
+}
+
+func (b *sealBlock) Difficulty() *big.Int     { return b.difficulty }
+func (b *sealBlock) HashNoNonce() common.Hash { return b.hashNoNonce }
+func (b *sealBlock) Nonce() uint64            { return b.nonce }
+func (b *sealBlock) MixDigest() common.Hash   { return b.mixDigest }
+func (b *sealBlock) NumberU64() uint64        { return b.number }
+
+// VerifyService checks seals over HTTP, so that indexers, bridges and
+// similar services can share the caches of one verifier instead of
+// each generating their own. It accepts a VerifyRequest as JSON in the
+// body of a POST request and responds with a VerifyResponse. A JSON
+// array of up to maxVerifyBatch requests is answered with an array of
+// responses in the same order, see VerifyBatch.
+//
+// The caches are those of the Light passed to NewVerifyService, whose
+// settings apply: SetCachesInMem bounds the epochs held at once and
+// SetLookahead, which should usually be NoLookaheadLimit for a shared
+// verifier, limits the epochs checked.
+type VerifyService struct {
+	light *ethash.Light
+}
+
+// NewVerifyService returns a service verifying seals with light.
+func NewVerifyService(light *ethash.Light) *VerifyService {
+	return &VerifyService{light: light}
+}
+
+var epochLength = func() uint64 {
+	alg, err := ethash.GetAlgorithm("ethash")
+	if err != nil {
+		panic(err)
+	}
+	return alg.EpochLength()
+}()
+
+// maxVerifyBatch bounds the number of seals per HTTP request, and
+// maxVerifyRequestSize the size of its body, which leaves about twice
+// the room needed by a batch of maxVerifyBatch seals.
+const (
+	maxVerifyBatch       = 10000
+	maxVerifyRequestSize = maxVerifyBatch * 512
+)
+
+var errInvalidSeal = errors.New("invalid seal")
+
+// Verify checks the seal described by req. It returns an error if the
+// request is malformed, otherwise whether the seal is valid and why
+// not.
+func (s *VerifyService) Verify(req *VerifyRequest) (VerifyResponse, error) {
+	nonce, err := strconv.ParseUint(req.Nonce, 0, 64)
+	if err != nil {
+		return VerifyResponse{}, errors.New("invalid nonce")
+	}
+	difficulty, ok := new(big.Int).SetString(req.Difficulty, 0)
+	if !ok {
+		return VerifyResponse{}, errors.New("invalid difficulty")
+	}
+	return s.verify(&sealBlock{
+		hashNoNonce: common.HexToHash(req.HeaderHash),
+		number:      req.Number,
+		nonce:       nonce,
+		mixDigest:   common.HexToHash(req.MixDigest),
+		difficulty:  difficulty,
+	}), nil
+}
+
+// VerifyBatch checks the seals described by reqs. Malformed requests
+// are answered with an error in their response. The seals are checked
+// in the order of their epochs, so each cache is needed only once even
+// if the batch holds more epochs than caches are kept in memory.
+func (s *VerifyService) VerifyBatch(reqs []VerifyRequest) []VerifyResponse {
+	order := make([]int, len(reqs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return reqs[order[i]].Number/epochLength < reqs[order[j]].Number/epochLength
+	})
+	res := make([]VerifyResponse, len(reqs))
+	for _, i := range order {
+		r, err := s.Verify(&reqs[i])
+		if err != nil {
+			r = VerifyResponse{Error: err.Error()}
+		}
+		res[i] = r
+	}
+	return res
+}
+
+func (s *VerifyService) verify(block *sealBlock) VerifyResponse {
+	if err := ethash.CheckDifficulty(block.difficulty); err != nil {
+		return VerifyResponse{Error: err.Error()}
+	}
+	if err := s.light.CheckBlockNumber(block.number); err != nil {
+		return VerifyResponse{Error: err.Error()}
+	}
+	if !s.light.Verify(block) {
+		return VerifyResponse{Error: errInvalidSeal.Error()}
+	}
+	return VerifyResponse{Valid: true}
+}
+
+func (s *VerifyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body json.RawMessage
+	r.Body = http.MaxBytesReader(w, r.Body, maxVerifyRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), decodeStatus(err))
+		return
+	}
+	var res interface{}
+	if body[0] == '[' {
+		var reqs []VerifyRequest
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(reqs) > maxVerifyBatch {
+			http.Error(w, "more than "+strconv.Itoa(maxVerifyBatch)+" seals", http.StatusRequestEntityTooLarge)
+			return
+		}
+		res = s.VerifyBatch(reqs)
+	} else {
+		var req VerifyRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		if res, err = s.Verify(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,130 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/ethash"
+)
+
+func TestVerifyService(t *testing.T) {
+	hs := httptest.NewServer(NewVerifyService(new(ethash.Light)))
+	defer hs.Close()
+
+	// block 22 of proof of concept nine testnet.
+	valid := VerifyRequest{
+		HeaderHash: "0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d",
+		Number:     22,
+		Nonce:      "0x495732e0ed7a801c",
+		MixDigest:  "0x2f74cdeb198af0b9abe65d22d372e22fb2d474371774a9583c1cc427a07939f5",
+		Difficulty: "132416",
+	}
+	wrongNonce := valid
+	wrongNonce.Nonce = "0x495732e0ed7a801d"
+	zeroDifficulty := valid
+	zeroDifficulty.Difficulty = "0"
+	tests := []struct {
+		req   VerifyRequest
+		valid bool
+	}{
+		{valid, true},
+		{wrongNonce, false},
+		{zeroDifficulty, false},
+	}
+	for i, test := range tests {
+		body, _ := json.Marshal(test.req)
+		resp, err := http.Post(hs.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res VerifyResponse
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res.Valid != test.valid || (res.Error == "") != test.valid {
+			t.Errorf("test %d: got %+v, want valid %v", i, res, test.valid)
+		}
+	}
+
+	resp, err := http.Post(hs.URL, "application/json", bytes.NewReader([]byte(`{"nonce": "x"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("malformed request answered with status %d", resp.StatusCode)
+	}
+}
+
+func TestVerifyServiceBatch(t *testing.T) {
+	hs := httptest.NewServer(NewVerifyService(new(ethash.Light)))
+	defer hs.Close()
+
+	valid := VerifyRequest{
+		HeaderHash: "0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d",
+		Number:     22,
+		Nonce:      "0x495732e0ed7a801c",
+		MixDigest:  "0x2f74cdeb198af0b9abe65d22d372e22fb2d474371774a9583c1cc427a07939f5",
+		Difficulty: "132416",
+	}
+	wrongNonce := valid
+	wrongNonce.Nonce = "0x495732e0ed7a801d"
+	malformed := valid
+	malformed.Difficulty = "x"
+	// block 30001 of proof of concept nine testnet, epoch 1.
+	other := VerifyRequest{
+		HeaderHash: "0x7e44356ee3441623bc72a683fd3708fdf75e971bbe294f33e539eedad4b92b34",
+		Number:     30001,
+		Nonce:      "0x318df1c8adef7e5e",
+		MixDigest:  "0x144b180aad09ae3c81fb07be92c8e6351b5646dda80e6844ae1b697e55ddde84",
+		Difficulty: "1532671",
+	}
+	reqs := []VerifyRequest{other, valid, wrongNonce, malformed, valid}
+	want := []bool{true, true, false, false, true}
+
+	body, _ := json.Marshal(reqs)
+	resp, err := http.Post(hs.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []VerifyResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(reqs) {
+		t.Fatalf("got %d responses for %d seals", len(res), len(reqs))
+	}
+	for i := range res {
+		if res[i].Valid != want[i] || (res[i].Error == "") != want[i] {
+			t.Errorf("seal %d: got %+v, want valid %v", i, res[i], want[i])
+		}
+	}
+
+	body, _ = json.Marshal(make([]VerifyRequest, maxVerifyBatch+1))
+	resp, err = http.Post(hs.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized batch answered with status %d", resp.StatusCode)
+	}
+
+	body = []byte(`{"headerHash":"` + strings.Repeat("0", maxVerifyRequestSize) + `"}`)
+	resp, err = http.Post(hs.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body answered with status %d", resp.StatusCode)
+	}
+}
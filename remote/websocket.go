@@ -0,0 +1,207 @@
+package remote
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// webSocketGUID is appended to the client's key to compute the accept
+// key of the handshake, see RFC 6455 section 1.3.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketFrame bounds the payload of frames read from miners,
+// which only send control frames.
+const maxWebSocketFrame = 4096
+
+// WebSocket opcodes.
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+var errWebSocketFrame = errors.New("invalid websocket frame")
+
+// Package returns the work package in the encoding of the ethash
+// package, which adds the block number to the eth_getWork fields.
+func (w Work) Package() ethash.WorkPackage {
+	return ethash.WorkPackage{HeaderHash: w.HeaderHash, SeedHash: w.SeedHash, Boundary: w.Target, BlockNumber: w.BlockNumber}
+}
+
+// isWebSocket reports whether r asks to upgrade to a websocket.
+func isWebSocket(r *http.Request) bool {
+	return r.Method == "GET" && headerHas(r.Header, "Connection", "upgrade") && headerHas(r.Header, "Upgrade", "websocket")
+}
+
+// headerHas reports whether the comma separated values of a header
+// include token, ignoring case.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveWebSocket upgrades the connection of r to a websocket and pushes
+// the current work package and every new one to it as a text message
+// with the JSON encoding of ethash.WorkPackage. Messages from the miner
+// are ignored, it submits solutions over eth_submitWork.
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket handshake", http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	rw.WriteString(base64.StdEncoding.EncodeToString(sum[:]))
+	rw.WriteString("\r\n\r\n")
+	if rw.Flush() != nil {
+		return
+	}
+	glog.V(logger.Debug).Infof("Websocket connection from %v", conn.RemoteAddr())
+
+	c := &wsConn{conn: conn}
+	closed := make(chan struct{})
+	go func() {
+		c.readLoop(rw.Reader)
+		close(closed)
+	}()
+	updates := s.subscribe()
+	defer s.unsubscribe(updates)
+	for {
+		select {
+		case <-closed:
+			return
+		case work := <-updates:
+			enc, _ := json.Marshal(work.Package())
+			if c.write(wsText, enc) != nil {
+				return
+			}
+		}
+	}
+}
+
+type wsConn struct {
+	conn net.Conn
+	mu   sync.Mutex // serializes writes
+}
+
+// write sends a single unmasked frame.
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	hdr := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = append(hdr, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = append(hdr, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(append(hdr, payload...))
+	return err
+}
+
+// readLoop reads the miner's frames until the connection is closed,
+// answering pings and close frames.
+func (c *wsConn) readLoop(r *bufio.Reader) {
+	for {
+		opcode, payload, err := readFrame(r)
+		if err != nil {
+			if err != io.EOF {
+				glog.V(logger.Debug).Infof("Websocket connection from %v: %v", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		switch opcode {
+		case wsPing:
+			if c.write(wsPong, payload) != nil {
+				return
+			}
+		case wsClose:
+			if len(payload) >= 2 {
+				payload = payload[:2] // echo the status code
+			}
+			c.write(wsClose, payload)
+			return
+		}
+	}
+}
+
+// readFrame reads a frame sent by a client, which must be masked, and
+// returns its opcode and unmasked payload.
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := hdr[0] & 0x0f
+	if hdr[1]&0x80 == 0 {
+		return 0, nil, errWebSocketFrame
+	}
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxWebSocketFrame {
+		return 0, nil, errWebSocketFrame
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,64 @@
+package ethash
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// seeds memoizes the seed hash chain. Seed hashes are computed by
+// repeated hashing, so without it every lookup for epoch n would cost
+// n keccak invocations.
+var seeds = struct {
+	mu     sync.Mutex
+	hashes []common.Hash          // seed hash by epoch
+	epochs map[common.Hash]uint64 // epoch by seed hash
+}{
+	hashes: []common.Hash{{}},
+	epochs: map[common.Hash]uint64{{}: 0},
+}
+
+// extendSeeds computes the seed hashes up to and including the given
+// epoch. seeds.mu must be held.
+func extendSeeds(epoch uint64) {
+	for uint64(len(seeds.hashes)) <= epoch {
+		next := crypto.Sha3Hash(seeds.hashes[len(seeds.hashes)-1][:])
+		seeds.epochs[next] = uint64(len(seeds.hashes))
+		seeds.hashes = append(seeds.hashes, next)
+	}
+}
+
+func makeSeedHash(epoch uint64) common.Hash {
+	seeds.mu.Lock()
+	defer seeds.mu.Unlock()
+	extendSeeds(epoch)
+	return seeds.hashes[epoch]
+}
+
+// seedEpoch returns the epoch of the given seed hash. Only epochs
+// below maxEpoch are considered.
+func seedEpoch(seedHash common.Hash) (uint64, bool) {
+	seeds.mu.Lock()
+	defer seeds.mu.Unlock()
+	if epoch, ok := seeds.epochs[seedHash]; ok {
+		return epoch, true
+	}
+	extendSeeds(maxEpoch - 1)
+	epoch, ok := seeds.epochs[seedHash]
+	return epoch, ok
+}
+
+// GetEpoch returns the epoch whose DAG and cache are derived from the
+// given seed hash.
+func GetEpoch(seedHash []byte) (uint64, error) {
+	if len(seedHash) != common.HashLength {
+		return 0, fmt.Errorf("invalid seed hash length %d", len(seedHash))
+	}
+	epoch, ok := seedEpoch(common.BytesToHash(seedHash))
+	if !ok {
+		return 0, fmt.Errorf("unknown seed hash %x", seedHash)
+	}
+	return epoch, nil
+}
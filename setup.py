@@ -31,7 +31,7 @@ depends = [
 pyethash = Extension('pyethash',
                      sources=sources,
                      depends=depends,
-                     extra_compile_args=["-Isrc/", "-std=gnu99", "-Wall"])
+                     extra_compile_args=["-Isrc/", "-std=gnu99", "-Wall", "-D_FILE_OFFSET_BITS=64"])
 
 setup(
     name='pyethash',
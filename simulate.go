@@ -0,0 +1,128 @@
+package ethash
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"time"
+)
+
+// RetargetFunc returns the difficulty of the block following a parent
+// with the given difficulty that was mined interval after its own
+// parent.
+type RetargetFunc func(number uint64, parent *big.Int, interval time.Duration) *big.Int
+
+// HomesteadRetarget is the difficulty adjustment of the Homestead
+// rules without the difficulty bomb, aiming at 10 to 20 seconds per
+// block.
+func HomesteadRetarget(number uint64, parent *big.Int, interval time.Duration) *big.Int {
+	adj := 1 - int64(interval/(10*time.Second))
+	if adj < -99 {
+		adj = -99
+	}
+	step := new(big.Int).Div(parent, big.NewInt(2048))
+	d := new(big.Int).Add(parent, step.Mul(step, big.NewInt(adj)))
+	if d.Cmp(big.NewInt(ProtocolMinimumDifficulty)) < 0 {
+		d.SetInt64(ProtocolMinimumDifficulty)
+	}
+	return d
+}
+
+// SimConfig describes a chain for Simulate.
+type SimConfig struct {
+	Start      uint64                        // number of the first block
+	Blocks     int                           // number of blocks to simulate
+	Difficulty *big.Int                      // of the first block
+	Hashrate   func(t time.Duration) float64 // hashes per second at time t since the first block
+	Retarget   RetargetFunc                  // nil keeps the difficulty constant
+
+	// Seed makes block intervals random, exponentially distributed
+	// around the expected time as in real mining. Zero uses the
+	// expected times, which makes runs reproducible without noise.
+	Seed int64
+}
+
+// SimBlock is a simulated block.
+type SimBlock struct {
+	Number     uint64
+	Epoch      uint64
+	Difficulty *big.Int
+	Interval   time.Duration // since the previous block
+	Time       time.Duration // since the first block
+}
+
+// Simulate estimates when the blocks of a chain are mined under the
+// given hashrate and difficulty rule, e.g. to plan when DAGs must be
+// ready, see SimEpochs.
+func Simulate(cfg SimConfig) []SimBlock {
+	var rnd *rand.Rand
+	if cfg.Seed != 0 {
+		rnd = rand.New(rand.NewSource(cfg.Seed))
+	}
+	blocks := make([]SimBlock, 0, cfg.Blocks)
+	var (
+		difficulty = new(big.Int).Set(cfg.Difficulty)
+		now        time.Duration
+		interval   time.Duration
+	)
+	for i := 0; i < cfg.Blocks; i++ {
+		number := cfg.Start + uint64(i)
+		if i > 0 && cfg.Retarget != nil {
+			difficulty = cfg.Retarget(number, difficulty, interval)
+		}
+		// the expected number of hashes for a seal is the difficulty.
+		hashes, _ := new(big.Float).SetInt(difficulty).Float64()
+		seconds := math.Inf(1)
+		if rate := cfg.Hashrate(now); rate > 0 {
+			seconds = hashes / rate
+		}
+		if rnd != nil {
+			seconds *= rnd.ExpFloat64()
+		}
+		if seconds > float64(math.MaxInt64)/float64(time.Second) {
+			interval = time.Duration(math.MaxInt64)
+		} else {
+			interval = time.Duration(seconds * float64(time.Second))
+		}
+		if i == 0 {
+			interval = 0
+		}
+		now += interval
+		blocks = append(blocks, SimBlock{
+			Number:     number,
+			Epoch:      number / epochLength,
+			Difficulty: difficulty,
+			Interval:   interval,
+			Time:       now,
+		})
+	}
+	return blocks
+}
+
+// SimEpoch is an epoch reached by simulated blocks.
+type SimEpoch struct {
+	Epoch       uint64
+	Start       time.Duration // time of its first simulated block
+	Duration    time.Duration // until the first block of the next epoch, zero for the last
+	DatasetSize uint64        // bytes of its DAG
+}
+
+// SimEpochs summarizes simulated blocks by epoch.
+func SimEpochs(blocks []SimBlock) []SimEpoch {
+	var epochs []SimEpoch
+	for _, b := range blocks {
+		if len(epochs) > 0 && epochs[len(epochs)-1].Epoch == b.Epoch {
+			continue
+		}
+		if len(epochs) > 0 {
+			last := &epochs[len(epochs)-1]
+			last.Duration = b.Time - last.Start
+		}
+		size := uint64(0)
+		if b.Epoch < maxEpoch {
+			size = datasetSize(b.Epoch, false)
+		}
+		epochs = append(epochs, SimEpoch{Epoch: b.Epoch, Start: b.Time, DatasetSize: size})
+	}
+	return epochs
+}
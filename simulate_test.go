@@ -0,0 +1,57 @@
+package ethash
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestSimulateConstant(t *testing.T) {
+	blocks := Simulate(SimConfig{
+		Start:      29998,
+		Blocks:     4,
+		Difficulty: big.NewInt(1000000),
+		Hashrate:   func(time.Duration) float64 { return 100000 },
+	})
+	if len(blocks) != 4 {
+		t.Fatalf("got %d blocks, want 4", len(blocks))
+	}
+	for i, b := range blocks {
+		want := 10 * time.Second
+		if i == 0 {
+			want = 0
+		}
+		if b.Interval != want || b.Time != time.Duration(i)*10*time.Second {
+			t.Errorf("block %d: interval %v at %v, want %v at %v", b.Number, b.Interval, b.Time, want, time.Duration(i)*10*time.Second)
+		}
+	}
+	epochs := SimEpochs(blocks)
+	if len(epochs) != 2 || epochs[0].Epoch != 0 || epochs[1].Epoch != 1 {
+		t.Fatalf("epochs %+v, want 0 and 1", epochs)
+	}
+	if epochs[0].Duration != 20*time.Second || epochs[1].Start != 20*time.Second || epochs[1].Duration != 0 {
+		t.Errorf("epochs %+v, want the change after 20s", epochs)
+	}
+	if epochs[1].DatasetSize != datasetSize(1, false) {
+		t.Errorf("dataset size %d, want %d", epochs[1].DatasetSize, datasetSize(1, false))
+	}
+}
+
+func TestSimulateRetarget(t *testing.T) {
+	// starting far too low, the difficulty must rise until blocks take
+	// 10 to 20 seconds.
+	blocks := Simulate(SimConfig{
+		Blocks:     20000,
+		Difficulty: big.NewInt(ProtocolMinimumDifficulty),
+		Hashrate:   func(time.Duration) float64 { return 1e6 },
+		Retarget:   HomesteadRetarget,
+		Seed:       1,
+	})
+	var total time.Duration
+	for _, b := range blocks[len(blocks)-1000:] {
+		total += b.Interval
+	}
+	if mean := total / 1000; mean < 8*time.Second || mean > 20*time.Second {
+		t.Errorf("mean interval %v after retargeting, want 10s to 20s", mean)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+  This file is part of ethash.
+
+  ethash is free software: you can redistribute it and/or modify
+  it under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  ethash is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with ethash.  If not, see <http://www.gnu.org/licenses/>.
+*/
+/** @file blake2b.c
+ * BLAKE2b-512 following the reference in RFC 7693
+ * @date 2015
+ */
+#include "blake2b.h"
+#include <stdbool.h>
+#include <string.h>
+
+static uint64_t const blake2b_iv[8] = {
+	0x6A09E667F3BCC908ULL, 0xBB67AE8584CAA73BULL,
+	0x3C6EF372FE94F82BULL, 0xA54FF53A5F1D36F1ULL,
+	0x510E527FADE682D1ULL, 0x9B05688C2B3E6C1FULL,
+	0x1F83D9ABFB41BD6BULL, 0x5BE0CD19137E2179ULL
+};
+
+static uint8_t const blake2b_sigma[12][16] = {
+	{ 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15 },
+	{ 14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3 },
+	{ 11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4 },
+	{ 7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8 },
+	{ 9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13 },
+	{ 2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9 },
+	{ 12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11 },
+	{ 13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10 },
+	{ 6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5 },
+	{ 10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0 },
+	{ 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15 },
+	{ 14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3 }
+};
+
+static inline uint64_t blake2b_rotr(uint64_t x, unsigned n)
+{
+	return (x >> n) | (x << (64 - n));
+}
+
+static inline uint64_t blake2b_load64(uint8_t const* p)
+{
+	uint64_t ret = 0;
+	for (unsigned i = 0; i != 8; ++i) {
+		ret |= (uint64_t)p[i] << (8 * i);
+	}
+	return ret;
+}
+
+#define BLAKE2B_G(a, b, c, d, x, y) do { \
+	v[a] = v[a] + v[b] + (x); \
+	v[d] = blake2b_rotr(v[d] ^ v[a], 32); \
+	v[c] = v[c] + v[d]; \
+	v[b] = blake2b_rotr(v[b] ^ v[c], 24); \
+	v[a] = v[a] + v[b] + (y); \
+	v[d] = blake2b_rotr(v[d] ^ v[a], 16); \
+	v[c] = v[c] + v[d]; \
+	v[b] = blake2b_rotr(v[b] ^ v[c], 63); \
+} while (0)
+
+static void blake2b_compress(uint64_t h[8], uint8_t const block[128], uint64_t counter, bool last)
+{
+	uint64_t v[16];
+	uint64_t m[16];
+	for (unsigned i = 0; i != 8; ++i) {
+		v[i] = h[i];
+		v[i + 8] = blake2b_iv[i];
+	}
+	v[12] ^= counter;
+	if (last) {
+		v[14] = ~v[14];
+	}
+	for (unsigned i = 0; i != 16; ++i) {
+		m[i] = blake2b_load64(block + 8 * i);
+	}
+	for (unsigned r = 0; r != 12; ++r) {
+		uint8_t const* s = blake2b_sigma[r];
+		BLAKE2B_G(0, 4, 8, 12, m[s[0]], m[s[1]]);
+		BLAKE2B_G(1, 5, 9, 13, m[s[2]], m[s[3]]);
+		BLAKE2B_G(2, 6, 10, 14, m[s[4]], m[s[5]]);
+		BLAKE2B_G(3, 7, 11, 15, m[s[6]], m[s[7]]);
+		BLAKE2B_G(0, 5, 10, 15, m[s[8]], m[s[9]]);
+		BLAKE2B_G(1, 6, 11, 12, m[s[10]], m[s[11]]);
+		BLAKE2B_G(2, 7, 8, 13, m[s[12]], m[s[13]]);
+		BLAKE2B_G(3, 4, 9, 14, m[s[14]], m[s[15]]);
+	}
+	for (unsigned i = 0; i != 8; ++i) {
+		h[i] ^= v[i] ^ v[i + 8];
+	}
+}
+
+#undef BLAKE2B_G
+
+void blake2b_512(uint8_t* ret, uint8_t const* data, size_t size)
+{
+	uint64_t h[8];
+	uint8_t block[128];
+	uint64_t counter = 0;
+	memcpy(h, blake2b_iv, sizeof(h));
+	// parameter block: 64 byte digest, no key, fanout and depth of 1
+	h[0] ^= 0x01010000ULL ^ 64;
+
+	while (size > 128) {
+		counter += 128;
+		blake2b_compress(h, data, counter, false);
+		data += 128;
+		size -= 128;
+	}
+	memset(block, 0, sizeof(block));
+	memcpy(block, data, size);
+	counter += size;
+	blake2b_compress(h, block, counter, true);
+
+	for (unsigned i = 0; i != 8; ++i) {
+		for (unsigned j = 0; j != 8; ++j) {
+			ret[8 * i + j] = (uint8_t)(h[i] >> (8 * j));
+		}
+	}
+}
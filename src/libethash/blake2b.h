@@ -0,0 +1,39 @@
+/*
+  This file is part of ethash.
+
+  ethash is free software: you can redistribute it and/or modify
+  it under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  ethash is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with ethash.  If not, see <http://www.gnu.org/licenses/>.
+*/
+/** @file blake2b.h
+ * BLAKE2b-512 (RFC 7693) for research builds which replace the hash of
+ * dataset items, see ETHASH_DATASET_BLAKE2B in internal.c
+ * @date 2015
+ */
+#pragma once
+
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/**
+ * Compute the unkeyed 64 byte BLAKE2b digest of data. ret and data may
+ * point to the same memory.
+ */
+void blake2b_512(uint8_t* ret, uint8_t const* data, size_t size);
+
+#ifdef __cplusplus
+}
+#endif
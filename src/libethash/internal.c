@@ -30,6 +30,16 @@
 #include "fnv.h"
 #include "endian.h"
 #include "internal.h"
+
+// Research builds can replace the Keccak-512 applied to dataset items
+// when they are computed, keeping all other parts of ethash. The cache
+// stays the same, DAG files get a different name.
+#ifdef ETHASH_DATASET_BLAKE2B
+#include "blake2b.h"
+#define ETHASH_DATASET_HASH(ret, data, size) blake2b_512(ret, data, size)
+#else
+#define ETHASH_DATASET_HASH(ret, data, size) SHA3_512(ret, data, size)
+#endif
 #include "data_sizes.h"
 #include "io.h"
 
@@ -101,7 +111,7 @@ void ethash_calculate_dag_item(
 	node const* init = &cache_nodes[node_index % num_parent_nodes];
 	memcpy(ret, init, sizeof(node));
 	ret->words[0] ^= node_index;
-	SHA3_512(ret->bytes, ret->bytes, sizeof(node));
+	ETHASH_DATASET_HASH(ret->bytes, ret->bytes, sizeof(node));
 #if defined(_M_X64) && ENABLE_SSE
 	__m128i const fnv_prime = _mm_set1_epi32(FNV_PRIME);
 	__m128i xmm0 = ret->xmm[0];
@@ -139,7 +149,7 @@ void ethash_calculate_dag_item(
 		}
 #endif
 	}
-	SHA3_512(ret->bytes, ret->bytes, sizeof(node));
+	ETHASH_DATASET_HASH(ret->bytes, ret->bytes, sizeof(node));
 }
 
 bool ethash_compute_full_data(
@@ -300,6 +310,9 @@ ethash_light_t ethash_light_new_internal(uint64_t cache_size, ethash_h256_t cons
 	if (!ret) {
 		return NULL;
 	}
+	if (cache_size > SIZE_MAX) {
+		goto fail_free_light;
+	}
 	ret->cache = malloc((size_t)cache_size);
 	if (!ret->cache) {
 		goto fail_free_light;
@@ -360,7 +373,9 @@ ethash_return_value_t ethash_light_compute(
 	return ethash_light_compute_internal(light, full_size, header_hash, nonce);
 }
 
-static bool ethash_mmap(struct ethash_full* ret, FILE* f)
+// complete DAG files are mapped read-only, so that several processes
+// can share them through the page cache, even from a read-only directory
+static bool ethash_mmap(struct ethash_full* ret, FILE* f, bool writable)
 {
 	int fd;
 	char* mmapped_data;
@@ -371,7 +386,7 @@ static bool ethash_mmap(struct ethash_full* ret, FILE* f)
 	mmapped_data= mmap(
 		NULL,
 		(size_t)ret->file_size + ETHASH_DAG_MAGIC_NUM_SIZE,
-		PROT_READ | PROT_WRITE,
+		writable ? PROT_READ | PROT_WRITE : PROT_READ,
 		MAP_SHARED,
 		fd,
 		0
@@ -397,23 +412,27 @@ ethash_full_t ethash_full_new_internal(
 	if (!ret) {
 		return NULL;
 	}
-	ret->file_size = (size_t)full_size;
-	switch (ethash_io_prepare(dirname, seed_hash, &f, (size_t)full_size, false)) {
+	// the whole file is mapped, which must fit into the address space
+	if (full_size > SIZE_MAX - ETHASH_DAG_MAGIC_NUM_SIZE) {
+		goto fail_free_full;
+	}
+	ret->file_size = full_size;
+	switch (ethash_io_prepare(dirname, seed_hash, &f, full_size, false)) {
 	case ETHASH_IO_FAIL:
 		goto fail_free_full;
 	case ETHASH_IO_MEMO_MATCH:
-		if (!ethash_mmap(ret, f)) {
+		if (!ethash_mmap(ret, f, false)) {
 			goto fail_close_file;
 		}
 		return ret;
 	case ETHASH_IO_MEMO_SIZE_MISMATCH:
 		// if a DAG of same filename but unexpected size is found, silently force new file creation
-		if (ethash_io_prepare(dirname, seed_hash, &f, (size_t)full_size, true) != ETHASH_IO_MEMO_MISMATCH) {
+		if (ethash_io_prepare(dirname, seed_hash, &f, full_size, true) != ETHASH_IO_MEMO_MISMATCH) {
 			goto fail_free_full;
 		}
 		// fallthrough to the mismatch case here, DO NOT go through match
 	case ETHASH_IO_MEMO_MISMATCH:
-		if (!ethash_mmap(ret, f)) {
+		if (!ethash_mmap(ret, f, true)) {
 			goto fail_close_file;
 		}
 		break;
@@ -436,7 +455,7 @@ ethash_full_t ethash_full_new_internal(
 
 fail_free_full_data:
 	// could check that munmap(..) == 0 but even if it did not can't really do anything here
-	munmap(ret->data, (size_t)full_size);
+	munmap((char*)ret->data - ETHASH_DAG_MAGIC_NUM_SIZE, (size_t)full_size + ETHASH_DAG_MAGIC_NUM_SIZE);
 fail_close_file:
 	fclose(ret->file);
 fail_free_full:
@@ -458,7 +477,8 @@ ethash_full_t ethash_full_new(ethash_light_t light, ethash_callback_t callback)
 void ethash_full_delete(ethash_full_t full)
 {
 	// could check that munmap(..) == 0 but even if it did not can't really do anything here
-	munmap(full->data, (size_t)full->file_size);
+	// data points past the magic number, the mapping starts at the beginning of the file
+	munmap((char*)full->data - ETHASH_DAG_MAGIC_NUM_SIZE, (size_t)full->file_size + ETHASH_DAG_MAGIC_NUM_SIZE);
 	if (full->file) {
 		fclose(full->file);
 	}
@@ -47,9 +47,9 @@ enum ethash_io_rc ethash_io_prepare(
 	FILE *f;
 	if (!force_create) {
 		// try to open the file
-		f = ethash_fopen(tmpfile, "rb+");
+		f = ethash_fopen(tmpfile, "rb");
 		if (f) {
-			size_t found_size;
+			uint64_t found_size;
 			if (!ethash_file_size(f, &found_size)) {
 				fclose(f);
 				goto free_memo;
@@ -83,7 +83,7 @@ enum ethash_io_rc ethash_io_prepare(
 		goto free_memo;
 	}
 	// make sure it's of the proper size
-	if (fseek(f, (long int)(file_size + ETHASH_DAG_MAGIC_NUM_SIZE - 1), SEEK_SET) != 0) {
+	if (!ethash_fseek(f, file_size + ETHASH_DAG_MAGIC_NUM_SIZE - 1)) {
 		fclose(f);
 		goto free_memo;
 	}
@@ -33,13 +33,22 @@
 #ifdef __cplusplus
 extern "C" {
 #endif
+// Research builds with a different dataset hash mark their DAG files,
+// see ETHASH_DATASET_BLAKE2B in internal.c
+#ifdef ETHASH_DATASET_BLAKE2B
+#define ETHASH_DAG_NAME_SUFFIX "-blake2b"
+#else
+#define ETHASH_DAG_NAME_SUFFIX ""
+#endif
+
 // Maximum size for mutable part of DAG file name
 // 6 is for "full-R", the suffix of the filename
 // 10 is for maximum number of digits of a uint32_t (for REVISION)
 // 1 is for - and 16 is for the first 16 hex digits for first 8 bytes of
-// the seedhash and last 1 is for the null terminating character
+// the seedhash, the rest is for the name suffix of research builds and
+// the null terminating character
 // Reference: https://github.com/ethereum/wiki/wiki/Ethash-DAG
-#define DAG_MUTABLE_NAME_MAX_SIZE (6 + 10 + 1 + 16 + 1)
+#define DAG_MUTABLE_NAME_MAX_SIZE (6 + 10 + 1 + 16 + sizeof(ETHASH_DAG_NAME_SUFFIX))
 /// Possible return values of @see ethash_io_prepare
 enum ethash_io_rc {
 	ETHASH_IO_FAIL = 0,           ///< There has been an IO failure
@@ -127,10 +136,20 @@ bool ethash_mkdir(char const* dirname);
  * Get a file's size
  *
  * @param[in] f        The open file stream whose size to get
- * @param[out] size    Pass a size_t by reference to contain the file size
+ * @param[out] size    Pass a uint64_t by reference to contain the file size
+ * @return             true in success and false if there was a failure
+ */
+bool ethash_file_size(FILE* f, uint64_t* ret_size);
+
+/**
+ * Set the position of a file stream, also beyond 2GB on platforms
+ * where long has 32 bits
+ *
+ * @param f            The file stream whose position to set
+ * @param offset       The offset from the beginning of the file
  * @return             true in success and false if there was a failure
  */
-bool ethash_file_size(FILE* f, size_t* ret_size);
+bool ethash_fseek(FILE* f, uint64_t offset);
 
 /**
  * Get a file descriptor number from a FILE stream
@@ -177,7 +196,7 @@ static inline bool ethash_io_mutable_name(
 #if LITTLE_ENDIAN == BYTE_ORDER
     hash = ethash_swap_u64(hash);
 #endif
-    return snprintf(output, DAG_MUTABLE_NAME_MAX_SIZE, "full-R%u-%016" PRIx64, revision, hash) >= 0;
+    return snprintf(output, DAG_MUTABLE_NAME_MAX_SIZE, "full-R%u-%016" PRIx64 ETHASH_DAG_NAME_SUFFIX, revision, hash) >= 0;
 }
 
 #ifdef __cplusplus
@@ -73,17 +73,26 @@ char* ethash_io_create_filename(
 	return name;
 }
 
-bool ethash_file_size(FILE* f, size_t* ret_size)
+bool ethash_file_size(FILE* f, uint64_t* ret_size)
 {
 	struct stat st;
 	int fd;
 	if ((fd = fileno(f)) == -1 || fstat(fd, &st) != 0) {
 		return false;
 	}
-	*ret_size = st.st_size;
+	*ret_size = (uint64_t)st.st_size;
 	return true;
 }
 
+bool ethash_fseek(FILE* f, uint64_t offset)
+{
+	off_t const off = (off_t)offset;
+	if (off < 0 || (uint64_t)off != offset) {
+		return false;
+	}
+	return fseeko(f, off, SEEK_SET) == 0;
+}
+
 bool ethash_get_default_dirname(char* strbuf, size_t buffsize)
 {
 	static const char dir_suffix[] = ".ethash/";
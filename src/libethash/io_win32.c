@@ -23,25 +23,67 @@
 #include <direct.h>
 #include <errno.h>
 #include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <wchar.h>
 #include <sys/stat.h>
 #include <sys/types.h>
 #include <shlobj.h>
 
+// file names are UTF-8, as passed by Go. The narrow CRT functions would
+// interpret them in the ANSI code page and fail for non-ASCII paths.
+static wchar_t* ethash_widen(char const* str)
+{
+	int n = MultiByteToWideChar(CP_UTF8, 0, str, -1, NULL, 0);
+	if (n <= 0) {
+		return NULL;
+	}
+	wchar_t* ret = malloc(n * sizeof(wchar_t));
+	if (!ret) {
+		return NULL;
+	}
+	if (MultiByteToWideChar(CP_UTF8, 0, str, -1, ret, n) != n) {
+		free(ret);
+		return NULL;
+	}
+	return ret;
+}
+
 FILE* ethash_fopen(char const* file_name, char const* mode)
 {
-	FILE* f;
-	return fopen_s(&f, file_name, mode) == 0 ? f : NULL;
+	FILE* f = NULL;
+	wchar_t* wname = ethash_widen(file_name);
+	wchar_t* wmode = ethash_widen(mode);
+	if (wname && wmode) {
+		f = _wfopen(wname, wmode);
+	}
+	free(wname);
+	free(wmode);
+	return f;
 }
 
 char* ethash_strncat(char* dest, size_t dest_size, char const* src, size_t count)
 {
-	return strncat_s(dest, dest_size, src, count) == 0 ? dest : NULL;
+	size_t len = strlen(dest);
+	size_t n = strnlen(src, count);
+	if (len + n >= dest_size) {
+		return NULL;
+	}
+	memcpy(dest + len, src, n);
+	dest[len + n] = '\0';
+	return dest;
 }
 
 bool ethash_mkdir(char const* dirname)
 {
-	int rc = _mkdir(dirname);
-	return rc != -1 || errno == EEXIST;
+	wchar_t* wdirname = ethash_widen(dirname);
+	if (!wdirname) {
+		return false;
+	}
+	int rc = _wmkdir(wdirname);
+	bool ret = rc != -1 || errno == EEXIST;
+	free(wdirname);
+	return ret;
 }
 
 int ethash_fileno(FILE* f)
@@ -57,7 +99,8 @@ char* ethash_io_create_filename(
 {
 	size_t dirlen = strlen(dirname);
 	size_t dest_size = dirlen + filename_length + 1;
-	if (dirname[dirlen] != '\\' || dirname[dirlen] != '/') {
+	bool const has_sep = dirlen > 0 && (dirname[dirlen - 1] == '\\' || dirname[dirlen - 1] == '/');
+	if (!has_sep) {
 		dest_size += 1;
 	}
 	char* name = malloc(dest_size);
@@ -67,24 +110,32 @@ char* ethash_io_create_filename(
 
 	name[0] = '\0';
 	ethash_strncat(name, dest_size, dirname, dirlen);
-	if (dirname[dirlen] != '\\' || dirname[dirlen] != '/') {
+	if (!has_sep) {
 		ethash_strncat(name, dest_size, "\\", 1);
 	}
 	ethash_strncat(name, dest_size, filename, filename_length);
 	return name;
 }
 
-bool ethash_file_size(FILE* f, size_t* ret_size)
+bool ethash_file_size(FILE* f, uint64_t* ret_size)
 {
-	struct _stat st;
+	struct __stat64 st;
 	int fd;
-	if ((fd = _fileno(f)) == -1 || _fstat(fd, &st) != 0) {
+	if ((fd = _fileno(f)) == -1 || _fstat64(fd, &st) != 0) {
 		return false;
 	}
-	*ret_size = st.st_size;
+	*ret_size = (uint64_t)st.st_size;
 	return true;
 }
 
+bool ethash_fseek(FILE* f, uint64_t offset)
+{
+	if (offset > INT64_MAX) {
+		return false;
+	}
+	return _fseeki64(f, (__int64)offset, SEEK_SET) == 0;
+}
+
 bool ethash_get_default_dirname(char* strbuf, size_t buffsize)
 {
 	static const char dir_suffix[] = "Appdata\\Ethash\\";
@@ -12,16 +12,13 @@
  */
 
 #include <io.h>
+#include <stdint.h>
 #include <windows.h>
 #include "mmap.h"
 
-#ifdef __USE_FILE_OFFSET64
-# define DWORD_HI(x) (x >> 32)
-# define DWORD_LO(x) ((x) & 0xffffffff)
-#else
-# define DWORD_HI(x) (0)
-# define DWORD_LO(x) (x)
-#endif
+// sizes and offsets are split into DWORDs as 64 bit values, DAGs are larger than 4GB
+#define DWORD_HI(x) ((DWORD)((uint64_t)(x) >> 32))
+#define DWORD_LO(x) ((DWORD)((uint64_t)(x) & 0xffffffff))
 
 void* mmap(void* start, size_t length, int prot, int flags, int fd, off_t offset)
 {
@@ -47,7 +44,7 @@ void* mmap(void* start, size_t length, int prot, int flags, int fd, off_t offset
 	} else
 		flProtect = PAGE_READONLY;
 
-	off_t end = length + offset;
+	uint64_t const end = (uint64_t)length + (uint64_t)offset;
 	HANDLE mmap_fd, h;
 	if (fd == -1)
 		mmap_fd = INVALID_HANDLE_VALUE;
@@ -0,0 +1,90 @@
+/*
+  This file is part of ethash.
+
+  ethash is free software: you can redistribute it and/or modify
+  it under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  ethash is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with ethash.  If not, see <http://www.gnu.org/licenses/>.
+*/
+/** @file reference.c
+ * Computes seals with the light client API for the cross-check of the
+ * Go bindings. Every input line holds a block number, a header hash
+ * and a nonce, all in hex, and is answered by a line with the mix
+ * digest and result in hex. Only the API of ethash.h is used, so the
+ * tool can be built against other releases of libethash as well.
+ */
+
+#include <stdio.h>
+#include <stdint.h>
+#include <inttypes.h>
+#include <libethash/ethash.h>
+
+static int parse_hash(char const* str, ethash_h256_t* hash)
+{
+	for (unsigned i = 0; i < 32; i++) {
+		unsigned byte;
+		if (sscanf(str + 2 * i, "%2x", &byte) != 1) {
+			return 0;
+		}
+		hash->b[i] = (uint8_t)byte;
+	}
+	return 1;
+}
+
+static void print_hash(ethash_h256_t const* hash)
+{
+	for (unsigned i = 0; i < 32; i++) {
+		printf("%02x", hash->b[i]);
+	}
+}
+
+int main(void)
+{
+	char line[256];
+	char header_hex[65];
+	ethash_light_t light = NULL;
+	uint64_t light_epoch = 0;
+	while (fgets(line, sizeof(line), stdin)) {
+		uint64_t block_number, nonce;
+		ethash_h256_t header_hash;
+		if (sscanf(line, "%" SCNx64 " %64s %" SCNx64, &block_number, header_hex, &nonce) != 3 ||
+			!parse_hash(header_hex, &header_hash)) {
+			fprintf(stderr, "invalid input: %s", line);
+			return 1;
+		}
+		// caches are generated once per epoch, the inputs are sorted by it.
+		if (!light || block_number / ETHASH_EPOCH_LENGTH != light_epoch) {
+			if (light) {
+				ethash_light_delete(light);
+			}
+			light = ethash_light_new(block_number);
+			light_epoch = block_number / ETHASH_EPOCH_LENGTH;
+			if (!light) {
+				fprintf(stderr, "can't create cache for block %" PRIu64 "\n", block_number);
+				return 1;
+			}
+		}
+		ethash_return_value_t ret = ethash_light_compute(light, header_hash, nonce);
+		if (!ret.success) {
+			fprintf(stderr, "can't compute block %" PRIu64 "\n", block_number);
+			return 1;
+		}
+		print_hash(&ret.mix_hash);
+		printf(" ");
+		print_hash(&ret.result);
+		printf("\n");
+		fflush(stdout);
+	}
+	if (light) {
+		ethash_light_delete(light);
+	}
+	return 0;
+}
@@ -3,6 +3,7 @@
 #include <libethash/ethash.h>
 #include <libethash/internal.h>
 #include <libethash/io.h>
+#include <libethash/blake2b.h>
 
 #ifdef WITH_CRYPTOPP
 
@@ -140,6 +141,18 @@ BOOST_AUTO_TEST_CASE(SHA512_check) {
 					<< "actual: " << actual.c_str() << "\n");
 }
 
+BOOST_AUTO_TEST_CASE(blake2b_512_check) {
+	uint8_t input[64], out[64];
+	memcpy(input, "~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~", 64);
+	blake2b_512(out, input, 64);
+	const std::string
+			expected = "bbf1c4c774e48aec17401caaa7e1ad61687a642544da893f87c0648f398e67be576402b0899f7141be6e1fc9f64b3eb1c92b457b53ceae53bb7fe7eccb71c92d",
+			actual = bytesToHexString(out, 64);
+	BOOST_REQUIRE_MESSAGE(expected == actual,
+			"\nexpected: " << expected.c_str() << "\n"
+					<< "actual: " << actual.c_str() << "\n");
+}
+
 BOOST_AUTO_TEST_CASE(test_swap_endian32) {
 	uint32_t v32 = (uint32_t)0xBAADF00D;
 	v32 = ethash_swap_u32(v32);
@@ -290,6 +303,26 @@ BOOST_AUTO_TEST_CASE(test_ethash_io_memo_file_size_mismatch) {
 	fs::remove_all("./test_ethash_directory/");
 }
 
+BOOST_AUTO_TEST_CASE(test_ethash_io_memo_file_beyond_4gb) {
+	static const int blockn = 0;
+	// sizes beyond 4GB must not be truncated anywhere, the file is sparse
+	static const uint64_t full_size = (5ULL << 30) + 128;
+	ethash_h256_t seedhash = ethash_get_seedhash(blockn);
+	FILE *f = NULL;
+	BOOST_REQUIRE_EQUAL(
+		ETHASH_IO_MEMO_MISMATCH,
+		ethash_io_prepare("./test_ethash_directory/", seedhash, &f, full_size, false)
+	);
+	BOOST_REQUIRE(f);
+	uint64_t found_size;
+	BOOST_REQUIRE(ethash_file_size(f, &found_size));
+	BOOST_REQUIRE_EQUAL(full_size + ETHASH_DAG_MAGIC_NUM_SIZE, found_size);
+	fclose(f);
+
+	// cleanup
+	fs::remove_all("./test_ethash_directory/");
+}
+
 BOOST_AUTO_TEST_CASE(test_ethash_get_default_dirname) {
 	char result[256];
 	// this is really not an easy thing to test for in a unit test, so yeah it does look ugly
@@ -23,6 +23,7 @@ mkdir -p $TEST_DIR/build
 cd $TEST_DIR/build ; 
 cmake ../../.. > /dev/null 
 make Test 
+make Reference 
 ./test/c/Test
 
 # If we have valgrind also run memory check tests
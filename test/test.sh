@@ -29,4 +29,8 @@ $TEST_DIR/c/test.sh
 #$TEST_DIR/python/test.sh
 
 echo "################# Testing Go ##################"
+# cross-check against the reference tool built by the C tests
+if [ -x $TEST_DIR/c/build/test/c/Reference ] ; then
+	export ETHASH_REFERENCE=$TEST_DIR/c/build/test/c/Reference
+fi
 cd $TEST_DIR/.. && go test -timeout 9999s
@@ -0,0 +1,100 @@
+package ethash
+
+/*
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// GenerationLimits bounds the resources used by DAGs generated in the
+// background, so that pre-generation does not starve the node it runs
+// in. Zero values mean no limit. A DAG that a search is waiting for is
+// always generated at full speed.
+type GenerationLimits struct {
+	CPUShare  float64 // fraction of one core, between 0 and 1
+	WriteRate uint64  // bytes written per second
+}
+
+// SetGenerationLimits sets the limits for DAGs pre-generated after the
+// call.
+func (pow *Full) SetGenerationLimits(limits GenerationLimits) {
+	pow.mu.Lock()
+	pow.genLimits = limits
+	pow.mu.Unlock()
+}
+
+// delay returns how long to pause after a step of the generation that
+// wrote n bytes in elapsed time.
+func (limits GenerationLimits) delay(n uint64, elapsed time.Duration) time.Duration {
+	var wait time.Duration
+	if share := limits.CPUShare; share > 0 && share < 1 {
+		wait = time.Duration(float64(elapsed) * (1 - share) / share)
+	}
+	if rate := limits.WriteRate; rate > 0 {
+		if w := time.Duration(float64(n)/float64(rate)*float64(time.Second)) - elapsed; w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// throttle is called whenever another percent of the DAG has been
+// generated.
+func (d *dag) throttle() {
+	clock := orSystem(d.clock)
+	now := clock.Now()
+	if !d.lastStep.IsZero() && atomic.LoadInt32(&d.urgent) == 0 {
+		if wait := d.limits.delay(datasetSize(d.epoch, d.test)/100, now.Sub(d.lastStep)); wait > 0 {
+			clock.Sleep(wait)
+			now = clock.Now()
+		}
+	}
+	d.lastStep = now
+}
+
+// handles maps the values passed through C to the DAGs being
+// generated, as C code must not keep Go pointers.
+type handles struct {
+	mu   sync.Mutex
+	next uintptr
+	dags map[uintptr]*dag
+}
+
+var progress = &handles{dags: make(map[uintptr]*dag)}
+
+func (h *handles) add(d *dag) uintptr {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.next++
+	h.dags[h.next] = d
+	return h.next
+}
+
+func (h *handles) remove(handle uintptr) {
+	h.mu.Lock()
+	delete(h.dags, handle)
+	h.mu.Unlock()
+}
+
+func (h *handles) get(handle uintptr) *dag {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dags[handle]
+}
+
+//export ethashGoCallback
+func ethashGoCallback(handle C.uintptr_t, percent C.unsigned) C.int {
+	glog.V(logger.Info).Infof("Still generating DAG: %d%%", percent)
+	if d := progress.get(uintptr(handle)); d != nil {
+		d.throttle()
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package ethash
+
+import (
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGenerationLimitsDelay(t *testing.T) {
+	tests := []struct {
+		limits  GenerationLimits
+		n       uint64
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{GenerationLimits{}, 1000, time.Second, 0},
+		{GenerationLimits{CPUShare: 1}, 1000, time.Second, 0},
+		{GenerationLimits{CPUShare: 0.25}, 1000, time.Second, 3 * time.Second},
+		{GenerationLimits{WriteRate: 100}, 1000, time.Second, 9 * time.Second},
+		{GenerationLimits{WriteRate: 10000}, 1000, time.Second, 0},
+		{GenerationLimits{CPUShare: 0.5, WriteRate: 100}, 1000, time.Second, 9 * time.Second},
+		{GenerationLimits{CPUShare: 0.1, WriteRate: 100}, 1000, time.Second, 9 * time.Second},
+	}
+	for i, test := range tests {
+		if got := test.limits.delay(test.n, test.elapsed); got != test.want {
+			t.Errorf("test %d: got %v, want %v", i, got, test.want)
+		}
+	}
+}
+
+func TestThrottledPregeneration(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	// at this rate, the test DAG takes about a second to write.
+	eth.SetGenerationLimits(GenerationLimits{WriteRate: uint64(dagSizeForTesting)})
+	eth.EnableAutoDAG(1)
+	block := &testBlock{difficulty: big.NewInt(10)}
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if dagFileComplete(eth.Full.Dir, 1, true) {
+		t.Fatal("throttled DAG generation finished early")
+	}
+
+	// searching on the next epoch lifts the throttle.
+	start := time.Now()
+	block = &testBlock{number: epochLength, difficulty: big.NewInt(10)}
+	if _, mix := eth.Search(block, nil); mix == nil {
+		t.Fatal("search failed on pre-generated epoch")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("search waited %v for throttled DAG", d)
+	}
+}
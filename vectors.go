@@ -0,0 +1,64 @@
+package ethash
+
+import "github.com/ethereum/go-ethereum/common"
+
+// TestVector is a known-good seal computation: hashing HeaderHash and
+// Nonce with the cache or DAG of the epoch of SeedHash must yield
+// MixDigest and Result.
+type TestVector struct {
+	Epoch      uint64
+	SeedHash   common.Hash
+	HeaderHash common.Hash
+	Nonce      uint64
+	MixDigest  common.Hash
+	Result     common.Hash
+}
+
+// testVectors are the seals of epoch 0. The first one is block 22 of
+// the proof of concept nine testnet, whose result is also checked by
+// the C tests. The others were computed with the C implementation of
+// revision 23. The seed hash of epoch 0 is all zeros.
+var testVectors = []TestVector{
+	{
+		HeaderHash: common.HexToHash("0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d"),
+		Nonce:      0x495732e0ed7a801c,
+		MixDigest:  common.HexToHash("0x2f74cdeb198af0b9abe65d22d372e22fb2d474371774a9583c1cc427a07939f5"),
+		Result:     common.HexToHash("0x00000b184f1fdd88bfd94c86c39e65db0c36144d5e43f745f722196e730cb614"),
+	},
+	{
+		HeaderHash: common.HexToHash("0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d"),
+		Nonce:      0,
+		MixDigest:  common.HexToHash("0xc1a89f0ee0d7c425013babb5ecce32bfe4963b644294173eaa835e7db5075958"),
+		Result:     common.HexToHash("0xc6dd842f3e2d2174bf6433bac5d0b49564c237fe73d2ba8b16dc8d4df0c87c1e"),
+	},
+	{
+		HeaderHash: common.HexToHash("0x372eca2454ead349c3df0ab5d00b0b706b23e49d469387db91811cee0358fc6d"),
+		Nonce:      0xffffffffffffffff,
+		MixDigest:  common.HexToHash("0x7ced529be53fdb7e40d1bd4383e0e2749447c163619206d7140fd65a00f65968"),
+		Result:     common.HexToHash("0x6bf4312373871c2cd051e9df7bd3a631d026849264b0b37e1e201fc06dd50887"),
+	},
+	{
+		Nonce:     0,
+		MixDigest: common.HexToHash("0xc763d8572dec8e75534d2007e265fa95f21be2912fa0625842683ef4329f9021"),
+		Result:    common.HexToHash("0x66168636ccf123558a858e585bf81400de28947be61d503c311dbb9d09703eed"),
+	},
+	{
+		Nonce:     0x495732e0ed7a801c,
+		MixDigest: common.HexToHash("0x56aba5187681269fbebf386cf2b065517d415e1d20d97dc2cf402f04f9f656f9"),
+		Result:    common.HexToHash("0x869018f4f4c42e0b487561026aab23cb2397541951d47376329d756676283c41"),
+	},
+	{
+		Nonce:     0xffffffffffffffff,
+		MixDigest: common.HexToHash("0x41db59be3fdb2865925f35e026aa7e44fb4b85ab81622eee6da8be7d288fb263"),
+		Result:    common.HexToHash("0x7c140912d91b22e906714902605a5ba771be7dac949d615ca67b70ee44a69912"),
+	},
+}
+
+// TestVectors returns known-good seals for validating changes to the
+// hashing code, e.g. optimized or alternative implementations. The
+// slice is a copy and may be modified.
+func TestVectors() []TestVector {
+	vectors := make([]TestVector, len(testVectors))
+	copy(vectors, testVectors)
+	return vectors
+}
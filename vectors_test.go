@@ -0,0 +1,94 @@
+package ethash
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethereum/ethash/primitives"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+)
+
+func TestTestVectors(t *testing.T) {
+	vectors := TestVectors()
+	if len(vectors) == 0 {
+		t.Fatal("no test vectors")
+	}
+	cache, err := NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vectors {
+		if v.SeedHash != makeSeedHash(v.Epoch) {
+			t.Errorf("vector %d: seed hash %x does not belong to epoch %d", i, v.SeedHash, v.Epoch)
+		}
+		if v.Epoch != cache.Epoch() {
+			t.Errorf("vector %d: no cache for epoch %d", i, v.Epoch)
+			continue
+		}
+		if mix, result := cache.Hash(v.HeaderHash, v.Nonce); mix != v.MixDigest || result != v.Result {
+			t.Errorf("vector %d: computed %x, %x, want %x, %x", i, mix, result, v.MixDigest, v.Result)
+		}
+	}
+}
+
+// TestPrimitivesMatchC recomputes the seals of the test vectors with
+// package primitives over dataset items of the C code, which checks
+// Fnv, FnvHash and ReduceMix against fnv_hash and the mix reduction of
+// ethash_hash.
+func TestPrimitivesMatchC(t *testing.T) {
+	cache, err := NewCache(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range TestVectors() {
+		if v.Epoch != cache.Epoch() {
+			continue
+		}
+		if mix, result := primitivesHash(cache, v.HeaderHash, v.Nonce); mix != v.MixDigest || result != v.Result {
+			t.Errorf("vector %d: computed %x, %x, want %x, %x", i, mix, result, v.MixDigest, v.Result)
+		}
+	}
+}
+
+// primitivesHash is ethash_hash of the C code written with package
+// primitives, taking the dataset items from cache.
+func primitivesHash(cache *Cache, headerHash common.Hash, nonce uint64) (mixDigest, result common.Hash) {
+	const (
+		nodeWords = dagItemSize / 4
+		mixNodes  = 2
+		accesses  = 64
+	)
+	var in [40]byte
+	copy(in[:], headerHash[:])
+	binary.LittleEndian.PutUint64(in[32:], nonce)
+	h := sha3.NewKeccak512()
+	h.Write(in[:])
+	seed := h.Sum(nil)
+
+	seedWords := make([]uint32, nodeWords)
+	for i := range seedWords {
+		seedWords[i] = binary.LittleEndian.Uint32(seed[4*i:])
+	}
+	mix := make([]uint32, mixNodes*nodeWords)
+	for i := range mix {
+		mix[i] = seedWords[i%nodeWords]
+	}
+	pages := uint32(datasetSize(cache.Epoch(), false) / (mixNodes * dagItemSize))
+	item := make([]uint32, nodeWords)
+	for i := uint32(0); i < accesses; i++ {
+		page := primitives.Fnv(seedWords[0]^i, mix[i%uint32(len(mix))]) % pages
+		for n := uint32(0); n < mixNodes; n++ {
+			data := cache.DatasetItem(page*mixNodes + n)
+			for w := range item {
+				item[w] = binary.LittleEndian.Uint32(data[4*w:])
+			}
+			primitives.FnvHash(mix[n*nodeWords:(n+1)*nodeWords], item)
+		}
+	}
+	for i, w := range primitives.ReduceMix(mix) {
+		binary.LittleEndian.PutUint32(mixDigest[4*i:], w)
+	}
+	return mixDigest, crypto.Sha3Hash(seed, mixDigest[:])
+}
@@ -0,0 +1,181 @@
+package ethash
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+// VerifyResult is the outcome of verifying one block of a batch
+// submitted to a Verifier.
+type VerifyResult struct {
+	Index   int // position of the block in the submitted batch
+	Block   pow.Block
+	Valid   bool
+	Skipped bool // not verified because the block wasn't sampled, Valid is set
+}
+
+// Batches of increasing block numbers pre-generate the cache of the
+// next epoch when they get within prewarmBatches batches of the same
+// span, or minPrewarmDistance blocks, of its first block.
+const (
+	prewarmBatches     = 4
+	minPrewarmDistance = 1024
+)
+
+type verifyJob struct {
+	index int
+	block pow.Block
+	done  func(VerifyResult)
+}
+
+// Verifier checks block nonces on a fixed pool of worker goroutines.
+// It is intended for the block importer, which can feed it headers
+// faster than a single goroutine can verify them.
+//
+// While the batches submitted have increasing block numbers, as during
+// sync, the cache of the next epoch is generated in the background as
+// they approach it, so that verification doesn't stall at the epoch
+// boundary.
+type Verifier struct {
+	light *Light
+	queue chan verifyJob
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	last    uint64 // highest block number of the previous batch
+	hasLast bool
+}
+
+// NewVerifier starts a Verifier that uses the caches of light. If
+// workers is zero or negative, one worker per CPU is started.
+func NewVerifier(light *Light, workers int) *Verifier {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	v := &Verifier{light: light, queue: make(chan verifyJob, 4*workers)}
+	v.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go v.loop()
+	}
+	return v
+}
+
+func (v *Verifier) loop() {
+	defer v.wg.Done()
+	for job := range v.queue {
+		job.done(VerifyResult{Index: job.index, Block: job.block, Valid: v.light.Verify(job.block)})
+	}
+}
+
+// observe pre-warms the cache of the next epoch if blocks continue a
+// sequence of increasing block numbers close to the epoch's start.
+func (v *Verifier) observe(blocks []pow.Block) {
+	if len(blocks) == 0 {
+		return
+	}
+	first, last := blocks[0].NumberU64(), blocks[len(blocks)-1].NumberU64()
+	v.mu.Lock()
+	increasing := !v.hasLast || first > v.last
+	v.last, v.hasLast = last, true
+	v.mu.Unlock()
+	for i := 1; increasing && i < len(blocks); i++ {
+		increasing = blocks[i].NumberU64() > blocks[i-1].NumberU64()
+	}
+	if !increasing {
+		return
+	}
+	distance := prewarmBatches * (last - first + 1)
+	if distance < minPrewarmDistance {
+		distance = minPrewarmDistance
+	}
+	next := last/epochLength + 1
+	if next*epochLength-last <= distance {
+		v.light.prewarm(next)
+	}
+}
+
+// Submit queues blocks for verification. It returns a channel on
+// which one result per block is delivered in completion order. The
+// channel is closed once all blocks of the batch have been verified.
+//
+// Submit blocks while the queue is full. It must not be called after
+// Close.
+func (v *Verifier) Submit(blocks []pow.Block) <-chan VerifyResult {
+	var (
+		results = make(chan VerifyResult, len(blocks))
+		pending = new(sync.WaitGroup)
+	)
+	pending.Add(len(blocks))
+	done := func(res VerifyResult) {
+		results <- res
+		pending.Done()
+	}
+	v.observe(blocks)
+	for i, block := range blocks {
+		v.queue <- verifyJob{i, block, done}
+	}
+	go func() {
+		pending.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// SubmitSampled is like Submit, but only verifies a random sample of
+// the blocks, as done during fast sync. The batch is split into
+// segments of n blocks. One randomly chosen block of each segment is
+// verified, except for the last, most recent segment, which is
+// verified completely. The other blocks are reported as valid with
+// Skipped set. If n is one or less, all blocks are verified.
+func (v *Verifier) SubmitSampled(blocks []pow.Block, n int) <-chan VerifyResult {
+	if n <= 1 {
+		return v.Submit(blocks)
+	}
+	var (
+		results = make(chan VerifyResult, len(blocks))
+		pending = new(sync.WaitGroup)
+		last    = (len(blocks) - 1) / n * n // start of the last segment
+	)
+	done := func(res VerifyResult) {
+		results <- res
+		pending.Done()
+	}
+	pending.Add(len(blocks))
+	v.observe(blocks)
+	for start := 0; start < len(blocks); start += n {
+		check := start + rand.Intn(n)
+		for i := start; i < start+n && i < len(blocks); i++ {
+			if i == check || start == last {
+				v.queue <- verifyJob{i, blocks[i], done}
+			} else {
+				done(VerifyResult{Index: i, Block: blocks[i], Valid: true, Skipped: true})
+			}
+		}
+	}
+	go func() {
+		pending.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// VerifyAsync queues a single block for verification and returns a
+// channel that receives the result. This allows the caller to go on
+// processing the block while its nonce is checked.
+//
+// VerifyAsync blocks while the queue is full. It must not be called
+// after Close.
+func (v *Verifier) VerifyAsync(block pow.Block) <-chan bool {
+	result := make(chan bool, 1)
+	v.queue <- verifyJob{0, block, func(res VerifyResult) { result <- res.Valid }}
+	return result
+}
+
+// Close stops the workers after all queued blocks have been verified.
+func (v *Verifier) Close() {
+	close(v.queue)
+	v.wg.Wait()
+}
@@ -0,0 +1,163 @@
+package ethash
+
+import (
+	"crypto/rand"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+func TestVerifier(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth.Turbo(true)
+	defer os.RemoveAll(eth.Full.Dir)
+
+	var blocks []pow.Block
+	for i := 0; i < 20; i++ {
+		block := &testBlock{number: uint64(i), difficulty: big.NewInt(100)}
+		rand.Read(block.hashNoNonce[:])
+		block.seal(eth.Search(block, nil))
+		if i%5 == 0 {
+			// make every fifth block invalid.
+			block.difficulty = big.NewInt(1000000)
+		}
+		blocks = append(blocks, block)
+	}
+
+	v := NewVerifier(eth.Light, 4)
+	defer v.Close()
+	seen := make(map[int]bool)
+	for res := range v.Submit(blocks) {
+		if seen[res.Index] {
+			t.Errorf("block %d: duplicate result", res.Index)
+		}
+		seen[res.Index] = true
+		if want := res.Index%5 != 0; res.Valid != want {
+			t.Errorf("block %d: got valid %t, want %t", res.Index, res.Valid, want)
+		}
+	}
+	if len(seen) != len(blocks) {
+		t.Errorf("got %d results, want %d", len(seen), len(blocks))
+	}
+}
+
+func TestVerifierAsync(t *testing.T) {
+	eth, err := NewForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(eth.Full.Dir)
+
+	block := &testBlock{difficulty: big.NewInt(10)}
+	block.seal(eth.Search(block, nil))
+	bad := &testBlock{difficulty: big.NewInt(1000000)}
+
+	v := NewVerifier(eth.Light, 2)
+	defer v.Close()
+	okc, badc := v.VerifyAsync(block), v.VerifyAsync(bad)
+	if !<-okc {
+		t.Error("valid block did not verify")
+	}
+	if <-badc {
+		t.Error("invalid block verified")
+	}
+}
+
+func TestVerifierSampled(t *testing.T) {
+	// seals are never valid, so the sampled blocks are those reported invalid.
+	var blocks []pow.Block
+	for i := 0; i < 23; i++ {
+		blocks = append(blocks, &testBlock{number: uint64(i), difficulty: big.NewInt(1000000)})
+	}
+
+	v := NewVerifier(&Light{test: true}, 4)
+	defer v.Close()
+	checked := make(map[int]int)
+	n := 0
+	for res := range v.SubmitSampled(blocks, 5) {
+		n++
+		if res.Valid != res.Skipped {
+			t.Errorf("block %d: got valid %t, skipped %t", res.Index, res.Valid, res.Skipped)
+		}
+		if !res.Skipped {
+			checked[res.Index/5]++
+		}
+	}
+	if n != len(blocks) {
+		t.Errorf("got %d results, want %d", n, len(blocks))
+	}
+	for segment, want := range []int{1, 1, 1, 1, 3} {
+		if checked[segment] != want {
+			t.Errorf("segment %d: %d blocks verified, want %d", segment, checked[segment], want)
+		}
+	}
+}
+
+func TestVerifierPrewarm(t *testing.T) {
+	// epochs 30 and 31 are not used by other tests. The blocks pass
+	// the quick check, so they reach the cache.
+	const epoch = 31
+	chain := testChain(epoch*epochLength - 6000)
+	light := &Light{test: true}
+	defer light.FreeCache()
+	v := NewVerifier(light, 2)
+	defer v.Close()
+	batch := func(from, to uint64) []pow.Block {
+		var blocks []pow.Block
+		for n := from; n != to; {
+			blocks = append(blocks, &testBlock{number: n, difficulty: big.NewInt(1)})
+			if from < to {
+				n++
+			} else {
+				n--
+			}
+		}
+		return blocks
+	}
+	next := func() *cache {
+		light.mu.Lock()
+		defer light.mu.Unlock()
+		return light.next
+	}
+	submit := func(blocks []pow.Block) {
+		for range v.Submit(blocks) {
+		}
+	}
+
+	// nothing is pre-warmed before the head is known.
+	submit(batch(epoch*epochLength-400, epoch*epochLength-390))
+	if next() != nil {
+		t.Fatal("cache pre-warmed without head")
+	}
+	light.SetBlockProvider(&chain)
+	submit(batch(epoch*epochLength-5000, epoch*epochLength-4990))
+	if next() != nil {
+		t.Fatal("cache pre-warmed far from the epoch boundary")
+	}
+	// nor epochs beyond the lookahead, whatever the blocks claim.
+	submit(batch(2000*epochLength-400, 2000*epochLength-390))
+	if next() != nil {
+		t.Fatal("cache pre-warmed beyond the lookahead")
+	}
+	// going backwards, e.g. after a reorg, doesn't pre-warm.
+	submit(batch(epoch*epochLength-500, epoch*epochLength-510))
+	if next() != nil {
+		t.Fatal("cache pre-warmed for decreasing block numbers")
+	}
+	submit(batch(epoch*epochLength-400, epoch*epochLength-390))
+	c := next()
+	if c == nil || c.epoch != epoch {
+		t.Fatalf("got pre-warmed cache %v, want epoch %d", c, epoch)
+	}
+	c.generate()
+	// the first blocks of the epoch take the cache over.
+	submit(batch(epoch*epochLength, epoch*epochLength+10))
+	if next() != nil || light.caches.get(epoch) != c {
+		t.Error("pre-warmed cache not used for the epoch")
+	}
+}
@@ -0,0 +1,89 @@
+package ethash
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/pow"
+)
+
+// maxBoundary is the boundary of difficulty 1, which doesn't fit 256
+// bits as 2^256.
+var maxBoundary = new(big.Int).Sub(minDifficulty, big.NewInt(1))
+
+// WorkPackage is the work for mining a block elsewhere, as handed out
+// by eth_getWork. Its JSON encoding is that of eth_getWork: an array of
+// the hex encoded header hash, seed hash, boundary and block number.
+type WorkPackage struct {
+	HeaderHash  common.Hash // hash of the header without nonce and mix digest
+	SeedHash    common.Hash // seed hash of the block's epoch
+	Boundary    common.Hash // 2^256 / difficulty, a seal's result must not exceed it
+	BlockNumber uint64
+}
+
+// NewWorkPackage returns the work package of a pending block.
+func NewWorkPackage(block pow.Block) (WorkPackage, error) {
+	number := block.NumberU64()
+	if number >= epochLength*maxEpoch {
+		return WorkPackage{}, fmt.Errorf("block number %d too high, limit is %d", number, epochLength*maxEpoch)
+	}
+	if err := CheckDifficulty(block.Difficulty()); err != nil {
+		return WorkPackage{}, err
+	}
+	boundary := new(big.Int).Div(minDifficulty, block.Difficulty())
+	if boundary.Cmp(maxBoundary) > 0 {
+		boundary = maxBoundary
+	}
+	return WorkPackage{
+		HeaderHash:  block.HashNoNonce(),
+		SeedHash:    makeSeedHash(number / epochLength),
+		Boundary:    common.BigToHash(boundary),
+		BlockNumber: number,
+	}, nil
+}
+
+// Difficulty returns the difficulty corresponding to the boundary.
+func (w WorkPackage) Difficulty() *big.Int {
+	boundary := w.Boundary.Big()
+	if boundary.Sign() == 0 {
+		return new(big.Int).Set(minDifficulty)
+	}
+	if boundary.Cmp(maxBoundary) == 0 {
+		return big.NewInt(1)
+	}
+	return boundary.Div(minDifficulty, boundary)
+}
+
+// MarshalJSON encodes w like eth_getWork.
+func (w WorkPackage) MarshalJSON() ([]byte, error) {
+	return json.Marshal([4]string{w.HeaderHash.Hex(), w.SeedHash.Hex(), w.Boundary.Hex(), fmt.Sprintf("0x%x", w.BlockNumber)})
+}
+
+// UnmarshalJSON decodes the result of eth_getWork. Nodes that leave out
+// the block number get 0.
+func (w *WorkPackage) UnmarshalJSON(data []byte) error {
+	var fields []string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 && len(fields) != 4 {
+		return fmt.Errorf("work package has %d fields, want 3 or 4", len(fields))
+	}
+	var number uint64
+	if len(fields) == 4 {
+		var err error
+		if number, err = strconv.ParseUint(fields[3], 0, 64); err != nil {
+			return fmt.Errorf("invalid block number %q", fields[3])
+		}
+	}
+	*w = WorkPackage{
+		HeaderHash:  common.HexToHash(fields[0]),
+		SeedHash:    common.HexToHash(fields[1]),
+		Boundary:    common.HexToHash(fields[2]),
+		BlockNumber: number,
+	}
+	return nil
+}
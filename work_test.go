@@ -0,0 +1,82 @@
+package ethash
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestWorkPackage(t *testing.T) {
+	block := &testBlock{difficulty: big.NewInt(1000), hashNoNonce: common.HexToHash("0x1234"), number: 30001}
+	w, err := NewWorkPackage(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.HeaderHash != block.hashNoNonce || w.SeedHash != makeSeedHash(1) || w.BlockNumber != 30001 {
+		t.Errorf("got %+v", w)
+	}
+	wantBoundary := new(big.Int).Div(minDifficulty, big.NewInt(1000))
+	if w.Boundary.Big().Cmp(wantBoundary) != 0 {
+		t.Errorf("boundary %x, want %x", w.Boundary, wantBoundary)
+	}
+	if w.Difficulty().Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("difficulty %v, want 1000", w.Difficulty())
+	}
+
+	enc, err := json.Marshal(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `["` + w.HeaderHash.Hex() + `","` + w.SeedHash.Hex() + `","` + w.Boundary.Hex() + `","0x7531"]`
+	if string(enc) != want {
+		t.Errorf("got %s, want %s", enc, want)
+	}
+	var dec WorkPackage
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec != w {
+		t.Errorf("decoded %+v, want %+v", dec, w)
+	}
+}
+
+func TestWorkPackageDifficultyOne(t *testing.T) {
+	w, err := NewWorkPackage(&testBlock{difficulty: big.NewInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Boundary.Hex() != "0x"+strings.Repeat("f", 64) {
+		t.Errorf("boundary %x, want all bits set", w.Boundary)
+	}
+	if w.Difficulty().Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("difficulty %v, want 1", w.Difficulty())
+	}
+}
+
+func TestWorkPackageInvalid(t *testing.T) {
+	if _, err := NewWorkPackage(&testBlock{difficulty: big.NewInt(0)}); err == nil {
+		t.Error("no error for zero difficulty")
+	}
+	if _, err := NewWorkPackage(&testBlock{difficulty: big.NewInt(1), number: epochLength * maxEpoch}); err == nil {
+		t.Error("no error for block beyond the last epoch")
+	}
+}
+
+func TestWorkPackageUnmarshal(t *testing.T) {
+	// nodes predating the block number send three fields.
+	var w WorkPackage
+	if err := json.Unmarshal([]byte(`["0x01","0x02","0x03"]`), &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.HeaderHash != common.HexToHash("0x01") || w.Boundary != common.HexToHash("0x03") || w.BlockNumber != 0 {
+		t.Errorf("got %+v", w)
+	}
+	for _, in := range []string{`["0x01","0x02"]`, `["0x01","0x02","0x03","x"]`, `{}`} {
+		if err := json.Unmarshal([]byte(in), &w); err == nil {
+			t.Errorf("no error for %s", in)
+		}
+	}
+}